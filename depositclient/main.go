@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+)
+
+/* variables for the deposit client. Try depositclient --help to see what you should be passing */
+var (
+	app               = kingpin.New("depositclient", "Deposits a coin written by partialblindsigclient --coin and checks the receipt")
+	appReceiptkeyfile = app.Arg("receiptkey", "Path to the public key the deposit server signs receipts with").Required().String()
+	appCoinfile       = app.Arg("coin", "Path to the coin").Required().String()
+	appHostspec       = app.Arg("host", "Server to connect to, as host:port").Required().String()
+)
+
+// How long the server has to answer.
+const protocolTimeout = 30 * time.Second
+
+// Status bytes the server starts its replies with. A coin we deposited
+// before gets STATUS_OK and the receipt it was given then.
+const (
+	STATUS_OK    byte = 0
+	STATUS_ERROR byte = 2
+)
+
+// Largest receipt we read.
+const maxReceiptSize = 1024
+
+/* Sends the coin to the deposit server and checks the receipt it gives
+   back is signed by the receipt key and names our coin. */
+func main() {
+
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	var hostspec string = *appHostspec
+
+	pubKey, err := schnorrgs.SchnorrLoadPubkey(*appReceiptkeyfile)
+	if err != nil {
+		fmt.Println("CLIENT", "Error loading public key "+err.Error())
+		return
+	}
+	err = pubKey.CheckUsage(schnorrgs.UsageNotary)
+	if err != nil {
+		fmt.Println("CLIENT", "Error "+err.Error())
+		return
+	}
+	suite, err := schnorrgs.GetSuite(pubKey.Suite())
+	if err != nil {
+		fmt.Println("CLIENT", "Error "+err.Error())
+		return
+	}
+
+	b, err := ioutil.ReadFile(*appCoinfile)
+	if err != nil {
+		fmt.Println("CLIENT", "Error loading coin "+err.Error())
+		return
+	}
+	var coin schnorrgs.WIBlindCoin
+	err = coin.UnmarshalBinary(suite, b)
+	if err != nil {
+		fmt.Println("CLIENT", "Error loading coin "+err.Error())
+		return
+	}
+	serial := coin.Serial()
+
+	fmt.Println("CLIENT", "Depositing coin", hex.EncodeToString(serial),
+		"at", hostspec)
+	conn, err := net.Dial("tcp", hostspec)
+	if err != nil {
+		fmt.Println("CLIENT", "Error connecting to server", err.Error())
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(protocolTimeout))
+
+	request := make([]byte, 4)
+	binary.BigEndian.PutUint32(request, uint32(len(b)))
+	_, err = conn.Write(append(request, b...))
+	if err != nil {
+		fmt.Println("CLIENT", "Error writing to server", err.Error())
+		return
+	}
+
+	// the server answers with a status and, for a deposit, the receipt,
+	// then hangs up.
+	reply, err := ioutil.ReadAll(io.LimitReader(conn, maxReceiptSize+1))
+	if err != nil {
+		fmt.Println("CLIENT", "Error reading from server", err.Error())
+		return
+	}
+	if len(reply) == 0 {
+		fmt.Println("CLIENT", "Error server hung up")
+		return
+	}
+	switch reply[0] {
+	case STATUS_OK:
+	default:
+		fmt.Println("CLIENT", "Deposit REFUSED, coin is not valid")
+		return
+	}
+
+	var receipt schnorrgs.WIDepositReceipt
+	err = receipt.UnmarshalBinary(suite, reply[1:])
+	if err != nil {
+		fmt.Println("CLIENT", "Error reading receipt", err.Error())
+		return
+	}
+	ok, err := receipt.Verify(suite, *pubKey)
+	if err != nil {
+		fmt.Println("CLIENT", "Error verifying receipt", err.Error())
+		return
+	}
+	if !ok || !bytes.Equal(receipt.Serial, serial) {
+		fmt.Println("CLIENT", "Receipt verify FAILED")
+		return
+	}
+	fmt.Println("CLIENT", "Receipt verified OK - denomination",
+		receipt.Denomination, "face value", receipt.FaceValue, "at",
+		receipt.Time.Format(time.RFC3339))
+}
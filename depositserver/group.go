@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+)
+
+/* Coins signed by a group of partialblindsigservers verify under the
+   group's joint key. The group configuration is checked before we trust
+   it: its joint key has to be the aggregate of the member keys, so that
+   a coin really needed every member. */
+
+/* Loads the group configuration at path. Returns the joint key and the
+   member keys, any of which may sign the catalogue. */
+func loadSigningGroup(path string) (*schnorrgs.SchnorrPublicKV,
+	[]schnorrgs.SchnorrPublicKV, error) {
+
+	config, err := schnorrgs.SchnorrLoadGroupConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = config.VerifyBlindSigners()
+	if err != nil {
+		return nil, nil, err
+	}
+	joint, err := config.GetJointKeyAsKV()
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := config.GetMemberKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, k := range keys {
+		err = k.CheckUsage(schnorrgs.UsageBlind)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return joint, keys, nil
+}
+
+/* Checks the catalogue is signed by one of keys. */
+func verifyCatalogue(suite schnorrgs.CryptoSuite,
+	catalogue schnorrgs.SchnorrDenominationCatalogue,
+	keys []schnorrgs.SchnorrPublicKV) error {
+
+	var err error
+	for _, k := range keys {
+		err = catalogue.Verify(suite, k)
+		if err == nil {
+			return nil
+		}
+	}
+	if len(keys) > 1 {
+		return errors.New("Catalogue is not signed by a group member.")
+	}
+	return err
+}
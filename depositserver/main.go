@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"golang.org/x/net/context"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	"net"
+	"os"
+	"os/signal"
+)
+
+/* These variables form the command line parameters of the deposit server. */
+var (
+	app               = kingpin.New("depositserver", "Deposit server - takes coins signed by partialblindsigserver, once each, and gives signed receipts for them")
+	appBankkeyfile    = app.Arg("bankkey", "Path to the public key coins are blindly signed with, or with --group to the group configuration of the signers").Required().String()
+	appCatalogue      = app.Arg("catalogue", "Path to the denomination catalogue, as written by keytool mkcatalogue").Required().String()
+	appReceiptkeyfile = app.Arg("receiptkey", "Path to the private key receipts are signed with").Required().String()
+	appSpentfile      = app.Arg("spent", "Path to the store of spent coins; created if it does not exist").Required().String()
+	appPort           = app.Arg("port", "Listen on port").Default("1115").Int()
+	appGroup          = app.Flag("group", "Coins are signed by a group of servers together, under the joint key of bankkey's group configuration").Bool()
+	appPassfile       = app.Flag("passfile", "Read the passphrase of an encrypted receipt key from this file instead of $"+schnorrgs.PassphraseEnvVar+" or the terminal").String()
+)
+
+/* runs through the process of setting up the server as specified in the args */
+func main() {
+
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	var port int = *appPort
+	var kfilepath string = *appReceiptkeyfile
+
+	fmt.Printf("Deposit server - listening on port %d.\n", port)
+
+	// a group's catalogues are each signed by a member, the coins by all.
+	var bankKey *schnorrgs.SchnorrPublicKV
+	var catalogueKeys []schnorrgs.SchnorrPublicKV
+	var err error
+	if *appGroup {
+		bankKey, catalogueKeys, err = loadSigningGroup(*appBankkeyfile)
+	} else {
+		bankKey, err = schnorrgs.SchnorrLoadPubkey(*appBankkeyfile)
+		if err == nil {
+			err = bankKey.CheckUsage(schnorrgs.UsageBlind)
+			catalogueKeys = []schnorrgs.SchnorrPublicKV{*bankKey}
+		}
+	}
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	suite, err := schnorrgs.GetSuite(bankKey.Suite())
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+
+	// only denominations the bank signed for are accepted.
+	catalogue, err := schnorrgs.SchnorrLoadDenominationCatalogue(*appCatalogue)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	err = verifyCatalogue(suite, *catalogue, catalogueKeys)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+
+	kv, encrypted, err := schnorrgs.SchnorrLoadSecretKVWithPassphrase(kfilepath,
+		func() ([]byte, error) {
+			return schnorrgs.SchnorrReadPassphrase(*appPassfile,
+				"Passphrase for "+kfilepath+": ")
+		})
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	if !encrypted {
+		fmt.Println("Warning: keyfile " + kfilepath + " is not encrypted")
+	}
+	err = kv.CheckUsage(schnorrgs.UsageNotary)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	if kv.Suite() != bankKey.Suite() {
+		fmt.Println("Error receipt key is " + kv.Suite() + " but the bank key is " +
+			bankKey.Suite())
+		return
+	}
+
+	spent, torn, err := openSpentStore(*appSpentfile)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	defer spent.Close()
+	if torn > 0 {
+		fmt.Println("Warning: dropped an unfinished record from", *appSpentfile)
+	}
+	fmt.Println(spent.Len(), "coins spent so far")
+
+	bank := &depositBank{suite: suite, bankKey: *bankKey,
+		catalogue: *catalogue, receiptKey: *kv, spent: spent}
+
+	var depositImpl connectionhandler = func(conn net.Conn) {
+		handleDeposit(conn, bank)
+	}
+
+	exitCh := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt)
+
+	go func() {
+		select {
+		case <-signalCh:
+			cancel()
+			return
+		}
+	}()
+
+	go serve(port, depositImpl, ctx, exitCh)
+
+	// delay main thread until worker has returned.
+	<-exitCh
+	fmt.Println("Exiting server now.")
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"golang.org/x/net/context"
+	"io"
+	"net"
+	"time"
+)
+
+// type alias for handling connections.
+type connectionhandler func(conn net.Conn)
+
+// Status bytes the server starts its replies with. STATUS_ERROR means the
+// coin was refused. A coin deposited before gets STATUS_OK and the
+// receipt it was given the first time.
+const (
+	STATUS_OK    byte = 0
+	STATUS_ERROR byte = 2
+)
+
+// How long a client has to send its coin.
+const depositTimeout = 30 * time.Second
+
+// Largest coin we read.
+const maxCoinSize = 64 * 1024
+
+// Everything the server needs to take deposits.
+type depositBank struct {
+	suite      schnorrgs.CryptoSuite
+	bankKey    schnorrgs.SchnorrPublicKV
+	catalogue  schnorrgs.SchnorrDenominationCatalogue
+	receiptKey schnorrgs.SchnorrSecretKV
+	spent      *spentStore
+}
+
+/* Reads a coin, sent as len||coin with a uint32 length. */
+func readCoin(conn net.Conn, suite schnorrgs.CryptoSuite) (schnorrgs.WIBlindCoin,
+	error) {
+
+	size := make([]byte, 4)
+	_, err := io.ReadFull(conn, size)
+	if err != nil {
+		return schnorrgs.WIBlindCoin{}, err
+	}
+	n := binary.BigEndian.Uint32(size)
+	if n > maxCoinSize {
+		return schnorrgs.WIBlindCoin{}, errors.New("Coin too large.")
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(conn, b)
+	if err != nil {
+		return schnorrgs.WIBlindCoin{}, err
+	}
+	var coin schnorrgs.WIBlindCoin
+	err = coin.UnmarshalBinary(suite, b)
+	return coin, err
+}
+
+/* Checks a coin was signed by the bank for a denomination still in
+   circulation, and marks it spent. The receipt is made first and stored
+   with the serial, and only handed out once both are on disk. A coin that
+   was spent before gets its first receipt back along with errDoubleSpend,
+   so nobody gets two different receipts for a coin, and a client that
+   lost the connection before the receipt reached it can ask again. */
+func (bank *depositBank) deposit(
+	coin schnorrgs.WIBlindCoin) (schnorrgs.WIDepositReceipt, []byte, error) {
+
+	denomination, err := bank.catalogue.LookupInfo(coin.Info, time.Now())
+	if err != nil {
+		return schnorrgs.WIDepositReceipt{}, nil, err
+	}
+	ok, err := coin.Verify(bank.suite, bank.bankKey)
+	if err != nil {
+		return schnorrgs.WIDepositReceipt{}, nil, err
+	}
+	if !ok {
+		return schnorrgs.WIDepositReceipt{}, nil,
+			errors.New("Coin signature does not verify.")
+	}
+
+	serial := coin.Serial()
+	receipt, err := schnorrgs.SchnorrSignDepositReceipt(bank.suite,
+		bank.receiptKey, serial, denomination, time.Now())
+	if err != nil {
+		return schnorrgs.WIDepositReceipt{}, nil, err
+	}
+	receiptb, err := receipt.MarshalBinary()
+	if err != nil {
+		return schnorrgs.WIDepositReceipt{}, nil, err
+	}
+	stored, err := bank.spent.Spend(serial, receiptb)
+	if err == errDoubleSpend {
+		var earlier schnorrgs.WIDepositReceipt
+		uerr := earlier.UnmarshalBinary(bank.suite, stored)
+		if uerr != nil {
+			return schnorrgs.WIDepositReceipt{}, nil, uerr
+		}
+		return earlier, stored, errDoubleSpend
+	}
+	if err != nil {
+		return schnorrgs.WIDepositReceipt{}, nil, err
+	}
+	return receipt, receiptb, nil
+}
+
+/* This function takes one coin from a client and answers with
+   OK||receipt, or the status saying why it was refused. It can be bound
+   via closure given a specific bank and send to the serve() function. */
+func handleDeposit(conn net.Conn, bank *depositBank) {
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(depositTimeout))
+	coin, err := readCoin(conn, bank.suite)
+	if err != nil {
+		fmt.Println("SERVER", "Error reading coin", err.Error())
+		conn.Write([]byte{STATUS_ERROR})
+		return
+	}
+	serial := hex.EncodeToString(coin.Serial())
+
+	receipt, b, err := bank.deposit(coin)
+	again := err == errDoubleSpend
+	if again {
+		fmt.Println("SERVER", "Coin", serial,
+			"was already spent, sending its receipt again")
+	} else if err != nil {
+		fmt.Println("SERVER", "Refusing coin", serial, err.Error())
+		conn.Write([]byte{STATUS_ERROR})
+		return
+	}
+
+	_, err = conn.Write(append([]byte{STATUS_OK}, b...))
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		return
+	}
+	if again {
+		return
+	}
+	fmt.Println("SERVER", "Deposited coin", serial, "of denomination",
+		receipt.Denomination, "face value", receipt.FaceValue)
+}
+
+/* The serve function is designed to serve an arbitrary connection handler
+   specified as a handler of type connectionhandler on port "port"
+   from this machine.
+*/
+func serve(port int, handler connectionhandler, ctx context.Context, exitCh chan struct{}) {
+
+	if port < 1024 || port > 65535 {
+		// todo: how does go handle errors.
+		fmt.Println("Error port must be between 1024 and 65535")
+		exitCh <- struct{}{}
+		return
+	}
+
+	portspec := fmt.Sprintf("0.0.0.0:%d", port)
+	addr, err := net.ResolveTCPAddr("tcp", portspec)
+	if err != nil {
+		fmt.Println(err.Error())
+		exitCh <- struct{}{}
+		return
+	}
+	sock, err := net.ListenTCP("tcp", addr)
+	if err != nil {
+		// error
+		fmt.Println(err.Error())
+		exitCh <- struct{}{}
+		return
+	}
+	// Let Accept be non-blocking / fall through to our loop
+	// an alternative would be for accept to dispatch as needed
+	// via a select / goroutines and then
+	// each handler function could check whether it should handle or exit.
+	for {
+		sock.SetDeadline(time.Now().Add(5 * time.Second))
+		conn, err := sock.Accept()
+		if err != nil {
+			if e, ok := err.(net.Error); !ok || !e.Timeout() {
+				fmt.Println(err.Error())
+				exitCh <- struct{}{}
+				return
+			}
+		} else {
+			go handler(conn)
+		}
+		// check if we need to exit:
+		select {
+		case <-ctx.Done():
+			exitCh <- struct{}{}
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/* The spent coins are kept in an append only file, one record per coin:
+   the length of its receipt as a uint16, the receipt, which starts with
+   the serial, and a CRC-32 of both. The record is written and synced to
+   disk before the receipt is handed out, and a coin deposited again gets
+   the same receipt back, so a client that lost the connection in between
+   can still collect it.
+
+   A crash can only tear the record being written, which is always the
+   last one; it is dropped when the file is opened again, as that deposit
+   was never acknowledged. A bad record anywhere else means the file was
+   damaged, and we refuse to run on it rather than forget spent coins.
+
+   One server owns a store: the lock around check and insert is only
+   shared between the connections of one process. */
+
+// Size of a record apart from the receipt: its length and the checksum.
+const spentRecordOverhead = 2 + 4
+
+// Largest receipt a record holds.
+const maxStoredReceipt = 1<<16 - 1
+
+var (
+	errDoubleSpend  = errors.New("Coin has already been spent.")
+	errCorruptStore = errors.New("Spent coin store is damaged.")
+)
+
+// The spent coins of a deposit server, with their receipts. It is safe for
+// concurrent use.
+type spentStore struct {
+	mu    sync.Mutex
+	f     *os.File
+	size  int64
+	spent map[string][]byte
+}
+
+/* Opens the store at path, creating it if need be, and loads the serials
+   and receipts recorded in it. Returns how many bytes of a torn record were dropped. */
+func openSpentStore(path string) (*spentStore, int, error) {
+	_, err := os.Stat(path)
+	created := os.IsNotExist(err)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, 0, err
+	}
+	if created {
+		// the new file's directory entry has to be durable too.
+		err = syncDir(filepath.Dir(path))
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	s := &spentStore{f: f, spent: make(map[string][]byte)}
+	for {
+		receipt, n, ok := parseRecord(data[s.size:])
+		if !ok {
+			break
+		}
+		s.spent[string(receipt[:schnorrgs.CoinSerialSize])] = receipt
+		s.size += int64(n)
+	}
+
+	// only the last record can be torn; anything after it is damage.
+	torn := len(data) - int(s.size)
+	if torn > 0 && !recordTorn(data[s.size:]) {
+		f.Close()
+		return nil, 0, errCorruptStore
+	}
+	if torn > 0 {
+		err = f.Truncate(s.size)
+		if err == nil {
+			err = f.Sync()
+		}
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+	}
+	return s, torn, nil
+}
+
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+/* Reads the record at the start of b. Returns its receipt and its size,
+   or false if b does not start with a whole, valid record. */
+func parseRecord(b []byte) ([]byte, int, bool) {
+	if len(b) < spentRecordOverhead {
+		return nil, 0, false
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	size := spentRecordOverhead + n
+	if n < schnorrgs.CoinSerialSize || len(b) < size {
+		return nil, 0, false
+	}
+	if crc32.ChecksumIEEE(b[:2+n]) != binary.BigEndian.Uint32(b[2+n:]) {
+		return nil, 0, false
+	}
+	return append([]byte{}, b[2:2+n]...), size, true
+}
+
+/* Tells whether b, which is not a valid record, could be what a crash
+   left of the last one: either cut short, or exactly one record long with
+   a bad checksum. */
+func recordTorn(b []byte) bool {
+	if len(b) < 2 {
+		return true
+	}
+	size := spentRecordOverhead + int(binary.BigEndian.Uint16(b))
+	return len(b) <= size
+}
+
+/* Marks the coin with the given serial as spent with the given receipt,
+   unless it already is. For a coin that was spent before it returns
+   errDoubleSpend along with the receipt it was given then. Once Spend
+   returns nil the receipt is on disk. */
+func (s *spentStore) Spend(serial []byte, receipt []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	earlier, ok := s.spent[string(serial)]
+	if ok {
+		return earlier, errDoubleSpend
+	}
+	if len(receipt) < schnorrgs.CoinSerialSize ||
+		len(receipt) > maxStoredReceipt ||
+		!bytes.Equal(receipt[:schnorrgs.CoinSerialSize], serial) {
+		return nil, errors.New("Receipt is not for this coin.")
+	}
+
+	n := len(receipt)
+	record := make([]byte, spentRecordOverhead+n)
+	binary.BigEndian.PutUint16(record, uint16(n))
+	copy(record[2:], receipt)
+	binary.BigEndian.PutUint32(record[2+n:], crc32.ChecksumIEEE(record[:2+n]))
+	_, err := s.f.WriteAt(record, s.size)
+	if err == nil {
+		err = s.f.Sync()
+	}
+	if err != nil {
+		// whatever made it to disk is dropped again; the coin is unspent.
+		s.f.Truncate(s.size)
+		return nil, err
+	}
+
+	s.size += int64(len(record))
+	s.spent[string(serial)] = append([]byte{}, receipt...)
+	return receipt, nil
+}
+
+// Returns how many coins have been spent.
+func (s *spentStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.spent)
+}
+
+func (s *spentStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
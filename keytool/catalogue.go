@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+/* Parses id,infofile,facevalue[,expires] arguments into denominations,
+   reading each info file, as written by raninf. */
+func parseDenominations(items []string) ([]schnorrgs.SchnorrDenomination,
+	error) {
+
+	var denominations []schnorrgs.SchnorrDenomination
+	for _, item := range items {
+		parts := strings.Split(item, ",")
+		if len(parts) < 3 || len(parts) > 4 {
+			return nil, errors.New("Invalid denomination " + item)
+		}
+		info, err := ioutil.ReadFile(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		facevalue, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			return nil, errors.New("Invalid face value " + parts[2])
+		}
+		expires := ""
+		if len(parts) == 4 {
+			expires = parts[3]
+		}
+		expiry, err := parseExpiry(expires)
+		if err != nil {
+			return nil, err
+		}
+		denominations = append(denominations,
+			schnorrgs.NewSchnorrDenomination(parts[0], info, facevalue, expiry))
+	}
+	return denominations, nil
+}
+
+/* Signs a catalogue of the denominations in items with the blind signing
+   key kpath (.pri) and writes it to output. */
+func runMakeCatalogue(kpath string, output string, items []string,
+	passfile string) error {
+
+	denominations, err := parseDenominations(items)
+	if err != nil {
+		return err
+	}
+	kv, _, err := schnorrgs.SchnorrLoadSecretKVWithPassphrase(kpath+".pri",
+		func() ([]byte, error) {
+			return schnorrgs.SchnorrReadPassphrase(passfile,
+				"Passphrase for "+kpath+".pri: ")
+		})
+	if err != nil {
+		return err
+	}
+	err = kv.CheckUsage(schnorrgs.UsageBlind)
+	if err != nil {
+		return err
+	}
+	suite, err := schnorrgs.GetSuite(kv.Suite())
+	if err != nil {
+		return err
+	}
+
+	catalogue, err := schnorrgs.SchnorrSignDenominationCatalogue(suite, *kv,
+		denominations)
+	if err != nil {
+		return err
+	}
+	err = schnorrgs.SchnorrSaveDenominationCatalogue(output, catalogue)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Written catalogue of", len(denominations),
+		"denominations to : "+output)
+	return nil
+}
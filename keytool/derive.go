@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"time"
+)
+
+/* Derives the key at path (for example m/notary/3) below the key kpath
+   (.pri) and writes it to output .pri, .pub, .pop and .xpub. An encrypted
+   key gives an encrypted child under the same passphrase, and the child
+   may be used for whatever its master may, until the master expires. The
+   path m
+   writes the key itself together with its .xpub, which is what verifiers
+   need for keytool derive-pub. */
+func runDerive(kpath string, path string, output string,
+	passfile string) error {
+
+	var passphrase []byte
+	kv, encrypted, err := schnorrgs.SchnorrLoadSecretKVWithPassphrase(kpath+".pri",
+		func() ([]byte, error) {
+			var err error
+			passphrase, err = schnorrgs.SchnorrReadPassphrase(passfile,
+				"Passphrase for "+kpath+".pri: ")
+			return passphrase, err
+		})
+	if err != nil {
+		return err
+	}
+	suite, err := schnorrgs.GetSuite(kv.Suite())
+	if err != nil {
+		return err
+	}
+
+	child, err := schnorrgs.SchnorrHDDeriveSecret(suite, *kv, path)
+	if err != nil {
+		return err
+	}
+	// the child is a new key for the same purposes as its master.
+	if parent := kv.Metadata(); parent != nil {
+		var expires time.Time
+		if parent.Expires != nil {
+			expires = *parent.Expires
+		}
+		meta, err := schnorrgs.NewSchnorrKeyMetadata(child.GetPublicKeyset(),
+			expires, parent.Usage...)
+		if err != nil {
+			return err
+		}
+		child, err = child.WithMetadata(meta)
+		if err != nil {
+			return err
+		}
+	}
+	xpub, err := child.GetHDPublicKeyset()
+	if err != nil {
+		return err
+	}
+
+	if encrypted {
+		err = schnorrgs.SchnorrSaveEncryptedSecretKV(output+".pri", child,
+			passphrase)
+	} else {
+		err = schnorrgs.SchnorrSaveSecretKV(output+".pri", child)
+	}
+	if err != nil {
+		return err
+	}
+	err = schnorrgs.SchnorrSavePubkey(output+".pub", child.GetPublicKeyset())
+	if err != nil {
+		return err
+	}
+	err = writeProofOfPossession(suite, child, output+".pop")
+	if err != nil {
+		return err
+	}
+	err = schnorrgs.SchnorrSaveHDPubkey(output+".xpub", xpub)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Derived " + path + " from " + kpath)
+	fmt.Println("Written private keypair to : " + output + ".pri")
+	fmt.Println("Written public key to      : " + output + ".pub")
+	fmt.Println("Written proof to           : " + output + ".pop")
+	fmt.Println("Written extended key to    : " + output + ".xpub")
+	return nil
+}
+
+/* Derives the public key at path below the extended public key in
+   xpubpath, without the secret key, and writes it to output .pub and
+   .xpub. Only normal (not hardened) steps can be derived this way. There
+   is no proof of possession, so such keys can not join a group until
+   their server runs keytool pop. */
+func runDerivePub(xpubpath string, path string, output string) error {
+
+	xpub, err := schnorrgs.SchnorrLoadHDPubkey(xpubpath)
+	if err != nil {
+		return err
+	}
+	suite, err := schnorrgs.GetSuite(xpub.Suite())
+	if err != nil {
+		return err
+	}
+
+	child, err := schnorrgs.SchnorrHDDerivePublic(suite, *xpub, path)
+	if err != nil {
+		return err
+	}
+	err = schnorrgs.SchnorrSavePubkey(output+".pub", child.GetPublicKeyset())
+	if err != nil {
+		return err
+	}
+	err = schnorrgs.SchnorrSaveHDPubkey(output+".xpub", child)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Derived " + path + " from " + xpubpath)
+	fmt.Println("Written public key to   : " + output + ".pub")
+	fmt.Println("Written extended key to : " + output + ".xpub")
+	return nil
+}
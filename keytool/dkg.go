@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"net"
+	"strconv"
+)
+
+// A connection to one DKG participant.
+type dkgPeer struct {
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+}
+
+// Sends req to the peer and waits for its answer.
+func (p dkgPeer) call(req schnorrgs.SchnorrDKGRequest) (schnorrgs.SchnorrDKGSigned, error) {
+	err := p.enc.Encode(req)
+	if err != nil {
+		return schnorrgs.SchnorrDKGSigned{}, err
+	}
+	var reply schnorrgs.SchnorrDKGReply
+	err = p.dec.Decode(&reply)
+	if err != nil {
+		return schnorrgs.SchnorrDKGSigned{}, err
+	}
+	if reply.Error != "" {
+		return schnorrgs.SchnorrDKGSigned{}, errors.New(reply.Error)
+	}
+	return reply.Message, nil
+}
+
+/* Relays a distributed key generation between sthresholdserver instances
+   started with -dkg, then writes the threshold group configuration. Each
+   host is given with the public half of the identity key it was started
+   with, and gets share index one more than its position in the list.
+   We only pass signed messages and encrypted shares around, so neither
+   this machine nor any other sees the group secret. */
+func runDKGRelay(group []SchnorrMSHostSpec, outputFile string,
+	threshold int) error {
+
+	var identities []string
+	var participants []schnorrgs.SchnorrPublicKV
+	for _, mshp := range group {
+		fmt.Println("Loading identity key " + mshp.KeyFilePath)
+		pkey, err := schnorrgs.SchnorrLoadPubkey(mshp.KeyFilePath)
+		if err != nil {
+			return err
+		}
+		if len(participants) > 0 && pkey.Suite() != participants[0].Suite() {
+			return errors.New("Key " + mshp.KeyFilePath + " is " +
+				pkey.Suite() + " but the group is " + participants[0].Suite())
+		}
+		identities = append(identities, pkey.Export())
+		participants = append(participants, *pkey)
+	}
+	if len(participants) == 0 {
+		return errors.New("No participants.")
+	}
+	suitename := participants[0].Suite()
+	suite, err := schnorrgs.GetSuite(suitename)
+	if err != nil {
+		return err
+	}
+
+	session := make([]byte, 16)
+	_, err = rand.Read(session)
+	if err != nil {
+		return err
+	}
+
+	var peers []dkgPeer
+	for _, mshp := range group {
+		conn, err := net.Dial("tcp", net.JoinHostPort(mshp.HostName,
+			strconv.Itoa(mshp.Port)))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		peers = append(peers, dkgPeer{conn, json.NewEncoder(conn),
+			json.NewDecoder(conn)})
+	}
+
+	// each round sends everybody everything from the round before.
+	req := schnorrgs.SchnorrDKGRequest{Step: schnorrgs.DKGStartStep,
+		Suite: suitename, Threshold: threshold, Session: session,
+		Participants: identities}
+	steps := []string{schnorrgs.DKGDealsStep, schnorrgs.DKGComplaintsStep,
+		schnorrgs.DKGJustificationsStep, schnorrgs.DKGCommitStep}
+
+	for _, next := range steps {
+		fmt.Println("DKG step", req.Step)
+		var msgs []schnorrgs.SchnorrDKGSigned
+		for k, p := range peers {
+			m, err := p.call(req)
+			if err != nil {
+				return errors.New("Participant " + strconv.Itoa(k+1) +
+					": " + err.Error())
+			}
+			msgs = append(msgs, m)
+		}
+		req = schnorrgs.SchnorrDKGRequest{Step: next, Messages: msgs}
+	}
+
+	// req now holds the results; check them before telling anybody to
+	// keep their share.
+	config, err := schnorrgs.SchnorrDKGVerifyResults(suite, participants,
+		threshold, session, req.Messages)
+	if err != nil {
+		return err
+	}
+	for k, p := range peers {
+		_, err := p.call(req)
+		if err != nil {
+			return errors.New("Participant " + strconv.Itoa(k+1) + ": " +
+				err.Error())
+		}
+	}
+
+	for k, mshp := range group {
+		config.Members[k].HostName = mshp.HostName
+		config.Members[k].Port = mshp.Port
+	}
+	fmt.Println("Group key " + config.JointKey)
+	return schnorrgs.SchnorrSaveGroupConfig(outputFile, config)
+}
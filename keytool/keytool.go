@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/rand"
 	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 	"os"
 	"strconv"
@@ -18,12 +19,70 @@ import (
 var (
 	app = kingpin.New("keytool", "Command line keygen tool for Schnorr work")
 
-	genCmd       = app.Command("gen", "Generate a new server instance pub,pri keypair")
-	genCmdOutput = genCmd.Arg("output", "Output file path to write (appends .pub, .pri)").Required().String()
-
-	groupCmd       = app.Command("mkgroup", "Create a Schnorr Multisignature group configuration file")
-	groupCmdOutput = groupCmd.Arg("output", "Write the output file to this path").Required().String()
-	groupCmdHost   = groupCmd.Arg("host:port,pathtokey", "triplet  indicating host to add").Required().Strings()
+	genCmd         = app.Command("gen", "Generate a new server instance pub,pri keypair")
+	genCmdOutput   = genCmd.Arg("output", "Output file path to write (appends .pub, .pri)").Required().String()
+	genCmdSuite    = genCmd.Flag("suite", "Cipher suite to generate the key in").Default(schnorrgs.DefaultSuite).Enum(schnorrgs.RegisteredSuites()...)
+	genCmdEncrypt  = genCmd.Flag("encrypt", "Encrypt the private key under a passphrase").Bool()
+	genCmdPassfile = genCmd.Flag("passfile", "Read the passphrase from this file instead of $"+schnorrgs.PassphraseEnvVar+" or the terminal").String()
+	genCmdUsage    = genCmd.Flag("usage", "Only allow the key to be used for this; may be repeated. Without it the key may be used for anything").Enums(schnorrgs.KeyUsages()...)
+	genCmdExpires  = genCmd.Flag("expires", "Expiry date of the key, YYYY-MM-DD").String()
+
+	popCmd         = app.Command("pop", "Write the proof of possession of an existing keypair")
+	popCmdPath     = popCmd.Arg("key", "Key file path without extension (reads .pri, writes .pop)").Required().String()
+	popCmdPassfile = popCmd.Flag("passfile", "Read the passphrase of an encrypted key from this file instead of $"+schnorrgs.PassphraseEnvVar+" or the terminal").String()
+
+	deriveCmd         = app.Command("derive", "Derive a child key by path, such as m/notary/3, from a master key")
+	deriveCmdKey      = deriveCmd.Arg("key", "Master key file path without extension (reads .pri)").Required().String()
+	deriveCmdPath     = deriveCmd.Arg("path", "Derivation path; a trailing ' hardens a step, as in m/notary'/3").Required().String()
+	deriveCmdOutput   = deriveCmd.Arg("output", "Output file path (writes .pri, .pub, .pop, .xpub)").Required().String()
+	deriveCmdPassfile = deriveCmd.Flag("passfile", "Read the passphrase of an encrypted key from this file instead of $"+schnorrgs.PassphraseEnvVar+" or the terminal").String()
+
+	derivePubCmd       = app.Command("derive-pub", "Derive the public key of a normal child from an extended public key")
+	derivePubCmdXpub   = derivePubCmd.Arg("xpub", "Extended public key file (.xpub) written by derive").Required().String()
+	derivePubCmdPath   = derivePubCmd.Arg("path", "Derivation path without hardened steps, as in m/notary/3").Required().String()
+	derivePubCmdOutput = derivePubCmd.Arg("output", "Output file path (writes .pub, .xpub)").Required().String()
+
+	dealCmd          = app.Command("deal", "Deal a new key out as shares for a t-of-n threshold group")
+	dealCmdOutput    = dealCmd.Arg("output", "Output file path prefix (writes <output><i>.pri,.pub,.pop per share and <output>.pub)").Required().String()
+	dealCmdShares    = dealCmd.Arg("shares", "Number of shares, n").Required().Int()
+	dealCmdThreshold = dealCmd.Flag("threshold", "Number of shares needed to sign, t").Required().Int()
+	dealCmdSuite     = dealCmd.Flag("suite", "Cipher suite to generate the key in").Default(schnorrgs.DefaultSuite).Enum(schnorrgs.RegisteredSuites()...)
+
+	dkgCmd          = app.Command("dkg", "Run a distributed key generation between sthresholdservers started with -dkg and write the group configuration")
+	dkgCmdOutput    = dkgCmd.Arg("output", "Write the group configuration to this path").Required().String()
+	dkgCmdHost      = dkgCmd.Arg("host:port,pathtoidentitykey", "server and the public key it was started with").Required().Strings()
+	dkgCmdThreshold = dkgCmd.Flag("threshold", "Number of members needed to sign, t").Required().Int()
+
+	groupCmd          = app.Command("mkgroup", "Create a Schnorr Multisignature group configuration file")
+	groupCmdOutput    = groupCmd.Arg("output", "Write the output file to this path").Required().String()
+	groupCmdHost      = groupCmd.Arg("host:port,pathtokey[,shareindex]", "triplet  indicating host to add; the proof is read from the .pop next to the key").Required().Strings()
+	groupCmdLegacy    = groupCmd.Flag("legacy", "Sum the member keys without MuSig coefficients (insecure, for old groups only)").Bool()
+	groupCmdThreshold = groupCmd.Flag("threshold", "Make a t-of-n threshold group of dealt shares; every member then needs its share index").Int()
+
+	ringSignCmd         = app.Command("ringsign", "Sign a file anonymously on behalf of the members of a group")
+	ringSignCmdGroup    = ringSignCmd.Arg("group", "Group configuration whose member keys form the ring").Required().String()
+	ringSignCmdKey      = ringSignCmd.Arg("key", "Member key file path without extension (reads .pri)").Required().String()
+	ringSignCmdMessage  = ringSignCmd.Arg("message", "File to sign").Required().String()
+	ringSignCmdOutput   = ringSignCmd.Arg("output", "Write the ring signature to this path").Required().String()
+	ringSignCmdContext  = ringSignCmd.Flag("context", "Context string to sign in").Default("").String()
+	ringSignCmdPassfile = ringSignCmd.Flag("passfile", "Read the passphrase of an encrypted key from this file instead of $"+schnorrgs.PassphraseEnvVar+" or the terminal").String()
+
+	ringVerifyCmd        = app.Command("ringverify", "Verify a ring signature against the members of a group")
+	ringVerifyCmdGroup   = ringVerifyCmd.Arg("group", "Group configuration whose member keys form the ring").Required().String()
+	ringVerifyCmdMessage = ringVerifyCmd.Arg("message", "File that was signed").Required().String()
+	ringVerifyCmdSig     = ringVerifyCmd.Arg("signature", "Ring signature file").Required().String()
+	ringVerifyCmdContext = ringVerifyCmd.Flag("context", "Context string the signature was made in").Default("").String()
+	ringVerifyCmdLink    = ringVerifyCmd.Flag("link", "Another ring signature and the message it signs, as sig:msg, to check for a common signer; may be repeated").Strings()
+
+	vectorsCmd       = app.Command("vectors", "Write known-answer test vectors for every scheme and suite, or check a vectors file")
+	vectorsCmdOutput = vectorsCmd.Arg("file", "Vectors file to write, or to check with --check").Required().String()
+	vectorsCmdCheck  = vectorsCmd.Flag("check", "Check the vectors in the file instead of writing it").Bool()
+
+	catalogueCmd         = app.Command("mkcatalogue", "Sign a catalogue of the denominations a blind signing server issues")
+	catalogueCmdKey      = catalogueCmd.Arg("key", "Blind signing key file path without extension (reads .pri)").Required().String()
+	catalogueCmdOutput   = catalogueCmd.Arg("output", "Write the catalogue to this path").Required().String()
+	catalogueCmdDenom    = catalogueCmd.Arg("id,infofile,facevalue[,expires]", "denomination to list; the info file is written by raninf and expires is YYYY-MM-DD").Required().Strings()
+	catalogueCmdPassfile = catalogueCmd.Flag("passfile", "Read the passphrase of an encrypted key from this file instead of $"+schnorrgs.PassphraseEnvVar+" or the terminal").String()
 
 	randomInfCmd       = app.Command("raninf", "Generate a random blob of shared information for Partially-Blind")
 	randomInfCmdOutput = randomInfCmd.Arg("output", "Output file path to write").Required().String()
@@ -54,6 +113,50 @@ func createRandomSharedInfoInFile(path string) error {
 	return err
 }
 
+/* Parses host:port,pathtokey[,shareindex] arguments, exiting on
+   anything malformed. */
+func parseHostSpecs(items []string) []SchnorrMSHostSpec {
+	var parties []SchnorrMSHostSpec
+
+	for _, item := range items {
+		parts := strings.Split(item, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			fmt.Println("Error invalid argument", item)
+			os.Exit(1)
+		}
+		hostspec := parts[0]
+		pubkeyfile := parts[1]
+
+		shareindex := 0
+		if len(parts) == 3 {
+			var err error
+			shareindex, err = strconv.Atoi(parts[2])
+			if err != nil {
+				fmt.Println("Error invalid share index")
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		}
+
+		hsparts := strings.Split(hostspec, ":")
+		if len(hsparts) != 2 {
+			fmt.Println("Error invalid argument", hostspec)
+			os.Exit(1)
+		}
+		host := hsparts[0]
+		port, err := strconv.Atoi(hsparts[1])
+		if err != nil {
+			fmt.Println("Error invalid argument")
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		party := SchnorrMSHostSpec{host, port, pubkeyfile, shareindex}
+		parties = append(parties, party)
+	}
+	return parties
+}
+
 /* Entry point to the keytool utility. Switches based on the command line argument structure
    given above.
    Parses all  arguments except os.Args[0], the program name.
@@ -62,31 +165,76 @@ func main() {
 
 	switch kingpin.MustParse(app.Parse(os.Args[1:])) {
 	case genCmd.FullCommand():
-		runKeyGen(*genCmdOutput)
+		runKeyGen(*genCmdOutput, *genCmdSuite, *genCmdEncrypt, *genCmdPassfile,
+			*genCmdUsage, *genCmdExpires)
+	case popCmd.FullCommand():
+		err := runProofGen(*popCmdPath, *popCmdPassfile)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
+		}
+	case deriveCmd.FullCommand():
+		err := runDerive(*deriveCmdKey, *deriveCmdPath, *deriveCmdOutput,
+			*deriveCmdPassfile)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
+		}
+	case derivePubCmd.FullCommand():
+		err := runDerivePub(*derivePubCmdXpub, *derivePubCmdPath,
+			*derivePubCmdOutput)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
+		}
+	case dealCmd.FullCommand():
+		err := runDeal(*dealCmdOutput, *dealCmdSuite, *dealCmdThreshold, *dealCmdShares)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
+		}
+	case dkgCmd.FullCommand():
+		parties := parseHostSpecs(*dkgCmdHost)
+		err := runDKGRelay(parties, *dkgCmdOutput, *dkgCmdThreshold)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
+		}
 	case groupCmd.FullCommand():
-
-		var outputfile string = *groupCmdOutput
-		var parties []SchnorrMSHostSpec
-
-		for _, item := range *groupCmdHost {
-			parts := strings.Split(item, ",")
-			hostspec := parts[0]
-			pubkeyfile := parts[1]
-
-			hsparts := strings.Split(hostspec, ":")
-			host := hsparts[0]
-			port, err := strconv.Atoi(hsparts[1])
-			if err != nil {
-				fmt.Println("Error invalid argument")
-				fmt.Println(err.Error())
-				os.Exit(1)
-			}
-
-			party := SchnorrMSHostSpec{host, port, pubkeyfile}
-			parties = append(parties, party)
+		parties := parseHostSpecs(*groupCmdHost)
+		err := runMultiSignatureGen(parties, *groupCmdOutput, *groupCmdLegacy,
+			*groupCmdThreshold)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
+		}
+	case ringSignCmd.FullCommand():
+		err := runRingSign(*ringSignCmdGroup, *ringSignCmdKey, *ringSignCmdMessage,
+			*ringSignCmdOutput, *ringSignCmdContext, *ringSignCmdPassfile)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
+		}
+	case ringVerifyCmd.FullCommand():
+		err := runRingVerify(*ringVerifyCmdGroup, *ringVerifyCmdMessage,
+			*ringVerifyCmdSig, *ringVerifyCmdContext, *ringVerifyCmdLink)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
+		}
+	case vectorsCmd.FullCommand():
+		err := runVectors(*vectorsCmdOutput, *vectorsCmdCheck)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
+		}
+	case catalogueCmd.FullCommand():
+		err := runMakeCatalogue(*catalogueCmdKey, *catalogueCmdOutput,
+			*catalogueCmdDenom, *catalogueCmdPassfile)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			os.Exit(1)
 		}
-
-		runMultiSignatureGen(parties, outputfile)
 	case randomInfCmd.FullCommand():
 		var outputfile string = *randomInfCmdOutput
 		err := createRandomSharedInfoInFile(outputfile)
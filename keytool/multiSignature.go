@@ -1,40 +1,31 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/dedis/kyber/group/edwards25519"
 	"github.com/diagprov/dedischallenge/schnorrgs"
 	"os"
+	"strings"
 )
 
 type SchnorrMSHostSpec struct {
 	HostName    string
 	Port        int
 	KeyFilePath string
-}
-
-type SchnorrMMember struct {
-	HostName string
-	Port     int
-	PKey     string
-}
-
-type SchnorrMGroupConfig struct {
-	JointKey string
-	Members  []SchnorrMMember
+	ShareIndex  int
 }
 
 /* Create a group configuration file. This is really a convenience feature
    more than anything, making it easier to direct the client than supplying
-   all the arguments on the command line. */
-func runMultiSignatureGen(group []SchnorrMSHostSpec, outputFile string) error {
+   all the arguments on the command line.
+   The joint key uses MuSig aggregation unless legacy is set, which
+   reproduces the plain sum older versions wrote. A non-zero threshold
+   makes a t-of-n group out of shares from keytool deal instead. */
+func runMultiSignatureGen(group []SchnorrMSHostSpec, outputFile string,
+	legacy bool, threshold int) error {
 
-	var config SchnorrMGroupConfig
-	var pkeys []schnorrgs.SchnorrPublicKV
-	var pkeys_s []string
+	var config schnorrgs.SchnorrMGroupConfig
 
-	suite := edwards25519.NewBlakeSHA256Ed25519()
 	for _, mshp := range group {
 
 		fmt.Println("Loading public key " + mshp.KeyFilePath)
@@ -44,25 +35,65 @@ func runMultiSignatureGen(group []SchnorrMSHostSpec, outputFile string) error {
 			os.Exit(1)
 		}
 
-		pkey_s := pkey.Export()
-		pkeys = append(pkeys, *pkey)
-		pkeys_s = append(pkeys_s, pkey_s)
+		// every member has to be in the same suite as the first one.
+		if config.Suite == "" {
+			config.Suite = pkey.Suite()
+		} else if config.Suite != pkey.Suite() {
+			return errors.New("Key " + mshp.KeyFilePath + " is " +
+				pkey.Suite() + " but the group is " + config.Suite)
+		}
 
-		member := SchnorrMMember{mshp.HostName, mshp.Port, pkey_s}
+		// the proof lives next to the public key, as keytool gen writes it.
+		poppath := strings.TrimSuffix(mshp.KeyFilePath, ".pub") + ".pop"
+		suite, err := schnorrgs.GetSuite(pkey.Suite())
+		if err != nil {
+			return err
+		}
+		proof, err := schnorrgs.SchnorrLoadProofOfPossession(poppath, suite)
+		if err != nil {
+			return errors.New("No usable proof of possession for " +
+				mshp.KeyFilePath + ": " + err.Error())
+		}
+		pop, err := proof.ExportProofOfPossession()
+		if err != nil {
+			return err
+		}
+
+		member := schnorrgs.SchnorrMMember{HostName: mshp.HostName,
+			Port: mshp.Port, PKey: pkey.Export(), PoP: pop,
+			ShareIndex: mshp.ShareIndex}
+		err = member.VerifyProofOfPossession()
+		if err != nil {
+			return errors.New(mshp.KeyFilePath + ": " + err.Error())
+		}
+		err = pkey.CheckUsage(schnorrgs.UsageMultisig)
+		if err != nil {
+			return errors.New(mshp.KeyFilePath + ": " + err.Error())
+		}
 		config.Members = append(config.Members, member)
 	}
 
-	jointKey := schnorrgs.SchnorrMSComputeSharedPublicKey(suite, pkeys)
-	jointKey_s := jointKey.Export()
-	config.JointKey = jointKey_s
-
-	data, _ := json.Marshal(config)
+	if threshold > 0 {
+		for _, member := range config.Members {
+			if member.ShareIndex == 0 {
+				return errors.New("Threshold group members need a share index.")
+			}
+		}
+	} else {
+		for _, member := range config.Members {
+			if member.ShareIndex != 0 {
+				return errors.New("Share indices only make sense with --threshold.")
+			}
+		}
+	}
 
-	f, err := os.OpenFile(outputFile, os.O_CREATE|os.O_RDWR, 0644)
+	config.LegacyAggregation = legacy
+	config.Threshold = threshold
+	jointKey, err := config.ComputeJointKey()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = f.Write(data)
-	return err
+	config.JointKey = jointKey.Export()
+
+	return schnorrgs.SchnorrSaveGroupConfig(outputFile, config)
 }
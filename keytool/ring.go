@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+/* Loads the member keys of a group configuration as a ring, after
+   checking the configuration the way the servers do. */
+func loadRing(gpath string) (schnorrgs.CryptoSuite, []schnorrgs.SchnorrPublicKV,
+	error) {
+
+	group, err := schnorrgs.SchnorrLoadGroupConfig(gpath)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = group.Verify()
+	if err != nil {
+		return nil, nil, err
+	}
+	suite, err := group.GetSuite()
+	if err != nil {
+		return nil, nil, err
+	}
+	ring, err := group.GetMemberKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	return suite, ring, nil
+}
+
+/* Signs the contents of msgpath anonymously on behalf of the members of
+   the group in gpath, with the member key kpath (.pri), and writes the
+   ring signature to sigpath as hex. */
+func runRingSign(gpath string, kpath string, msgpath string, sigpath string,
+	context string, passfile string) error {
+
+	suite, ring, err := loadRing(gpath)
+	if err != nil {
+		return err
+	}
+	kv, _, err := schnorrgs.SchnorrLoadSecretKVWithPassphrase(kpath+".pri",
+		func() ([]byte, error) {
+			return schnorrgs.SchnorrReadPassphrase(passfile,
+				"Passphrase for "+kpath+".pri: ")
+		})
+	if err != nil {
+		return err
+	}
+	if kv.Suite() != ring[0].Suite() {
+		return errors.New("Key " + kpath + " is " + kv.Suite() +
+			" but the group is " + ring[0].Suite())
+	}
+	msg, err := ioutil.ReadFile(msgpath)
+	if err != nil {
+		return err
+	}
+
+	sig, err := schnorrgs.SchnorrRingSign(suite, *kv, ring, []byte(context), msg)
+	if err != nil {
+		return err
+	}
+	s, err := sig.Export()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(sigpath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write([]byte(s))
+	if err != nil {
+		return err
+	}
+	fmt.Println("Written ring signature to : " + sigpath)
+	return nil
+}
+
+/* Checks the ring signature in sigpath on the contents of msgpath against
+   the members of the group in gpath, and prints its key image. Each of
+   links names a further signature and the message it signs as sig:msg;
+   those are verified in the same context and reported if they were made
+   by the same member. A key image alone proves nothing, so a linked
+   signature that does not verify is an error. */
+func runRingVerify(gpath string, msgpath string, sigpath string,
+	context string, links []string) error {
+
+	suite, ring, err := loadRing(gpath)
+	if err != nil {
+		return err
+	}
+
+	load := func(path string) (schnorrgs.SchnorrRingSignature, error) {
+		fcontents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return schnorrgs.SchnorrRingSignature{}, err
+		}
+		return schnorrgs.NewSchnorrRingSignatureFromString(suite,
+			string(fcontents))
+	}
+
+	sig, err := load(sigpath)
+	if err != nil {
+		return err
+	}
+	msg, err := ioutil.ReadFile(msgpath)
+	if err != nil {
+		return err
+	}
+	v, err := schnorrgs.SchnorrRingVerify(suite, ring, []byte(context), msg, sig)
+	if err != nil {
+		return err
+	}
+	if !v {
+		return errors.New("Ring signature verify FAILED")
+	}
+	fmt.Println("Ring signature verified OK")
+	fmt.Println("Key image :", sig.I.String())
+
+	for _, link := range links {
+		i := strings.Index(link, ":")
+		if i < 0 {
+			return errors.New("Linked signature " + link +
+				" needs its message, as sig:msg")
+		}
+		path := link[:i]
+		other, err := load(path)
+		if err != nil {
+			return err
+		}
+		othermsg, err := ioutil.ReadFile(link[i+1:])
+		if err != nil {
+			return err
+		}
+		v, err := schnorrgs.SchnorrRingVerify(suite, ring, []byte(context),
+			othermsg, other)
+		if err != nil {
+			return err
+		}
+		if !v {
+			return errors.New("Ring signature " + path + " verify FAILED")
+		}
+		if schnorrgs.SchnorrRingLinked(sig, other) {
+			fmt.Println("Linked    : " + path + " was signed by the same member")
+		} else {
+			fmt.Println("Unlinked  : " + path)
+		}
+	}
+	return nil
+}
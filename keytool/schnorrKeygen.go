@@ -1,37 +1,124 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"github.com/dedis/kyber/group/edwards25519"
 	"github.com/diagprov/dedischallenge/schnorrgs"
+	"os"
+	"time"
 )
 
 /* Does excactly what it sounds like - creates and saves a schnorr public/private keypair.
    Much like ssh-keygen, we append .pub to the public key. Unlike ssh-keygen we append .pri
-   to the private key also. */
-func runKeyGen(kpath string) {
-	suite := edwards25519.NewBlakeSHA256Ed25519()
-	KeyGen(suite, kpath)
+   to the private key also. The proof of possession that mkgroup asks for
+   goes next to them in .pop. With encrypt set the private key is sealed
+   under a passphrase. Both key files record the key's metadata: it may
+   only be used for usage, or anything if that is empty, and expires at
+   expires unless that is empty. */
+func runKeyGen(kpath string, suitename string, encrypt bool, passfile string,
+	usage []string, expires string) {
+	suite, err := schnorrgs.GetSuite(suitename)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
+	expiry, err := parseExpiry(expires)
+	if err != nil {
+		fmt.Println("Error", err.Error())
+		return
+	}
+	var usages []schnorrgs.SchnorrKeyUsage
+	for _, u := range usage {
+		usages = append(usages, schnorrgs.SchnorrKeyUsage(u))
+	}
+	var passphrase []byte
+	if encrypt {
+		passphrase, err = readNewPassphrase(passfile)
+		if err != nil {
+			fmt.Println("Error", err.Error())
+			return
+		}
+	}
+	KeyGen(suite, kpath, passphrase, expiry, usages)
+}
+
+/* Reads an expiry date given as 2006-01-02 or in RFC 3339. Empty means
+   the key never expires. */
+func parseExpiry(expires string) (time.Time, error) {
+	if expires == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse("2006-01-02", expires)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.Parse(time.RFC3339, expires)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid expiry date " + expires +
+			", use YYYY-MM-DD")
+	}
+	return t, nil
+}
+
+/* Reads the passphrase for a new key. When it has to be typed in we ask
+   twice, since a typo would lock the key away for good. */
+func readNewPassphrase(passfile string) ([]byte, error) {
+	_, fromEnv := os.LookupEnv(schnorrgs.PassphraseEnvVar)
+	if passfile != "" || fromEnv {
+		return schnorrgs.SchnorrReadPassphrase(passfile, "")
+	}
+
+	pass, err := schnorrgs.SchnorrReadPassphrase("", "New passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	again, err := schnorrgs.SchnorrReadPassphrase("", "Repeat passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if string(pass) != string(again) {
+		return nil, errors.New("Passphrases do not match.")
+	}
+	return pass, nil
 }
 
-/* abstract keygen function. Takes any suite, although later code assumes ED25519 with the
-   fill curve group */
+/* abstract keygen function. Takes any registered suite; the suite name is
+   recorded in both key files. The private key is encrypted under
+   passphrase unless it is empty. */
 func KeyGen(suite schnorrgs.CryptoSuite,
-	kpath string) {
+	kpath string, passphrase []byte, expires time.Time,
+	usage []schnorrgs.SchnorrKeyUsage) {
 
 	var kpubpath string = kpath
 	var kpripath string = kpath
+	var kpoppath string = kpath
 	kpubpath = kpubpath + ".pub"
 	kpripath = kpripath + ".pri"
+	kpoppath = kpoppath + ".pop"
 
 	keypair, err := schnorrgs.SchnorrGenerateKeypair(suite)
 	if err != nil {
 		fmt.Println("Key generation failed")
+		fmt.Println(err.Error())
+		return
+	}
+	meta, err := schnorrgs.NewSchnorrKeyMetadata(keypair.GetPublicKeyset(),
+		expires, usage...)
+	if err == nil {
+		keypair, err = keypair.WithMetadata(meta)
+	}
+	if err != nil {
+		fmt.Println("Error", err.Error())
 		return
 	}
 	pubkey := keypair.GetPublicKeyset()
 
-	r := schnorrgs.SchnorrSaveSecretKV(kpripath, keypair)
+	var r error
+	if len(passphrase) > 0 {
+		r = schnorrgs.SchnorrSaveEncryptedSecretKV(kpripath, keypair, passphrase)
+	} else {
+		r = schnorrgs.SchnorrSaveSecretKV(kpripath, keypair)
+	}
 	if r != nil {
 		fmt.Printf("Unable to write to %s\n", kpripath)
 		fmt.Println("Error is")
@@ -43,6 +130,46 @@ func KeyGen(suite schnorrgs.CryptoSuite,
 		fmt.Printf("Unable to write to %s\n", kpubpath)
 		return
 	}
+	r = writeProofOfPossession(suite, keypair, kpoppath)
+	if r != nil {
+		fmt.Printf("Unable to write to %s\n", kpoppath)
+		return
+	}
 	fmt.Println("Written private keypair to : " + kpripath)
 	fmt.Println("Written public key to      : " + kpubpath)
+	fmt.Println("Written proof to           : " + kpoppath)
+	fmt.Println("Key ID                     : " + meta.KeyID)
+}
+
+func writeProofOfPossession(suite schnorrgs.CryptoSuite,
+	keypair schnorrgs.SchnorrSecretKV, path string) error {
+
+	proof, err := schnorrgs.SchnorrGenerateProofOfPossession(suite, keypair)
+	if err != nil {
+		return err
+	}
+	return schnorrgs.SchnorrSaveProofOfPossession(path, proof)
+}
+
+/* Writes the proof of possession for a key made before keytool gen
+   wrote them, from its .pri file. */
+func runProofGen(kpath string, passfile string) error {
+	keypair, _, err := schnorrgs.SchnorrLoadSecretKVWithPassphrase(kpath+".pri",
+		func() ([]byte, error) {
+			return schnorrgs.SchnorrReadPassphrase(passfile,
+				"Passphrase for "+kpath+".pri: ")
+		})
+	if err != nil {
+		return err
+	}
+	suite, err := schnorrgs.GetSuite(keypair.Suite())
+	if err != nil {
+		return err
+	}
+	err = writeProofOfPossession(suite, *keypair, kpath+".pop")
+	if err != nil {
+		return err
+	}
+	fmt.Println("Written proof to : " + kpath + ".pop")
+	return nil
 }
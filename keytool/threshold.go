@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"strconv"
+)
+
+/* Deals a fresh key out as n Shamir shares, any t of which can sign.
+   Share i (counting from 1) is written to kpath<i>.pri, .pub and .pop like
+   an ordinary key, and the group key to kpath.pub. Whoever runs this sees
+   the whole key, so run it somewhere the group trusts and hand out the
+   .pri files over secure channels. */
+func runDeal(kpath string, suitename string, t int, n int) error {
+	suite, err := schnorrgs.GetSuite(suitename)
+	if err != nil {
+		return err
+	}
+
+	groupkey, shares, err := schnorrgs.SchnorrTSDealShares(suite, t, n)
+	if err != nil {
+		return err
+	}
+
+	for k, share := range shares {
+		spath := kpath + strconv.Itoa(k+1)
+		err = schnorrgs.SchnorrSaveSecretKV(spath+".pri", share)
+		if err != nil {
+			return err
+		}
+		err = schnorrgs.SchnorrSavePubkey(spath+".pub", share.GetPublicKeyset())
+		if err != nil {
+			return err
+		}
+		err = writeProofOfPossession(suite, share, spath+".pop")
+		if err != nil {
+			return err
+		}
+		fmt.Println("Written share", k+1, "to     : "+spath+".pri")
+	}
+
+	err = schnorrgs.SchnorrSavePubkey(kpath+".pub", groupkey)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Written group key to  : " + kpath + ".pub")
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"strconv"
+)
+
+/* Writes the default known-answer vectors of every scheme and suite to
+   path, or with check set, regenerates the vectors in path and compares
+   them value by value. The vectors are made from fixed seeds, so writing
+   them twice gives the same file. */
+func runVectors(path string, check bool) error {
+
+	if check {
+		vectors, err := schnorrgs.SchnorrLoadTestVectors(path)
+		if err != nil {
+			return err
+		}
+		err = schnorrgs.CheckTestVectors(vectors)
+		if err != nil {
+			return err
+		}
+		fmt.Println("All " + strconv.Itoa(len(vectors.Vectors)) +
+			" vectors in " + path + " match")
+		return nil
+	}
+
+	vectors, err := schnorrgs.GenerateTestVectors()
+	if err != nil {
+		return err
+	}
+	err = schnorrgs.SchnorrSaveTestVectors(path, vectors)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Written " + strconv.Itoa(len(vectors.Vectors)) +
+		" vectors to " + path)
+	return nil
+}
@@ -1,10 +1,10 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"flag"
 	"fmt"
-	"github.com/dedis/kyber/group/edwards25519"
 	"github.com/diagprov/dedischallenge/schnorrgs"
 	"net"
 )
@@ -13,20 +13,33 @@ func main() {
 	var port int
 	var hostname string
 	var kfilepath string
+	var format string
+	var sigcontext string
 
 	flag.StringVar(&kfilepath, "keyfile", "", "Use the keyfile specified")
 	flag.StringVar(&hostname, "host", "localhost", "Connect to the specified host")
 	flag.IntVar(&port, "port", 1111, "Use the specified port")
+	flag.StringVar(&format, "format", "schnorr", "Signature format the server uses: schnorr or ed25519")
+	flag.StringVar(&sigcontext, "context", "notary-v1", "Context string the server makes schnorr signatures in")
 	flag.Parse()
 
-	suite := edwards25519.NewBlakeSHA256Ed25519()
-
 	pk, err := schnorrgs.SchnorrLoadPubkey(kfilepath)
 	if err != nil {
 		fmt.Println("Error " + err.Error())
 		return
 	}
 
+	suite, err := schnorrgs.GetSuite(pk.Suite())
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	err = pk.CheckUsage(schnorrgs.UsageNotary)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+
 	var hostspec string
 	hostspec = fmt.Sprintf("%s:%d", hostname, port)
 	fmt.Println("Connecting to %s\n", hostspec)
@@ -43,14 +56,35 @@ func main() {
 		return
 	}
 
-	buffer := make([]byte, 64)
+	// a signature is two scalars, (s, e), or an Ed25519 (R, S).
+	buffer := make([]byte, 2*suite.Scalar().MarshalSize())
+	if format == "ed25519" {
+		buffer = make([]byte, ed25519.SignatureSize)
+	}
 
 	conn.Write(randomdata)
-	_, err = conn.Read(buffer)
+	n, err := conn.Read(buffer)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
+	buffer = buffer[:n]
+
+	// Ed25519 receipts are checked with the standard library, exactly as
+	// a downstream service would.
+	if format == "ed25519" {
+		stdpk, err := pk.Ed25519PublicKey()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		if ed25519.Verify(stdpk, randomdata, buffer) {
+			fmt.Println("Ed25519 signature verified OK")
+		} else {
+			fmt.Println("Ed25519 signature verify FAILED")
+		}
+		return
+	}
 
 	sig, err := schnorrgs.DecodeSchnorrSignature(suite, buffer)
 	if err != nil {
@@ -58,7 +92,8 @@ func main() {
 		return
 	}
 
-	v, err := schnorrgs.SchnorrVerify(suite, *pk, randomdata, sig)
+	v, err := schnorrgs.SchnorrVerifyWithContext(suite, *pk, []byte(sigcontext),
+		randomdata, sig)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
@@ -3,7 +3,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/dedis/kyber/group/edwards25519"
 	"github.com/diagprov/dedischallenge/schnorrgs"
 	"golang.org/x/net/context"
 	"net"
@@ -14,26 +13,73 @@ import (
 func main() {
 	var port int
 	var kfilepath string
+	var passfile string
+	var noncemode string
+	var format string
+	var sigcontext string
 
 	flag.IntVar(&port, "port", 1111, "Listen on given port")
 	flag.StringVar(&kfilepath, "keyfile", "", "Use the keyfile specified")
+	flag.StringVar(&passfile, "passfile", "", "Read the passphrase of an encrypted keyfile from this file instead of $"+schnorrgs.PassphraseEnvVar+" or the terminal")
+	flag.StringVar(&noncemode, "nonce", "hedged", "Nonce mode: random, deterministic or hedged")
+	flag.StringVar(&format, "format", "schnorr", "Signature format: schnorr or ed25519 (RFC 8032)")
+	flag.StringVar(&sigcontext, "context", "notary-v1", "Context string schnorr signatures are made in")
 
 	flag.Parse()
+
+	mode, err := schnorrgs.ParseNonceMode(noncemode)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	if format != "schnorr" && format != "ed25519" {
+		fmt.Println("Error unknown signature format " + format)
+		return
+	}
+
 	fmt.Printf("notary - listening on port %d.\n", port)
 
-	suite := edwards25519.NewBlakeSHA256Ed25519()
-	kv, err := schnorrgs.SchnorrLoadSecretKV(kfilepath)
+	kv, encrypted, err := schnorrgs.SchnorrLoadSecretKVWithPassphrase(kfilepath,
+		func() ([]byte, error) {
+			return schnorrgs.SchnorrReadPassphrase(passfile,
+				"Passphrase for "+kfilepath+": ")
+		})
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	if !encrypted {
+		fmt.Println("Warning: keyfile " + kfilepath + " is not encrypted")
+	}
+	err = kv.CheckUsage(schnorrgs.UsageNotary)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+
+	// the key file tells us which suite we are working in.
+	suite, err := schnorrgs.GetSuite(kv.Suite())
 	if err != nil {
 		fmt.Println("Error " + err.Error())
 		return
 	}
 
+	// only Ed25519 keys can make Ed25519 signatures; better to find out now
+	// than on the first request.
+	if format == "ed25519" {
+		_, err = kv.GetPublicKeyset().Ed25519PublicKey()
+		if err != nil {
+			fmt.Println("Error " + err.Error())
+			return
+		}
+	}
+
 	// I don't know if there's a way to
 	// do std::bind-like behaviour in GO.
 	// for C++ what I'd do is pretty simple:
 	// newfunc := std::bind(&func, args to bind)
 	var signOneKBImpl connectionhandler = func(conn net.Conn) {
-		signOneKBSchnorr(conn, suite, kv)
+		signOneKBSchnorr(conn, suite, kv, mode, format, []byte(sigcontext))
 	}
 
 	exitCh := make(chan struct{})
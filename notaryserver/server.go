@@ -11,7 +11,12 @@ import (
 
 type connectionhandler func(conn net.Conn)
 
-func signOneKBSchnorr(conn net.Conn, suite schnorrgs.CryptoSuite, kv *schnorrgs.SchnorrSecretKV) {
+// Signs 1KB read from the connection and writes back the signature, either
+// our (s, e) encoding in the given context or, for format "ed25519", a
+// standard RFC 8032 signature (which is always deterministic, whatever the
+// nonce mode, and has no context).
+func signOneKBSchnorr(conn net.Conn, suite schnorrgs.CryptoSuite, kv *schnorrgs.SchnorrSecretKV,
+	mode schnorrgs.SchnorrNonceMode, format string, sigcontext []byte) {
 	buffer := make([]byte, 1024)
 
 	defer conn.Close()
@@ -25,7 +30,16 @@ func signOneKBSchnorr(conn net.Conn, suite schnorrgs.CryptoSuite, kv *schnorrgs.
 		conn.Close()
 	}
 
-	signature, err := schnorrgs.SchnorrSignBinary(suite, *kv, buffer)
+	var signature []byte
+	if format == "ed25519" {
+		signature, err = schnorrgs.SchnorrSignEd25519(suite, *kv, buffer)
+	} else {
+		var sig schnorrgs.SchnorrSignature
+		sig, err = schnorrgs.SchnorrSignWithContext(suite, *kv, sigcontext, buffer, mode)
+		if err == nil {
+			signature, err = sig.Encode()
+		}
+	}
 
 	conn.Write(signature)
 	if err != nil {
@@ -1,130 +1,306 @@
 package main
 
 import (
-	"bytes"
 	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"github.com/dedis/kyber/group/edwards25519"
 	"github.com/diagprov/dedischallenge/schnorrgs"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
+	"strconv"
+	"time"
 )
 
 /* variables for sigcli3. Try sigcli3 --help to see what you should be passing */
 var (
-	app               = kingpin.New("partialblindsigclient", "Client for partially blind signature scheme implementation")
-	appPrivatekeyfile = app.Arg("privatekey", "Path to schnorr public key").Required().String()
-	appInfo           = app.Arg("info", "Output file path to write (appends .pub, .pri)").Required().String()
-	appHostspec       = app.Arg("host", "Listen on port").Required().String()
+	app              = kingpin.New("partialblindsigclient", "Client for partially blind signature scheme implementation")
+	appPublickeyfile = app.Arg("publickey", "Path to schnorr public key, or with --group to the group configuration of the signers").Required().String()
+	appDenomination  = app.Arg("denomination", "ID of the denomination to ask for, as listed in the server's catalogue").Required().String()
+	appHostspec      = app.Arg("host", "Server to connect to, as host:port; not needed with --group").String()
+	appCoinfile      = app.Flag("coin", "Write the coin, the message with its signature and info, to this file for depositclient").String()
+	appGroup         = app.Flag("group", "Have every member of a group of servers sign together, under their joint key").Bool()
 )
 
-/* this function loads the random binary blob used as the
-   blind key and specified in path
-*/
-func LoadInfo(path string) ([]byte, error) {
-	fcontents, err := ioutil.ReadFile(path)
+// How long the server has for each step of the protocol.
+const protocolTimeout = 30 * time.Second
+
+// Size of the server's session IDs.
+const sessionIDSize = 16
+
+// Status bytes the server starts its replies with.
+const (
+	STATUS_OK    byte = 0
+	STATUS_BUSY  byte = 1
+	STATUS_ERROR byte = 2
+)
+
+/* Reads the status byte of a reply, explaining anything but OK. */
+func readStatus(conn net.Conn) error {
+	status := make([]byte, 1)
+	_, err := io.ReadFull(conn, status)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	switch status[0] {
+	case STATUS_OK:
+		return nil
+	case STATUS_BUSY:
+		wait := make([]byte, 2)
+		_, err = io.ReadFull(conn, wait)
+		if err != nil {
+			return err
+		}
+		return errors.New("Server busy, retry in " +
+			strconv.Itoa(int(binary.BigEndian.Uint16(wait))) + " seconds")
 	}
-	return fcontents, nil
+	return errors.New("Server refused the request")
 }
 
-/* Runs through the "user" side of the protocol i.e. the party
-   requesting a partially blind signature */
-func main() {
-
-	kingpin.MustParse(app.Parse(os.Args[1:]))
-
-	var kfilepath string = *appPrivatekeyfile
-	var kinfopath string = *appInfo
-	var hostspec string = *appHostspec
+// Requests we start with.
+const (
+	REQUEST_CATALOGUE byte = 0
+	REQUEST_SIGN      byte = 1
+)
 
-	suite := edwards25519.NewBlakeSHA256Ed25519()
+// Largest catalogue we accept from a server.
+const maxCatalogueSize = 1 << 20
 
-	fmt.Println("CLIENT", "Connecting to", hostspec)
+/* Fetches the server's denomination catalogue and checks it is signed by
+   the server key, so that the info we blind against is the bank's. */
+func fetchCatalogue(hostspec string,
+	pubKey schnorrgs.SchnorrPublicKV) (*schnorrgs.SchnorrDenominationCatalogue,
+	error) {
 
-	pubKey, err := schnorrgs.SchnorrLoadPubkey(kfilepath)
+	suite, err := schnorrgs.GetSuite(pubKey.Suite())
 	if err != nil {
-		fmt.Println("CLIENT", "Error loading public key"+err.Error())
-		return
+		return nil, err
 	}
+	conn, err := net.Dial("tcp", hostspec)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(protocolTimeout))
 
-	info, err := LoadInfo(kinfopath)
+	_, err = conn.Write([]byte{REQUEST_CATALOGUE})
 	if err != nil {
-		fmt.Println("CLIENT", "Error loading info"+err.Error())
-		return
+		return nil, err
+	}
+	err = readStatus(conn)
+	if err != nil {
+		return nil, err
+	}
+	size := make([]byte, 4)
+	_, err = io.ReadFull(conn, size)
+	if err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size)
+	if n > maxCatalogueSize {
+		return nil, errors.New("Catalogue of " + strconv.Itoa(int(n)) +
+			" bytes is too large")
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(conn, b)
+	if err != nil {
+		return nil, err
 	}
 
-	message := make([]byte, 1024)
-	_, err = rand.Read(message)
+	catalogue, err := schnorrgs.NewSchnorrDenominationCatalogueFromJSON(b)
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		return nil, err
 	}
+	err = catalogue.Verify(suite, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return catalogue, nil
+}
+
+/* Connects to a server and asks for a signature on a coin of the given
+   denomination. Returns the connection, the session the server opened
+   and its parameters, two points. */
+func requestSignature(suite schnorrgs.CryptoSuite, hostspec string,
+	denominationID string) (net.Conn, []byte, schnorrgs.WISchnorrPublicParams,
+	error) {
 
 	conn, err := net.Dial("tcp", hostspec)
 	if err != nil {
-		fmt.Println("CLIENT", "Error connecting to server", err.Error())
-		return
+		return nil, nil, schnorrgs.WISchnorrPublicParams{},
+			errors.New("connecting to server " + err.Error())
 	}
-	defer conn.Close()
-
-	// first up, let's receive the signer's parameter set
+	conn.SetDeadline(time.Now().Add(protocolTimeout))
 
-	buffer := make([]byte, 1028)
-	_, err = conn.Read(buffer)
+	request := append([]byte{REQUEST_SIGN, byte(len(denominationID))},
+		[]byte(denominationID)...)
+	_, err = conn.Write(request)
+	if err == nil {
+		err = readStatus(conn)
+	}
+	session := make([]byte, sessionIDSize)
+	buffer := make([]byte, 2*suite.Point().MarshalSize())
+	if err == nil {
+		_, err = io.ReadFull(conn, session)
+	}
+	if err == nil {
+		_, err = io.ReadFull(conn, buffer)
+	}
+	var params schnorrgs.WISchnorrPublicParams
+	if err == nil {
+		err = params.UnmarshalBinary(suite, buffer)
+	}
 	if err != nil {
-		fmt.Println("CLIENT", "Error reading from server", err.Error())
-		return
+		conn.Close()
+		return nil, nil, schnorrgs.WISchnorrPublicParams{},
+			errors.New(hostspec + ": " + err.Error())
 	}
+	return conn, session, params, nil
+}
+
+/* Runs the protocol with a single server, which signs under pubKey. */
+func signSingle(suite schnorrgs.CryptoSuite, pubKey schnorrgs.SchnorrPublicKV,
+	hostspec string, denominationID string, info []byte,
+	message []byte) (schnorrgs.WIBlindSignature, error) {
 
-	var userPublicParams schnorrgs.WISchnorrPublicParams
-	userPublicParams.UnmarshalBinary(buffer)
+	fmt.Println("CLIENT", "Connecting to", hostspec)
+	conn, session, userPublicParams, err := requestSignature(suite, hostspec,
+		denominationID)
+	if err != nil {
+		return schnorrgs.WIBlindSignature{}, err
+	}
+	defer conn.Close()
 
 	// now we've got that, complete the challenge phase (i.e. let's generate E)
 	challenge, userPrivateParams, err := schnorrgs.ClientGenerateChallenge(suite, userPublicParams, pubKey, info, message)
 	if err != nil {
-		fmt.Println("CLIENT", "Error generating challenge", err.Error())
-		return
+		return schnorrgs.WIBlindSignature{}, err
 	}
 
 	// encode and send to server.
-	challengebuffer := bytes.Buffer{}
-	challenge.MarshalTo(challengebuffer)
-	conn.Write(challengebuffer.Bytes())
+	challengebuffer, err := challenge.MarshalBinary()
+	if err != nil {
+		return schnorrgs.WIBlindSignature{}, err
+	}
+	_, err = conn.Write(append(session, challengebuffer...))
+	if err != nil {
+		return schnorrgs.WIBlindSignature{}, err
+	}
 
-	// and now we wait for the server to respond to this:
-	secondread := make([]byte, 1024)
-	_, err = conn.Read(secondread)
+	// and now we wait for the server to respond to this: four scalars.
+	err = readStatus(conn)
 	if err != nil {
-		fmt.Println("CLIENT", "Error reading from server", err.Error())
-		return
+		return schnorrgs.WIBlindSignature{}, err
+	}
+	secondread := make([]byte, 4*suite.Scalar().MarshalSize())
+	_, err = io.ReadFull(conn, secondread)
+	if err != nil {
+		return schnorrgs.WIBlindSignature{}, err
 	}
 
 	var responseMessage schnorrgs.WISchnorrResponseMessage
-	decodeBuffer = bytes.NewBuffer(secondread)
-	err = abstract.Read(decodeBuffer, &responseMessage, suite)
+	err = responseMessage.UnmarshalBinary(suite, secondread)
 	if err != nil {
-		fmt.Println("CLIENT", "Error reading response", err.Error())
+		return schnorrgs.WIBlindSignature{}, err
+	}
+
+	// we've got the response message, time to sign.
+	sig, worked := schnorrgs.ClientSignBlindly(suite, userPrivateParams, responseMessage, pubKey, message)
+	if worked != true {
+		return schnorrgs.WIBlindSignature{}, errors.New("preforming blind signature")
+	}
+	return sig, nil
+}
+
+/* Runs through the "user" side of the protocol i.e. the party
+   requesting a partially blind signature */
+func main() {
+
+	kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	var kfilepath string = *appPublickeyfile
+	var denominationID string = *appDenomination
+	var hostspec string = *appHostspec
+
+	if len(denominationID) > 255 {
+		fmt.Println("CLIENT", "Error denomination ID longer than 255 bytes")
 		return
 	}
 
-	// we've got the response message, time to sign and check.
+	// a group signs under its joint key, which is all the coin shows.
+	var group *signingGroup
+	var pubKey *schnorrgs.SchnorrPublicKV
+	var err error
+	if *appGroup {
+		group, err = loadSigningGroup(kfilepath)
+		if err == nil {
+			pubKey = &group.jointKey
+		}
+	} else if hostspec == "" {
+		err = errors.New("need the host to connect to")
+	} else {
+		pubKey, err = schnorrgs.SchnorrLoadPubkey(kfilepath)
+		if err == nil {
+			err = pubKey.CheckUsage(schnorrgs.UsageBlind)
+		}
+	}
+	if err != nil {
+		fmt.Println("CLIENT", "Error loading key "+err.Error())
+		return
+	}
+	suite, err := schnorrgs.GetSuite(pubKey.Suite())
+	if err != nil {
+		fmt.Println("CLIENT", "Error "+err.Error())
+		return
+	}
 
-	// finally, we can sign the message and check it verifies.
-	sig, worked := schnorrgs.ClientSignBlindly(suite, userPrivateParams, responseMessage, pubKey, message)
+	var denomination schnorrgs.SchnorrDenomination
+	if group != nil {
+		denomination, err = group.lookupDenomination(denominationID)
+	} else {
+		var catalogue *schnorrgs.SchnorrDenominationCatalogue
+		catalogue, err = fetchCatalogue(hostspec, *pubKey)
+		if err == nil {
+			denomination, err = catalogue.Lookup(denominationID, time.Now())
+		}
+	}
+	if err != nil {
+		fmt.Println("CLIENT", "Error denomination "+denominationID, err.Error())
+		return
+	}
+	info, err := denomination.GetInfo()
+	if err != nil {
+		fmt.Println("CLIENT", "Error "+err.Error())
+		return
+	}
+	fmt.Println("CLIENT", "Denomination", denominationID, "face value",
+		denomination.FaceValue, "info", denomination.Info)
 
-	//fmt.Println(blindSignature)
+	message := make([]byte, 1024)
+	_, err = rand.Read(message)
+	if err != nil {
+		fmt.Println(err.Error())
+		return
+	}
 
-	if worked != true {
-		fmt.Println("CLIENT", "Error preforming blind signature")
+	var sig schnorrgs.WIBlindSignature
+	if group != nil {
+		sig, err = signMulti(suite, *group, denominationID, info, message)
+	} else {
+		sig, err = signSingle(suite, *pubKey, hostspec, denominationID, info,
+			message)
+	}
+	if err != nil {
+		fmt.Println("CLIENT", "Error", err.Error())
 		return
 	}
 
 	// now verify this worked fine.
-	result, err := schnorrgs.VerifyBlindSignature(suite, pubKey, sig, info, message)
+	result, err := schnorrgs.VerifyBlindSignature(suite, *pubKey, sig, info, message)
 
 	if err != nil {
 		fmt.Println("CLIENT", "Error handling signature verification", err.Error())
@@ -135,7 +311,25 @@ func main() {
 		return
 	}
 
-	fmt.Println("CLIENT", "Signature OK -", sig)
+	encoded, err := sig.MarshalBinary()
+	if err != nil {
+		fmt.Println("CLIENT", "Error encoding signature", err.Error())
+		return
+	}
+	fmt.Println("CLIENT", "Signature OK -", hex.EncodeToString(encoded))
 
-	return
+	if *appCoinfile == "" {
+		return
+	}
+	coin := schnorrgs.WIBlindCoin{Info: info, Message: message, Signature: sig}
+	encoded, err = coin.MarshalBinary()
+	if err == nil {
+		err = ioutil.WriteFile(*appCoinfile, encoded, 0600)
+	}
+	if err != nil {
+		fmt.Println("CLIENT", "Error writing coin", err.Error())
+		return
+	}
+	fmt.Println("CLIENT", "Coin", hex.EncodeToString(coin.Serial()),
+		"written to", *appCoinfile)
 }
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"errors"
+	"github.com/dedis/kyber"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+/* With --group the client asks every member of a group of servers for
+   its part of the signature, and combines them into one signature under
+   the group's joint key; see the server's multi.go for the messages.
+   Every member has to take part, so no single server can issue a coin. */
+
+// One member of the group: where to reach it and its key.
+type groupMember struct {
+	hostspec string
+	key      schnorrgs.SchnorrPublicKV
+}
+
+// A group of signers, in group order, and their joint key.
+type signingGroup struct {
+	jointKey schnorrgs.SchnorrPublicKV
+	members  []groupMember
+}
+
+/* Loads the group configuration at path and checks its joint key really
+   is the aggregate of the member keys, each of which is for blind
+   signing. */
+func loadSigningGroup(path string) (*signingGroup, error) {
+	config, err := schnorrgs.SchnorrLoadGroupConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	err = config.VerifyBlindSigners()
+	if err != nil {
+		return nil, err
+	}
+	joint, err := config.GetJointKeyAsKV()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := config.GetMemberKeys()
+	if err != nil {
+		return nil, err
+	}
+	group := &signingGroup{jointKey: *joint}
+	for i, k := range keys {
+		err = k.CheckUsage(schnorrgs.UsageBlind)
+		if err != nil {
+			return nil, err
+		}
+		member := config.Members[i]
+		group.members = append(group.members, groupMember{
+			hostspec: net.JoinHostPort(member.HostName,
+				strconv.Itoa(member.Port)),
+			key: k})
+	}
+	return group, nil
+}
+
+func (g signingGroup) keys() []schnorrgs.SchnorrPublicKV {
+	var keys []schnorrgs.SchnorrPublicKV
+	for _, m := range g.members {
+		keys = append(keys, m.key)
+	}
+	return keys
+}
+
+/* Looks the denomination up in the catalogue of every member, each signed
+   by that member's key. They all have to list it with the same info and
+   face value, so that no member can have us blind against info the
+   others did not agree to. */
+func (g signingGroup) lookupDenomination(
+	id string) (schnorrgs.SchnorrDenomination, error) {
+
+	var agreed schnorrgs.SchnorrDenomination
+	for i, m := range g.members {
+		catalogue, err := fetchCatalogue(m.hostspec, m.key)
+		if err != nil {
+			return agreed, errors.New(m.hostspec + ": " + err.Error())
+		}
+		d, err := catalogue.Lookup(id, time.Now())
+		if err != nil {
+			return agreed, errors.New(m.hostspec + ": " + err.Error())
+		}
+		if i == 0 {
+			agreed = d
+		} else if d.Info != agreed.Info || d.FaceValue != agreed.FaceValue {
+			return agreed, errors.New(m.hostspec + " does not agree on " +
+				"the denomination")
+		}
+	}
+	return agreed, nil
+}
+
+/* Reads an OK reply of size bytes from every connection. */
+func readReplies(conns []net.Conn, hostspecs []string,
+	size int) ([][]byte, error) {
+
+	var replies [][]byte
+	for i, conn := range conns {
+		reply := make([]byte, size)
+		err := readStatus(conn)
+		if err == nil {
+			_, err = io.ReadFull(conn, reply)
+		}
+		if err != nil {
+			return nil, errors.New(hostspecs[i] + ": " + err.Error())
+		}
+		replies = append(replies, reply)
+	}
+	return replies, nil
+}
+
+/* Runs the protocol with every member of the group at once, which sign
+   together under the joint key. */
+func signMulti(suite schnorrgs.CryptoSuite, group signingGroup,
+	denominationID string, info []byte,
+	message []byte) (schnorrgs.WIBlindSignature, error) {
+
+	var conns []net.Conn
+	var hostspecs []string
+	var sessions [][]byte
+	var commitments []schnorrgs.WISchnorrPublicParams
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for _, m := range group.members {
+		hostspecs = append(hostspecs, m.hostspec)
+		conn, session, params, err := requestSignature(suite, m.hostspec,
+			denominationID)
+		if err != nil {
+			return schnorrgs.WIBlindSignature{}, err
+		}
+		conns = append(conns, conn)
+		sessions = append(sessions, session)
+		commitments = append(commitments, params)
+	}
+
+	// we blind the sum of the parameters, as if one server had sent it.
+	joint := schnorrgs.ClientAggregatePublicParams(suite, commitments)
+	challenge, userPrivateParams, err := schnorrgs.ClientGenerateChallenge(suite,
+		joint, group.jointKey, info, message)
+	if err != nil {
+		return schnorrgs.WIBlindSignature{}, err
+	}
+
+	// every member gets e and everybody's parameters.
+	msg, err := challenge.MarshalBinary()
+	if err != nil {
+		return schnorrgs.WIBlindSignature{}, err
+	}
+	for _, c := range commitments {
+		b, err := c.MarshalBinary()
+		if err != nil {
+			return schnorrgs.WIBlindSignature{}, err
+		}
+		msg = append(msg, b...)
+	}
+	for i, conn := range conns {
+		_, err = conn.Write(append(append([]byte{}, sessions[i]...), msg...))
+		if err != nil {
+			return schnorrgs.WIBlindSignature{}, err
+		}
+	}
+
+	// then everybody's openings.
+	scalarSize := suite.Scalar().MarshalSize()
+	replies, err := readReplies(conns, hostspecs, 2*scalarSize)
+	if err != nil {
+		return schnorrgs.WIBlindSignature{}, err
+	}
+	var openings []schnorrgs.WISchnorrBlindingOpening
+	msg = nil
+	for i, reply := range replies {
+		var o schnorrgs.WISchnorrBlindingOpening
+		err = o.UnmarshalBinary(suite, reply)
+		if err != nil {
+			return schnorrgs.WIBlindSignature{}, errors.New(hostspecs[i] +
+				": " + err.Error())
+		}
+		openings = append(openings, o)
+		msg = append(msg, reply...)
+	}
+	for _, conn := range conns {
+		_, err = conn.Write(msg)
+		if err != nil {
+			return schnorrgs.WIBlindSignature{}, err
+		}
+	}
+
+	replies, err = readReplies(conns, hostspecs, scalarSize)
+	if err != nil {
+		return schnorrgs.WIBlindSignature{}, err
+	}
+	var responses []kyber.Scalar
+	for i, reply := range replies {
+		r := suite.Scalar()
+		err = r.UnmarshalBinary(reply)
+		if err != nil {
+			return schnorrgs.WIBlindSignature{}, errors.New(hostspecs[i] +
+				": " + err.Error())
+		}
+		responses = append(responses, r)
+	}
+
+	// a member that answered wrongly is named here.
+	response, err := schnorrgs.ClientCombineResponses(suite, group.keys(),
+		userPrivateParams, challenge, commitments, openings, responses)
+	if err != nil {
+		return schnorrgs.WIBlindSignature{}, err
+	}
+	sig, worked := schnorrgs.ClientSignBlindly(suite, userPrivateParams,
+		response, group.jointKey, message)
+	if !worked {
+		return schnorrgs.WIBlindSignature{}, errors.New("performing blind " +
+			"signature")
+	}
+	return sig, nil
+}
@@ -2,12 +2,12 @@ package main
 
 import (
 	"fmt"
-	"github.com/dedis/kyber/group/edwards25519"
 	"github.com/diagprov/dedischallenge/schnorrgs"
+	"golang.org/x/net/context"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
-	"io/ioutil"
 	"net"
 	"os"
+	"os/signal"
 )
 
 /* These variables form the command line parameters of the keytool utility.
@@ -18,18 +18,14 @@ import (
 var (
 	app               = kingpin.New("sigserv3", "Blind signature server - signs (partially blindly) a message provided by sigcli3")
 	appPrivatekeyfile = app.Arg("privatekey", "Path to schnorr private key").Required().String()
-	appInfo           = app.Arg("info", "Output file path to write (appends .pub, .pri)").Required().String()
-	appPort           = app.Arg("port", "Listen on port").Int()
+	appCatalogue      = app.Arg("catalogue", "Path to the denomination catalogue, as written by keytool mkcatalogue").Required().String()
+	appPort           = app.Arg("port", "Listen on port").Default("1113").Int()
+	appMaxSessions    = app.Flag("max-sessions", "Most signing sessions to have open at once; clients beyond it are told to retry").Default("16").Int()
+	appSessionTimeout = app.Flag("session-timeout", "How long a client has to answer before its session expires").Default("30s").Duration()
+	appGroup          = app.Flag("group", "Sign as a member of this group configuration; every member then has to take part in each signature").String()
+	appPassfile       = app.Flag("passfile", "Read the passphrase of an encrypted keyfile from this file instead of $"+schnorrgs.PassphraseEnvVar+" or the terminal").String()
 )
 
-func LoadInfo(path string) ([]byte, error) {
-	fcontents, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-	return fcontents, nil
-}
-
 /* runs through the process of setting up the server as specified in the args */
 func main() {
 
@@ -37,29 +33,92 @@ func main() {
 
 	var port int = *appPort
 	var kfilepath string = *appPrivatekeyfile
-	var kinfopath string = *appInfo
+	var cpath string = *appCatalogue
 
 	fmt.Printf("Sigserv3 - listening on port %d.\n", port)
 
-	suite := edwards25519.NewBlakeSHA256Ed25519()
-	kv, err := schnorrgs.SchnorrLoadKeypair(kfilepath, suite)
+	kv, encrypted, err := schnorrgs.SchnorrLoadSecretKVWithPassphrase(kfilepath,
+		func() ([]byte, error) {
+			return schnorrgs.SchnorrReadPassphrase(*appPassfile,
+				"Passphrase for "+kfilepath+": ")
+		})
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	if !encrypted {
+		fmt.Println("Warning: keyfile " + kfilepath + " is not encrypted")
+	}
+	err = kv.CheckUsage(schnorrgs.UsageBlind)
 	if err != nil {
 		fmt.Println("Error " + err.Error())
 		return
 	}
 
-	info, err := LoadInfo(kinfopath)
+	// the key file tells us which suite we are working in.
+	suite, err := schnorrgs.GetSuite(kv.Suite())
 	if err != nil {
 		fmt.Println("Error " + err.Error())
 		return
 	}
 
+	// we only serve a catalogue made for our own key.
+	catalogue, err := schnorrgs.SchnorrLoadDenominationCatalogue(cpath)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	err = catalogue.Verify(suite, kv.GetPublicKeyset())
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	for _, d := range catalogue.Denominations {
+		fmt.Println("Denomination", d.ID, "face value", d.FaceValue)
+	}
+
+	// a group member only signs along with the rest of its group.
+	var group *signingGroup
+	if *appGroup != "" {
+		group, err = loadSigningGroup(*appGroup, *kv)
+		if err != nil {
+			fmt.Println("Error " + err.Error())
+			return
+		}
+		fmt.Println("Signing as one of", len(group.keys), "group members")
+	}
+
+	if *appMaxSessions < 1 || *appSessionTimeout <= 0 {
+		fmt.Println("Error need at least one session and a positive session timeout")
+		return
+	}
+	sessions := newSessionManager(*appMaxSessions, *appSessionTimeout)
+
 	// I don't know if there's a way to
 	// do std::bind-like behaviour in GO.
 	// for C++ what I'd do is pretty simple:
 	// newfunc := std::bind(&func, args to bind)
 	var signBlindImpl connectionhandler = func(conn net.Conn) {
-		signBlindlySchnorr(conn, suite, kv, info)
+		handleClient(conn, suite, *kv, *catalogue, group, sessions)
 	}
-	serve(port, signBlindImpl)
+
+	exitCh := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt)
+
+	go func() {
+		select {
+		case <-signalCh:
+			cancel()
+			return
+		}
+	}()
+
+	go serve(port, signBlindImpl, ctx, exitCh)
+
+	// delay main thread until worker has returned.
+	<-exitCh
+	fmt.Println("Exiting server now.")
 }
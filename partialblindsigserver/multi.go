@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/dedis/kyber"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"io"
+	"net"
+)
+
+/* A server started with a group configuration is one of several signers
+   that all have to take part in every signature, so that no single server
+   can issue coins on its own. The client runs the protocol with every
+   member at once, over one connection to each; see partialBlindMulti.go
+   for the rounds. After the request the messages are, with n members:
+
+       server: OK||id||a_i||b_i
+       client: id||e||a_1||b_1||...||a_n||b_n
+       server: OK||s_i||d_i
+       client: s_1||d_1||...||s_n||d_n
+       server: OK||r_i
+
+   The session stays open across the rounds and is closed with the
+   connection. */
+
+// The group a server signs in: the member keys, in group order.
+type signingGroup struct {
+	keys []schnorrgs.SchnorrPublicKV
+}
+
+/* Loads the group configuration at path and checks the server key kv is
+   a member of a group that can sign partially blindly. */
+func loadSigningGroup(path string, kv schnorrgs.SchnorrSecretKV) (*signingGroup,
+	error) {
+
+	config, err := schnorrgs.SchnorrLoadGroupConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	err = config.VerifyBlindSigners()
+	if err != nil {
+		return nil, err
+	}
+	_, err = config.MemberIndex(kv)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := config.GetMemberKeys()
+	if err != nil {
+		return nil, err
+	}
+	return &signingGroup{keys: keys}, nil
+}
+
+/* This function implements our part of the signer protocol for a coin
+   signed with sharedinfo by the whole group, once the client has asked
+   for one. */
+func signBlindlyMulti(conn net.Conn,
+	suite schnorrgs.CryptoSuite,
+	kv schnorrgs.SchnorrSecretKV,
+	group *signingGroup,
+	sharedinfo []byte,
+	sessions *sessionManager) {
+
+	id, userPublicParams, expires, err := sessions.OpenMulti(suite, kv,
+		group.keys, sharedinfo)
+	if err == errTooManySessions {
+		refuseBusy(conn, sessions)
+		return
+	}
+	if err != nil {
+		fmt.Println("SERVER", "Error creating new multisignature session", err.Error())
+		conn.Write([]byte{STATUS_ERROR})
+		return
+	}
+	defer sessions.Close(id)
+	conn.SetDeadline(expires)
+
+	fmt.Println("SERVER", "Group session", hex.EncodeToString(id), "opened,",
+		sessions.Len(), "open")
+
+	b, err := userPublicParams.MarshalBinary()
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		return
+	}
+	_, err = conn.Write(append(append([]byte{STATUS_OK}, id...), b...))
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		return
+	}
+
+	// the challenge comes with every member's parameters.
+	n := len(group.keys)
+	pointSize := suite.Point().MarshalSize()
+	scalarSize := suite.Scalar().MarshalSize()
+	data := make([]byte, sessionIDSize+scalarSize+n*2*pointSize)
+	_, err = io.ReadFull(conn, data)
+	if err != nil {
+		fmt.Println("SERVER", "Error reading challenge", err.Error())
+		return
+	}
+	var challenge schnorrgs.WISchnorrChallengeMessage
+	err = challenge.UnmarshalBinary(suite,
+		data[sessionIDSize:sessionIDSize+scalarSize])
+	if err == nil && !bytes.Equal(data[:sessionIDSize], id) {
+		err = errUnknownSession
+	}
+	commitments := make([]schnorrgs.WISchnorrPublicParams, n)
+	for i := 0; err == nil && i < n; i++ {
+		start := sessionIDSize + scalarSize + i*2*pointSize
+		err = commitments[i].UnmarshalBinary(suite,
+			data[start:start+2*pointSize])
+	}
+	var session *schnorrgs.WISchnorrMultiSignerSession
+	if err == nil {
+		session, err = sessions.Multi(id)
+	}
+	var opening schnorrgs.WISchnorrBlindingOpening
+	if err == nil {
+		opening, err = session.Challenge(challenge, commitments)
+	}
+	if err == nil {
+		b, err = opening.MarshalBinary()
+	}
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		conn.Write([]byte{STATUS_ERROR})
+		return
+	}
+	_, err = conn.Write(append([]byte{STATUS_OK}, b...))
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		return
+	}
+
+	// every member's opening, which we check before answering.
+	data = make([]byte, n*2*scalarSize)
+	_, err = io.ReadFull(conn, data)
+	if err != nil {
+		fmt.Println("SERVER", "Error reading openings", err.Error())
+		return
+	}
+	openings := make([]schnorrgs.WISchnorrBlindingOpening, n)
+	for i := 0; err == nil && i < n; i++ {
+		err = openings[i].UnmarshalBinary(suite,
+			data[i*2*scalarSize:(i+1)*2*scalarSize])
+	}
+	// the session may have expired while we waited.
+	if err == nil {
+		_, err = sessions.Multi(id)
+	}
+	var r kyber.Scalar
+	if err == nil {
+		r, err = session.Respond(openings)
+	}
+	if err == nil {
+		b, err = r.MarshalBinary()
+	}
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		conn.Write([]byte{STATUS_ERROR})
+		return
+	}
+	_, err = conn.Write(append([]byte{STATUS_OK}, b...))
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		return
+	}
+
+	fmt.Println("SERVER", "Group session", hex.EncodeToString(id), "answered")
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
-	"github.com/dedis/kyber"
 	"github.com/diagprov/dedischallenge/schnorrgs"
 	"golang.org/x/net/context"
 	"io"
@@ -14,85 +15,200 @@ import (
 // type alias for handling connections.
 type connectionhandler func(conn net.Conn)
 
-/* This function implements the signer protocol from the blind signature paper
-   and can be bound via closure given a specific set of parameters and
-   send to the serve() function
-   This is not the best accept() handler ever written,  but it's better than the client side code */
-func signBlindlySchnorr(conn net.Conn,
-	suite abstract.Suite,
-	kv schnorrgs.SchnorrKeyset,
-	sharedinfo []byte) {
+// Status bytes the server starts its replies with. A busy server follows
+// the status with the number of seconds to wait, as a uint16.
+const (
+	STATUS_OK    byte = 0
+	STATUS_BUSY  byte = 1
+	STATUS_ERROR byte = 2
+)
+
+// Requests a client starts with: the catalogue, or a signature on a coin
+// of the denomination whose ID follows, as len(id) || id.
+const (
+	REQUEST_CATALOGUE byte = 0
+	REQUEST_SIGN      byte = 1
+)
+
+// How long a client has to send its request.
+const requestTimeout = 30 * time.Second
+
+/* Reads the request a client starts with. For a signature it also reads
+   the denomination ID. */
+func readRequest(conn net.Conn) (byte, string, error) {
+	request := make([]byte, 2)
+	_, err := io.ReadFull(conn, request[:1])
+	if err != nil || request[0] != REQUEST_SIGN {
+		return request[0], "", err
+	}
+	_, err = io.ReadFull(conn, request[1:])
+	if err != nil {
+		return 0, "", err
+	}
+	id := make([]byte, request[1])
+	_, err = io.ReadFull(conn, id)
+	if err != nil {
+		return 0, "", err
+	}
+	return REQUEST_SIGN, string(id), nil
+}
+
+/* This function answers a client, which either fetches the denomination
+   catalogue, sent as OK||len||catalogue with a uint32 length, or asks
+   for a signature, which a member of a group makes with the others. It
+   can be bound via closure given a specific set of parameters and send
+   to the serve() function. */
+func handleClient(conn net.Conn,
+	suite schnorrgs.CryptoSuite,
+	kv schnorrgs.SchnorrSecretKV,
+	catalogue schnorrgs.SchnorrDenominationCatalogue,
+	group *signingGroup,
+	sessions *sessionManager) {
 	defer conn.Close()
 
-	fmt.Println("SERVER", "Sending initial parameters")
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+	request, id, err := readRequest(conn)
+	if err != nil {
+		fmt.Println("SERVER", "Error reading request", err.Error())
+		return
+	}
 
-	signerParams, err := schnorrgs.NewPrivateParams(suite, sharedinfo)
+	switch request {
+	case REQUEST_CATALOGUE:
+		b, err := catalogue.Export()
+		if err != nil {
+			fmt.Println("SERVER", "Error", err.Error())
+			conn.Write([]byte{STATUS_ERROR})
+			return
+		}
+		reply := []byte{STATUS_OK, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(reply[1:], uint32(len(b)))
+		_, err = conn.Write(append(reply, b...))
+		if err != nil {
+			fmt.Println("SERVER", "Error", err.Error())
+			return
+		}
+		fmt.Println("SERVER", "Sent catalogue")
+	case REQUEST_SIGN:
+		denomination, err := catalogue.Lookup(id, time.Now())
+		var info []byte
+		if err == nil {
+			info, err = denomination.GetInfo()
+		}
+		if err != nil {
+			fmt.Println("SERVER", "Refusing denomination", id, err.Error())
+			conn.Write([]byte{STATUS_ERROR})
+			return
+		}
+		fmt.Println("SERVER", "Client asks for denomination", id)
+		if group != nil {
+			signBlindlyMulti(conn, suite, kv, group, info, sessions)
+			return
+		}
+		signBlindlySchnorr(conn, suite, kv, info, sessions)
+	default:
+		fmt.Println("SERVER", "Unknown request", request)
+		conn.Write([]byte{STATUS_ERROR})
+	}
+}
+
+/* Tells a client we are too busy, and when to try again. */
+func refuseBusy(conn net.Conn, sessions *sessionManager) {
+	wait := uint16((sessions.RetryAfter() + time.Second - 1) / time.Second)
+	fmt.Println("SERVER", "Too many sessions, client told to retry in",
+		wait, "seconds")
+	reply := []byte{STATUS_BUSY, 0, 0}
+	binary.BigEndian.PutUint16(reply[1:], wait)
+	conn.Write(reply)
+}
+
+/* This function implements the signer protocol from the blind signature paper
+   for a coin signed with sharedinfo, once the client has asked for one.
+   The messages have fixed sizes in a given suite: we open a session and
+   send OK||id||a||b, read id||e and answer OK||r||c||s||d. The session's
+   parameters are destroyed once they are used, or when the client takes
+   longer than the session lifetime. */
+func signBlindlySchnorr(conn net.Conn,
+	suite schnorrgs.CryptoSuite,
+	kv schnorrgs.SchnorrSecretKV,
+	sharedinfo []byte,
+	sessions *sessionManager) {
+
+	id, userPublicParams, expires, err := sessions.Open(suite, sharedinfo)
+	if err == errTooManySessions {
+		refuseBusy(conn, sessions)
+		return
+	}
 	if err != nil {
 		fmt.Println("SERVER", "Error creating new private parameters", err.Error())
+		conn.Write([]byte{STATUS_ERROR})
 		return
 	}
+	// a session the client walks away from must not outlive the connection.
+	defer sessions.Close(id)
+	conn.SetDeadline(expires)
+
+	fmt.Println("SERVER", "Session", hex.EncodeToString(id), "opened,",
+		sessions.Len(), "open")
 
 	// "send" these to the user.
-	userPublicParams := signerParams.DerivePubParams()
-	buffer := bytes.Buffer{}
-	abstract.Write(&buffer, &userPublicParams, suite)
-	conn.Write(buffer.Bytes())
+	b, err := userPublicParams.MarshalBinary()
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		return
+	}
+	_, err = conn.Write(append(append([]byte{STATUS_OK}, id...), b...))
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		return
+	}
 
 	// now we need to wait for the client to send us "e"
-	ch := make(chan []byte)
-	errorCh := make(chan error)
-
-	// this neat little routine for wrapping read connections
-	// in a class unashamedly stolen from stackoverflow:
-	// http://stackoverflow.com/a/9764191
-	go func(ch chan []byte, eCh chan error) {
-		for {
-			// try to read the data
-			fmt.Println("SERVER", "Read goroutine off and going")
-			buffer := make([]byte, 1026)
-			_, err := conn.Read(buffer)
-			if err != nil {
-				// send an error if it's encountered
-				errorCh <- err
-				return
-			}
-			// send data if we read some.
-			ch <- buffer
-		}
-	}(ch, errorCh)
-
-	for {
-		select {
-		case data := <-ch:
-			fmt.Println("SERVER", "Received Message")
-
-			var challenge schnorrgs.WISchnorrChallengeMessage
-			buffer := bytes.NewBuffer(data)
-			err = abstract.Read(buffer, &challenge, suite)
-			if err != nil {
-				fmt.Println("SERVER", "Error", err.Error())
-				return
-			}
-
-			response := schnorrgs.ServerGenerateResponse(suite, challenge, signerParams, kv)
-			respbuffer := bytes.Buffer{}
-			abstract.Write(&respbuffer, &response, suite)
-			conn.Write(respbuffer.Bytes())
+	data := make([]byte, sessionIDSize+suite.Scalar().MarshalSize())
+	_, err = io.ReadFull(conn, data)
+	if err != nil {
+		fmt.Println("SERVER", "Error reading challenge", err.Error())
+		return
+	}
+	fmt.Println("SERVER", "Received Message")
 
-			fmt.Println("SERVER", "We're done")
-			return
+	var challenge schnorrgs.WISchnorrChallengeMessage
+	err = challenge.UnmarshalBinary(suite, data[sessionIDSize:])
+	if err == nil && !bytes.Equal(data[:sessionIDSize], id) {
+		err = errUnknownSession
+	}
+	var signerParams schnorrgs.WISchnorrBlindPrivateParams
+	if err == nil {
+		signerParams, err = sessions.Take(id)
+	}
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		conn.Write([]byte{STATUS_ERROR})
+		return
+	}
 
-		case err := <-errorCh:
-			if err == io.EOF {
-				return
-			}
-			// we should, really, log instead.
-			fmt.Println("Encountered error serving client")
-			fmt.Println(err.Error())
-			break
-		}
+	response, err := schnorrgs.ServerGenerateResponse(suite, challenge,
+		signerParams, kv)
+	if err != nil {
+		destroyParams(signerParams)
+		fmt.Println("SERVER", "Error", err.Error())
+		conn.Write([]byte{STATUS_ERROR})
+		return
+	}
+	// the response shares sigma and delta with the parameters.
+	b, err = response.MarshalBinary()
+	destroyParams(signerParams)
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		return
+	}
+	_, err = conn.Write(append([]byte{STATUS_OK}, b...))
+	if err != nil {
+		fmt.Println("SERVER", "Error", err.Error())
+		return
 	}
 
+	fmt.Println("SERVER", "Session", hex.EncodeToString(id), "answered")
 }
 
 /* The serve function is designed to serve an arbitrary connection handler
@@ -103,6 +219,7 @@ func serve(port int, handler connectionhandler, ctx context.Context, exitCh chan
 
 	if port < 1024 || port > 65535 {
 		// todo: how does go handle errors.
+		fmt.Println("Error port must be between 1024 and 65535")
 		exitCh <- struct{}{}
 		return
 	}
@@ -110,14 +227,14 @@ func serve(port int, handler connectionhandler, ctx context.Context, exitCh chan
 	portspec := fmt.Sprintf("0.0.0.0:%d", port)
 	addr, err := net.ResolveTCPAddr("tcp", portspec)
 	if err != nil {
-		fmt.Printf("%d", err)
+		fmt.Println(err.Error())
 		exitCh <- struct{}{}
 		return
 	}
 	sock, err := net.ListenTCP("tcp", addr)
 	if err != nil {
 		// error
-		fmt.Printf("%d", err)
+		fmt.Println(err.Error())
 		exitCh <- struct{}{}
 		return
 	}
@@ -125,14 +242,17 @@ func serve(port int, handler connectionhandler, ctx context.Context, exitCh chan
 	// an alternative would be for accept to dispatch as needed
 	// via a select / goroutines and then
 	// each handler function could check whether it should handle or exit.
-	sock.SetDeadline(time.Now().Add(5 * time.Second))
 	for {
+		sock.SetDeadline(time.Now().Add(5 * time.Second))
 		conn, err := sock.Accept()
 		if err != nil {
-			fmt.Printf("%d", err)
+			if e, ok := err.(net.Error); !ok || !e.Timeout() {
+				fmt.Println(err.Error())
+				exitCh <- struct{}{}
+				return
+			}
 		} else {
 			go handler(conn)
-			time.Sleep(250 * time.Millisecond)
 		}
 		// check if we need to exit:
 		select {
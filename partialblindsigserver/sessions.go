@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"sync"
+	"time"
+)
+
+/* Abe and Okamoto only prove the scheme secure for a bounded number of
+   signing sessions running at once, so the server keeps track of them.
+   Each session holds the (u, s, d) drawn for it, answers exactly one
+   challenge with them and then forgets them; sessions that are not
+   answered in time expire the same way. Once the limit of open sessions is
+   reached, new clients are told when to try again instead.
+
+   A server that is one of a group of signers keeps a multisignature
+   session instead, which stays open over the rounds of the protocol and
+   is only forgotten once the connection is done with it. */
+
+// Size of a session ID on the wire.
+const sessionIDSize = 16
+
+var (
+	errTooManySessions = errors.New("Too many open signing sessions.")
+	errUnknownSession  = errors.New("Unknown or expired signing session.")
+)
+
+// One signing session: its parameters, or for a group its multisignature
+// session, and when they stop being usable.
+type blindSession struct {
+	params  schnorrgs.WISchnorrBlindPrivateParams
+	multi   *schnorrgs.WISchnorrMultiSignerSession
+	expires time.Time
+}
+
+// Keeps the open sessions. It is safe for concurrent use.
+type sessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*blindSession
+	limit    int
+	lifetime time.Duration
+}
+
+func newSessionManager(limit int, lifetime time.Duration) *sessionManager {
+	return &sessionManager{sessions: make(map[string]*blindSession),
+		limit: limit, lifetime: lifetime}
+}
+
+/* Overwrites the secret scalars of spent or abandoned parameters, so that
+   nothing can answer a second challenge with them. Anything still holding
+   them, such as a response made from them, has to be encoded first. */
+func destroyParams(params schnorrgs.WISchnorrBlindPrivateParams) {
+	params.U.Zero()
+	params.S.Zero()
+	params.D.Zero()
+}
+
+func (s *blindSession) destroy() {
+	if s.multi != nil {
+		s.multi.Destroy()
+		return
+	}
+	destroyParams(s.params)
+}
+
+// Drops expired sessions. The caller holds the lock.
+func (m *sessionManager) sweep(now time.Time) {
+	for id, s := range m.sessions {
+		if now.After(s.expires) {
+			s.destroy()
+			delete(m.sessions, id)
+		}
+	}
+}
+
+/* Adds a session made by create, unless the limit is reached. Returns
+   the session ID, the public parameters to send and when the session
+   expires. */
+func (m *sessionManager) open(create func() (*blindSession,
+	schnorrgs.WISchnorrPublicParams, error)) ([]byte,
+	schnorrgs.WISchnorrPublicParams, time.Time, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+	if len(m.sessions) >= m.limit {
+		return nil, schnorrgs.WISchnorrPublicParams{}, time.Time{},
+			errTooManySessions
+	}
+
+	id := make([]byte, sessionIDSize)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, schnorrgs.WISchnorrPublicParams{}, time.Time{}, err
+	}
+	s, pub, err := create()
+	if err != nil {
+		return nil, schnorrgs.WISchnorrPublicParams{}, time.Time{}, err
+	}
+	s.expires = now.Add(m.lifetime)
+	m.sessions[hex.EncodeToString(id)] = s
+	return id, pub, s.expires, nil
+}
+
+/* Opens a session with fresh parameters for info, unless the limit is
+   reached. Returns the session ID, the public parameters to send and when
+   the session expires. */
+func (m *sessionManager) Open(suite schnorrgs.CryptoSuite,
+	info []byte) ([]byte, schnorrgs.WISchnorrPublicParams, time.Time, error) {
+
+	return m.open(func() (*blindSession, schnorrgs.WISchnorrPublicParams,
+		error) {
+		params, err := schnorrgs.NewPrivateParams(suite, info)
+		if err != nil {
+			return nil, schnorrgs.WISchnorrPublicParams{}, err
+		}
+		return &blindSession{params: params}, params.DerivePubParams(), nil
+	})
+}
+
+/* Opens a multisignature session for info as the signer kv of the group
+   keys, unless the limit is reached. Returns the same as Open. */
+func (m *sessionManager) OpenMulti(suite schnorrgs.CryptoSuite,
+	kv schnorrgs.SchnorrSecretKV, keys []schnorrgs.SchnorrPublicKV,
+	info []byte) ([]byte, schnorrgs.WISchnorrPublicParams, time.Time, error) {
+
+	return m.open(func() (*blindSession, schnorrgs.WISchnorrPublicParams,
+		error) {
+		ss, pub, err := schnorrgs.NewMultiSignerSession(suite, kv, keys, info)
+		if err != nil {
+			return nil, schnorrgs.WISchnorrPublicParams{}, err
+		}
+		return &blindSession{multi: ss}, pub, nil
+	})
+}
+
+/* Returns the multisignature session with the given ID, which stays open
+   until it is closed or expires. Only the connection that opened it may
+   use it. */
+func (m *sessionManager) Multi(id []byte) (*schnorrgs.WISchnorrMultiSignerSession,
+	error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[hex.EncodeToString(id)]
+	if !ok || s.multi == nil || time.Now().After(s.expires) {
+		return nil, errUnknownSession
+	}
+	return s.multi, nil
+}
+
+/* Takes the parameters of a session out for its one response. The session
+   is closed whether or not it is still usable; the caller destroys the
+   parameters once the response is encoded. */
+func (m *sessionManager) Take(id []byte) (schnorrgs.WISchnorrBlindPrivateParams,
+	error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := hex.EncodeToString(id)
+	s, ok := m.sessions[key]
+	if !ok {
+		return schnorrgs.WISchnorrBlindPrivateParams{}, errUnknownSession
+	}
+	delete(m.sessions, key)
+	if s.multi != nil || time.Now().After(s.expires) {
+		s.destroy()
+		return schnorrgs.WISchnorrBlindPrivateParams{}, errUnknownSession
+	}
+	return s.params, nil
+}
+
+// Closes a session without using it, if it is still open.
+func (m *sessionManager) Close(id []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := hex.EncodeToString(id)
+	if s, ok := m.sessions[key]; ok {
+		s.destroy()
+		delete(m.sessions, key)
+	}
+}
+
+// Returns how long until a session frees up at the latest.
+func (m *sessionManager) RetryAfter() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	wait := m.lifetime
+	now := time.Now()
+	for _, s := range m.sessions {
+		if d := s.expires.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
+
+// Returns the number of open sessions.
+func (m *sessionManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
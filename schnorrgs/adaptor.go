@@ -0,0 +1,254 @@
+package schnorrgs
+
+/* This file implements Schnorr adaptor signatures.
+
+   A pre-signature under the adaptor point T = tG is made with the
+   commitment R' = kG but the challenge of R = R' + T,
+
+       e = H(Y, R' + T, M),  s' = k - xe
+
+   so it is checked with s'G + eY = R' and is no signature by itself.
+   Whoever knows t completes it to the ordinary signature s = s' + t with
+   commitment R, and whoever holds both the pre-signature and the
+   completed signature learns t = s - s'. Publishing the signature thus
+   publishes the secret, which is what atomic swaps are built on.
+
+   The two-party variant runs the multisignature protocol under the joint
+   key with the adaptor challenge, and the summed responses form a
+   pre-signature under the joint key.
+*/
+
+import (
+	"bytes"
+	"errors"
+	"github.com/dedis/kyber"
+)
+
+// Represents a pre-signature. R is the commitment kG without the adaptor
+// point T.
+type SchnorrAdaptorPreSignature struct {
+	S     kyber.Scalar
+	R     kyber.Point
+	T     kyber.Point
+	suite string
+}
+
+// Returns the name of the suite this pre-signature belongs to.
+func (ps SchnorrAdaptorPreSignature) Suite() string {
+	return ps.suite
+}
+
+// Encodes a pre-signature as R||T||s'.
+func (ps SchnorrAdaptorPreSignature) Encode() ([]byte, error) {
+
+	var b bytes.Buffer
+	_, err := ps.R.MarshalTo(&b)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ps.T.MarshalTo(&b)
+	if err != nil {
+		return nil, err
+	}
+	_, err = ps.S.MarshalTo(&b)
+	if err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Decodes a pre-signature produced by Encode.
+func DecodeSchnorrAdaptorPreSignature(suite CryptoSuite,
+	b []byte) (SchnorrAdaptorPreSignature, error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrAdaptorPreSignature{}, err
+	}
+
+	d := newStrictDecoder(suite, "pre-signature", b)
+	R := d.point()
+	T := d.point()
+	S := d.scalar()
+	err = d.finish()
+	if err != nil {
+		return SchnorrAdaptorPreSignature{}, err
+	}
+
+	return SchnorrAdaptorPreSignature{S: S, R: R, T: T, suite: name}, nil
+}
+
+// Generates an adaptor secret t and its point T = tG.
+func SchnorrAdaptorGenerateSecret(suite CryptoSuite) (kyber.Scalar,
+	kyber.Point) {
+
+	t := suite.Scalar().Pick(suite.RandomStream())
+	return t, suite.Point().Mul(t, nil)
+}
+
+// Computes the challenge e = H(Y, R + T, M) of a pre-signature with
+// commitment R under the adaptor point T, in the given context. The
+// completed signature has the same challenge.
+func schnorrAdaptorChallenge(suite CryptoSuite, context []byte,
+	Y kyber.Point, R kyber.Point, T kyber.Point,
+	msg []byte) (kyber.Scalar, error) {
+
+	RT := suite.Point().Add(R, T)
+	return schnorrHashChallenge(suite, signatureTag, context, Y,
+		[]kyber.Point{RT}, msg)
+}
+
+// Pre-signs msg under the adaptor point T in the given context. Once
+// completed, the signature verifies with SchnorrVerifyWithContext, or
+// SchnorrVerify for a nil context.
+func SchnorrAdaptorPreSign(suite CryptoSuite, kv SchnorrSecretKV,
+	T kyber.Point, context []byte, msg []byte,
+	mode SchnorrNonceMode) (SchnorrAdaptorPreSignature, error) {
+
+	err := checkSigningKey(suite, kv)
+	if err != nil {
+		return SchnorrAdaptorPreSignature{}, err
+	}
+
+	// a nonce of its own purpose, bound to T: pre-signing the same
+	// message under two adaptor points, or signing it plainly, must not
+	// reuse k with another challenge.
+	domain, err := challengeDomain(signatureTag, context)
+	if err != nil {
+		return SchnorrAdaptorPreSignature{}, err
+	}
+	Tb, err := T.MarshalBinary()
+	if err != nil {
+		return SchnorrAdaptorPreSignature{}, err
+	}
+	noncemsg := append(append(domain, Tb...), msg...)
+	var k kyber.Scalar
+	switch mode {
+	case NonceRandom:
+		k = suite.Scalar().Pick(suite.RandomStream())
+	case NonceDeterministic, NonceHedged:
+		k, err = schnorrHashNonce(suite, 'a', kv, noncemsg, mode)
+		if err != nil {
+			return SchnorrAdaptorPreSignature{}, err
+		}
+	default:
+		return SchnorrAdaptorPreSignature{}, errors.New("Unknown nonce mode.")
+	}
+	R := suite.Point().Mul(k, nil)
+
+	e, err := schnorrAdaptorChallenge(suite, context, kv.pP, R, T, msg)
+	if err != nil {
+		return SchnorrAdaptorPreSignature{}, err
+	}
+	s := suite.Scalar().Zero()
+	s.Mul(kv.s, e).Sub(k, s) // k - xe
+
+	return SchnorrAdaptorPreSignature{S: s, R: R, T: T, suite: kv.suite}, nil
+}
+
+// Checks a pre-signature on msg against the key and the adaptor point it
+// claims, i.e. that it completes to a valid signature for whoever knows
+// the discrete log of T.
+func SchnorrAdaptorVerify(suite CryptoSuite, kp SchnorrPublicKV,
+	context []byte, msg []byte,
+	presig SchnorrAdaptorPreSignature) (bool, error) {
+
+	err := checkSuite(suite, kp.suite)
+	if err != nil {
+		return false, err
+	}
+	if presig.suite != kp.suite {
+		return false, errors.New("Pre-signature suite " + presig.suite +
+			" does not match key suite " + kp.suite)
+	}
+	if presig.R == nil || presig.T == nil || presig.S == nil {
+		return false, errors.New("Incomplete pre-signature.")
+	}
+
+	e, err := schnorrAdaptorChallenge(suite, context, kp.pP, presig.R,
+		presig.T, msg)
+	if err != nil {
+		return false, err
+	}
+	sG := suite.Point().Mul(presig.S, nil)
+	eY := suite.Point().Mul(e, kp.pP)
+	return suite.Point().Add(sG, eY).Equal(presig.R), nil
+}
+
+// Completes a pre-signature with the adaptor secret t into an ordinary
+// signature, s = s' + t.
+func SchnorrAdaptorComplete(suite CryptoSuite, kp SchnorrPublicKV,
+	context []byte, msg []byte, presig SchnorrAdaptorPreSignature,
+	t kyber.Scalar) (SchnorrSignature, error) {
+
+	err := checkSuite(suite, kp.suite)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+	if !suite.Point().Mul(t, nil).Equal(presig.T) {
+		return SchnorrSignature{},
+			errors.New("Adaptor secret does not match the adaptor point.")
+	}
+
+	e, err := schnorrAdaptorChallenge(suite, context, kp.pP, presig.R,
+		presig.T, msg)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+	s := suite.Scalar().Add(presig.S, t)
+	R := suite.Point().Add(presig.R, presig.T)
+
+	return SchnorrSignature{S: s, E: e, R: R, suite: kp.suite}, nil
+}
+
+// Recovers the adaptor secret t = s - s' from a pre-signature and the
+// signature it was completed to.
+func SchnorrAdaptorExtract(suite CryptoSuite,
+	presig SchnorrAdaptorPreSignature,
+	sig SchnorrSignature) (kyber.Scalar, error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return nil, err
+	}
+	if presig.suite != name || sig.suite != name {
+		return nil, errors.New("Pre-signature and signature suites differ.")
+	}
+
+	t := suite.Scalar().Sub(sig.S, presig.S)
+	if !suite.Point().Mul(t, nil).Equal(presig.T) {
+		return nil, errors.New("Signature is not a completion of the pre-signature.")
+	}
+	return t, nil
+}
+
+// Computes the collective challenge of a two-party pre-signature under
+// the adaptor point T. The parties answer it with SchnorrMSComputeResponse
+// as for any other multisignature.
+func SchnorrMSComputeAdaptorChallenge(suite CryptoSuite, context []byte,
+	jointkey SchnorrPublicKV, aggcommit SchnorrMSPublicCommitment,
+	T kyber.Point, msg []byte) (kyber.Scalar, error) {
+
+	err := checkSuite(suite, jointkey.suite)
+	if err != nil {
+		return nil, err
+	}
+	return schnorrAdaptorChallenge(suite, context, jointkey.pP, aggcommit.T,
+		T, msg)
+}
+
+// Builds the pre-signature under the joint key from the aggregate
+// commitment and the combined responses. It is checked, completed and
+// extracted from like any other pre-signature.
+func SchnorrMSCreateAdaptorPreSignature(suite CryptoSuite,
+	aggcommit SchnorrMSPublicCommitment, T kyber.Point,
+	r kyber.Scalar) (SchnorrAdaptorPreSignature, error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrAdaptorPreSignature{}, err
+	}
+
+	return SchnorrAdaptorPreSignature{S: r, R: aggcommit.T, T: T,
+		suite: name}, nil
+}
@@ -0,0 +1,197 @@
+package schnorrgs
+
+import (
+	"github.com/dedis/kyber"
+	"testing"
+)
+
+// Checks that presig is valid, completes it, checks the signature and
+// extracts t again.
+func checkAdaptorRoundTrip(t *testing.T, suite CryptoSuite, pk SchnorrPublicKV,
+	context []byte, msg []byte, presig SchnorrAdaptorPreSignature,
+	secret kyber.Scalar) {
+
+	ok, err := SchnorrAdaptorVerify(suite, pk, context, msg, presig)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Fatal("Pre-signature did not verify.")
+	}
+
+	// a pre-signature is not a signature.
+	plain := SchnorrSignature{S: presig.S, R: presig.R, suite: presig.suite}
+	ok, _ = SchnorrVerifyWithContext(suite, pk, context, msg, plain)
+	if ok {
+		t.Error("Pre-signature verified as a signature.")
+	}
+
+	sig, err := SchnorrAdaptorComplete(suite, pk, context, msg, presig, secret)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ok, err = SchnorrVerifyWithContext(suite, pk, context, msg, sig)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Error("Completed signature did not verify.")
+	}
+
+	// the (R, s) encoding carries the completed signature too.
+	rs, err := sig.EncodeRS()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	decoded, err := DecodeSchnorrSignatureRS(suite, rs)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ok, _ = SchnorrVerifyWithContext(suite, pk, context, msg, decoded)
+	if !ok {
+		t.Error("Completed (R, s) signature did not verify.")
+	}
+
+	extracted, err := SchnorrAdaptorExtract(suite, presig, decoded)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !extracted.Equal(secret) {
+		t.Error("Extracted the wrong adaptor secret.")
+	}
+}
+
+func TestSchnorrAdaptorRoundTrip(t *testing.T) {
+
+	msg := []byte("pay 1 coin to bob")
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		pk := kv.GetPublicKeyset()
+		secret, T := SchnorrAdaptorGenerateSecret(suite)
+
+		for _, mode := range []SchnorrNonceMode{NonceRandom,
+			NonceDeterministic, NonceHedged} {
+			for _, context := range [][]byte{nil, []byte("swap-v1")} {
+				presig, err := SchnorrAdaptorPreSign(suite, kv, T, context,
+					msg, mode)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				enc, err := presig.Encode()
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				presig, err = DecodeSchnorrAdaptorPreSignature(suite, enc)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				checkAdaptorRoundTrip(t, suite, pk, context, msg, presig,
+					secret)
+			}
+		}
+	}
+}
+
+func TestSchnorrAdaptorRejects(t *testing.T) {
+
+	msg := []byte("pay 1 coin to bob")
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+		pk := kv.GetPublicKeyset()
+		_, T := SchnorrAdaptorGenerateSecret(suite)
+		_, other := SchnorrAdaptorGenerateSecret(suite)
+
+		presig, err := SchnorrAdaptorPreSign(suite, kv, T, nil, msg,
+			NonceDeterministic)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		// another adaptor point, message or context.
+		swapped := presig
+		swapped.T = other
+		ok, _ := SchnorrAdaptorVerify(suite, pk, nil, msg, swapped)
+		if ok {
+			t.Errorf("%s: pre-signature verified under another point", name)
+		}
+		ok, _ = SchnorrAdaptorVerify(suite, pk, nil, []byte("pay 2"), presig)
+		if ok {
+			t.Errorf("%s: pre-signature verified on another message", name)
+		}
+		ok, _ = SchnorrAdaptorVerify(suite, pk, []byte("ctx"), msg, presig)
+		if ok {
+			t.Errorf("%s: pre-signature verified in another context", name)
+		}
+
+		wrong := suite.Scalar().Pick(suite.RandomStream())
+		_, err = SchnorrAdaptorComplete(suite, pk, nil, msg, presig, wrong)
+		if err == nil {
+			t.Errorf("%s: completed with the wrong secret", name)
+		}
+
+		// a signature that did not come from the pre-signature.
+		sig, _ := SchnorrSign(suite, kv, msg)
+		_, err = SchnorrAdaptorExtract(suite, presig, sig)
+		if err == nil {
+			t.Errorf("%s: extracted from an unrelated signature", name)
+		}
+
+		// deterministic pre-signatures under different points must not
+		// share a nonce.
+		presig2, _ := SchnorrAdaptorPreSign(suite, kv, other, nil, msg,
+			NonceDeterministic)
+		if presig2.R.Equal(presig.R) {
+			t.Errorf("%s: nonce reused across adaptor points", name)
+		}
+	}
+}
+
+func TestSchnorrMSAdaptor(t *testing.T) {
+
+	msg := []byte("atomic swap, leg one")
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv1, _ := SchnorrGenerateKeypair(suite)
+		kv2, _ := SchnorrGenerateKeypair(suite)
+		pks := []SchnorrPublicKV{kv1.GetPublicKeyset(), kv2.GetPublicKeyset()}
+		jointkey, err := SchnorrMSComputeSharedPublicKey(suite, pks)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		// party 2 holds the adaptor secret.
+		secret, T := SchnorrAdaptorGenerateSecret(suite)
+
+		commit1 := SchnorrMSGenerateCommitment(suite)
+		commit2 := SchnorrMSGenerateCommitment(suite)
+		agg := SchnorrMSAggregateCommitment(suite,
+			[]SchnorrMSPublicCommitment{commit1.GetPublicCommitment(),
+				commit2.GetPublicCommitment()})
+
+		c, err := SchnorrMSComputeAdaptorChallenge(suite, nil, jointkey, agg,
+			T, msg)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		r1, err := SchnorrMSComputeResponse(suite, c, pks, kv1, commit1)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		r2, err := SchnorrMSComputeResponse(suite, c, pks, kv2, commit2)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		presig, err := SchnorrMSCreateAdaptorPreSignature(suite, agg, T,
+			SchnorrMSComputeCombinedResponse(suite, []kyber.Scalar{r1, r2}))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		checkAdaptorRoundTrip(t, suite, jointkey, nil, msg, presig, secret)
+	}
+}
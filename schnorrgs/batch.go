@@ -0,0 +1,271 @@
+package schnorrgs
+
+/* This file implements batch verification of Schnorr signatures.
+
+   A signature (R, s) on M under Y is valid when sG + eY = R with
+   e = H(tag||ctx||Y||R||M), see domain.go. For a batch we pick random 128-bit weights z_i and check
+
+       8 ((sum z_i s_i) G + sum (z_i e_i) Y_i - sum z_i R_i) = 0
+
+   with two multi-scalar multiplications, one for each side. The R side
+   only has the short weights, so it costs half the doublings of the
+   other. A forged signature only passes if it cancels out against the
+   random weights, which happens with probability about 2^-128. If the
+   batch fails we split it in half and recurse to find the bad signatures.
+
+   Batching needs R. Signatures that only carry (s, e) get their R
+   recomputed first, which costs as much as verifying them on their own.
+
+   Multiplying by the cofactor makes the result independent of the
+   weights: a small order component of R or Y is always ignored, never
+   just when a weight happens to cancel it. Keys and signatures that went
+   through our decoders never have one, see decodePoint, so for them the
+   batch agrees with SchnorrVerify. A hand-built R with a small order
+   component is refused by SchnorrVerify but passes the batch.
+*/
+
+import (
+	"errors"
+	"github.com/dedis/kyber"
+	"sort"
+)
+
+// Size of the random weights, in bytes.
+const batchWeightSize = 16
+
+// Window size, in bits, of the multi-scalar multiplication.
+const msmWindow = 4
+
+// Returns the little-endian bytes of a scalar. kyber marshals scalars
+// little-endian for Ed25519 but big-endian for the NIST curves, so we
+// find out which by looking at how one is encoded.
+func scalarBytesLE(suite CryptoSuite, s kyber.Scalar) ([]byte, error) {
+	b, err := s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	one, err := suite.Scalar().One().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if len(one) > 1 && one[0] != 1 {
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+	}
+	return b, nil
+}
+
+// Computes sum scalars[i] * points[i] using Straus' interleaving trick:
+// all the terms share the same doublings, so n terms cost one set of
+// doublings plus a few additions per term, instead of n full scalar
+// multiplications.
+func multiScalarMul(suite CryptoSuite, scalars []kyber.Scalar,
+	points []kyber.Point) (kyber.Point, error) {
+
+	if len(scalars) != len(points) {
+		return nil, errors.New("Need as many scalars as points.")
+	}
+
+	tableSize := 1 << msmWindow
+	tables := make([][]kyber.Point, len(points))
+	digits := make([][]byte, len(points))
+	nbytes := 0
+
+	for i, P := range points {
+		table := make([]kyber.Point, tableSize)
+		table[0] = suite.Point().Null()
+		for j := 1; j < tableSize; j++ {
+			table[j] = suite.Point().Add(table[j-1], P)
+		}
+		tables[i] = table
+
+		b, err := scalarBytesLE(suite, scalars[i])
+		if err != nil {
+			return nil, err
+		}
+		// high zero bytes add nothing but doublings.
+		for len(b) > 0 && b[len(b)-1] == 0 {
+			b = b[:len(b)-1]
+		}
+		digits[i] = b
+		if len(b) > nbytes {
+			nbytes = len(b)
+		}
+	}
+
+	acc := suite.Point().Null()
+	for w := nbytes*8/msmWindow - 1; w >= 0; w-- {
+		for k := 0; k < msmWindow; k++ {
+			acc.Add(acc, acc)
+		}
+		bit := w * msmWindow
+		for i := range points {
+			if bit/8 >= len(digits[i]) {
+				continue
+			}
+			d := (digits[i][bit/8] >> uint(bit%8)) & byte(tableSize-1)
+			if d != 0 {
+				acc.Add(acc, tables[i][d])
+			}
+		}
+	}
+
+	return acc, nil
+}
+
+// One signature, ready for the batch equation.
+type batchEntry struct {
+	Y kyber.Point
+	R kyber.Point
+	S kyber.Scalar
+	E kyber.Scalar
+}
+
+// Checks the batch equation over the given subset of entries.
+func batchCheck(suite CryptoSuite, entries []batchEntry,
+	indices []int) (bool, error) {
+
+	// sG + eY on one side, with full size scalars; R on the other, with
+	// the weights alone.
+	var scalars, weights []kyber.Scalar
+	var points, rs []kyber.Point
+
+	sumS := suite.Scalar().Zero()
+	weightBytes := make([]byte, batchWeightSize)
+
+	for _, i := range indices {
+		for k := range weightBytes {
+			weightBytes[k] = 0
+		}
+		suite.RandomStream().XORKeyStream(weightBytes, weightBytes)
+		z := suite.Scalar().SetBytes(weightBytes)
+
+		sumS.Add(sumS, suite.Scalar().Mul(z, entries[i].S))
+
+		scalars = append(scalars, suite.Scalar().Mul(z, entries[i].E))
+		points = append(points, entries[i].Y)
+
+		weights = append(weights, z)
+		rs = append(rs, entries[i].R)
+	}
+
+	scalars = append(scalars, sumS)
+	points = append(points, suite.Point().Base())
+
+	left, err := multiScalarMul(suite, scalars, points)
+	if err != nil {
+		return false, err
+	}
+	right, err := multiScalarMul(suite, weights, rs)
+	if err != nil {
+		return false, err
+	}
+
+	diff := suite.Point().Sub(left, right)
+	for k := 0; k < 3; k++ {
+		diff.Add(diff, diff)
+	}
+	return diff.Equal(suite.Point().Null()), nil
+}
+
+// Finds the bad entries among indices by checking halves recursively.
+func batchFindBad(suite CryptoSuite, entries []batchEntry,
+	indices []int) ([]int, error) {
+
+	ok, err := batchCheck(suite, entries, indices)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, nil
+	}
+	if len(indices) == 1 {
+		return indices, nil
+	}
+
+	half := len(indices) / 2
+	left, err := batchFindBad(suite, entries, indices[:half])
+	if err != nil {
+		return nil, err
+	}
+	right, err := batchFindBad(suite, entries, indices[half:])
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// Verifies many signatures at once. Signature i must be a signature
+// on msgs[i] by keys[i]. Returns true if all signatures verify; otherwise
+// returns false and the sorted indices of the signatures that do not.
+// Errors are reserved for malformed input, e.g. mismatched suites.
+func SchnorrVerifyBatch(suite CryptoSuite, keys []SchnorrPublicKV,
+	msgs [][]byte, sigs []SchnorrSignature) (bool, []int, error) {
+
+	return SchnorrVerifyBatchWithContext(suite, nil, keys, msgs, sigs)
+}
+
+// SchnorrVerifyBatch for signatures made with SchnorrSignWithContext, all
+// in the same context.
+func SchnorrVerifyBatchWithContext(suite CryptoSuite, context []byte,
+	keys []SchnorrPublicKV, msgs [][]byte,
+	sigs []SchnorrSignature) (bool, []int, error) {
+
+	if len(keys) != len(msgs) || len(keys) != len(sigs) {
+		return false, nil, errors.New("Batch needs as many keys, messages " +
+			"and signatures.")
+	}
+
+	entries := make([]batchEntry, len(sigs))
+	var indices []int
+	var bad []int
+
+	for i, sig := range sigs {
+		err := checkSuite(suite, keys[i].suite)
+		if err != nil {
+			return false, nil, err
+		}
+		if sig.suite != keys[i].suite {
+			return false, nil, errors.New("Signature suite " + sig.suite +
+				" does not match key suite " + keys[i].suite)
+		}
+
+		R := sig.R
+		if R == nil {
+			if sig.E == nil {
+				return false, nil, errors.New("Signature has neither E nor R.")
+			}
+			sG := suite.Point().Mul(sig.S, nil)
+			eY := suite.Point().Mul(sig.E, keys[i].pP)
+			R = suite.Point().Add(sG, eY)
+		}
+
+		e, err := schnorrHashChallenge(suite, signatureTag, context,
+			keys[i].pP, []kyber.Point{R}, msgs[i])
+		if err != nil {
+			return false, nil, err
+		}
+
+		// an (s, e) signature also claims a challenge; if it is not
+		// the one the message gives us there is nothing to batch.
+		if sig.E != nil && !sig.E.Equal(e) {
+			bad = append(bad, i)
+			continue
+		}
+
+		entries[i] = batchEntry{Y: keys[i].pP, R: R, S: sig.S, E: e}
+		indices = append(indices, i)
+	}
+
+	if len(indices) > 0 {
+		batchbad, err := batchFindBad(suite, entries, indices)
+		if err != nil {
+			return false, nil, err
+		}
+		bad = append(bad, batchbad...)
+	}
+
+	sort.Ints(bad)
+	return len(bad) == 0, bad, nil
+}
@@ -0,0 +1,195 @@
+package schnorrgs
+
+import (
+	"fmt"
+	"github.com/dedis/kyber"
+	"testing"
+)
+
+// Signs n distinct messages with n distinct keys in the given suite.
+func makeBatch(t testing.TB, suite CryptoSuite, n int) ([]SchnorrPublicKV,
+	[][]byte, []SchnorrSignature) {
+
+	var keys []SchnorrPublicKV
+	var msgs [][]byte
+	var sigs []SchnorrSignature
+
+	for i := 0; i < n; i++ {
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		msg := []byte(fmt.Sprintf("notary receipt %d", i))
+		sig, err := SchnorrSign(suite, kv, msg)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		keys = append(keys, kv.GetPublicKeyset())
+		msgs = append(msgs, msg)
+		sigs = append(sigs, sig)
+	}
+	return keys, msgs, sigs
+}
+
+func TestSchnorrVerifyBatch(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		keys, msgs, sigs := makeBatch(t, suite, 64)
+
+		ok, bad, err := SchnorrVerifyBatch(suite, keys, msgs, sigs)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !ok || len(bad) != 0 {
+			t.Errorf("%s: valid batch failed, bad indices %v", name, bad)
+		}
+
+		// break a message, a signature and a key.
+		msgs[3] = []byte("forged receipt")
+		sigs[17].S = suite.Scalar().Add(sigs[17].S, suite.Scalar().One())
+		keys[40] = keys[41]
+
+		ok, bad, err = SchnorrVerifyBatch(suite, keys, msgs, sigs)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if ok {
+			t.Errorf("%s: invalid batch verified", name)
+		}
+		if fmt.Sprint(bad) != "[3 17 40]" {
+			t.Errorf("%s: wrong bad indices %v", name, bad)
+		}
+	}
+}
+
+func TestSchnorrVerifyBatchRSEncoding(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	keys, msgs, sigs := makeBatch(t, suite, 8)
+
+	var decoded []SchnorrSignature
+	for _, sig := range sigs {
+		b, err := sig.EncodeRS()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		rs, err := DecodeSchnorrSignatureRS(suite, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if rs.E != nil || !rs.R.Equal(sig.R) || !rs.S.Equal(sig.S) {
+			t.Error("(R, s) signature did not survive encoding.")
+		}
+		decoded = append(decoded, rs)
+	}
+
+	v, err := SchnorrVerify(suite, keys[0], msgs[0], decoded[0])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !v {
+		t.Error("(R, s) signature did not verify.")
+	}
+	v, err = SchnorrVerify(suite, keys[0], msgs[1], decoded[0])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v {
+		t.Error("(R, s) signature verified for the wrong message.")
+	}
+
+	// (s, e) signatures without R are still accepted in a batch.
+	decoded[5].R = nil
+	decoded[5].E = sigs[5].E
+	ok, bad, err := SchnorrVerifyBatch(suite, keys, msgs, decoded)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Errorf("Mixed batch failed, bad indices %v", bad)
+	}
+
+	_, err = DecodeSchnorrSignatureRS(suite, []byte{1, 2, 3})
+	if err == nil {
+		t.Error("Decoded a signature from a short buffer.")
+	}
+}
+
+func BenchmarkSchnorrVerifyBatch(b *testing.B) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	keys, msgs, sigs := makeBatch(b, suite, 256)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SchnorrVerifyBatch(suite, keys, msgs, sigs)
+	}
+}
+
+func BenchmarkSchnorrVerifyOneByOne(b *testing.B) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	keys, msgs, sigs := makeBatch(b, suite, 256)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range sigs {
+			SchnorrVerify(suite, keys[j], msgs[j], sigs[j])
+		}
+	}
+}
+
+func TestSchnorrVerifyBatchTorsionR(t *testing.T) {
+
+	suite, _ := GetSuite("BlakeSHA256Ed25519")
+	kv, _ := SchnorrGenerateKeypair(suite)
+	msg := []byte("torsion")
+
+	// y = 0 is a point of order 4.
+	T := suite.Point()
+	if T.UnmarshalBinary(make([]byte, 32)) != nil {
+		t.Fatal("Order 4 point did not decode")
+	}
+
+	// R' = kG + T with s = k - x e', e' = H(Y, R', M): sG + e'Y = R' - T,
+	// so only the torsion part is off.
+	k := suite.Scalar().Pick(suite.RandomStream())
+	R := suite.Point().Add(suite.Point().Mul(k, nil), T)
+	e, err := schnorrHashChallenge(suite, signatureTag, nil, kv.pP,
+		[]kyber.Point{R}, msg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s := suite.Scalar().Sub(k, suite.Scalar().Mul(kv.s, e))
+	sig := SchnorrSignature{S: s, R: R, suite: kv.suite}
+
+	ok, _ := SchnorrVerify(suite, kv.GetPublicKeyset(), msg, sig)
+	if ok {
+		t.Fatal("Signature with a torsion R verified")
+	}
+	// such an R never comes off the wire.
+	b, err := sig.EncodeRS()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = DecodeSchnorrSignatureRS(suite, b)
+	if err == nil {
+		t.Fatal("Decoded a signature with a torsion R")
+	}
+
+	// the cofactored batch ignores T whatever the weights are, instead
+	// of refusing it only when the weight is not a multiple of 4.
+	for i := 0; i < 64; i++ {
+		ok, bad, err := SchnorrVerifyBatch(suite,
+			[]SchnorrPublicKV{kv.GetPublicKeyset()}, [][]byte{msg},
+			[]SchnorrSignature{sig})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !ok || len(bad) != 0 {
+			t.Fatalf("Batch result depends on the weights, bad indices %v",
+				bad)
+		}
+	}
+}
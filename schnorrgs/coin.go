@@ -0,0 +1,203 @@
+package schnorrgs
+
+/* This file implements the coins a blind signing bank issues and the
+   receipts it gives when they are deposited.
+
+   A coin is a message the client chose, the info of its denomination and
+   the partially blind signature on them. The bank never saw the message
+   while signing, so it can only tell a coin was spent before by
+   remembering it: the serial of a coin is a hash of its info and message,
+   and a coin is spent once its serial is recorded. A second signature on
+   the same message and info is the same coin.
+
+   A receipt states that the coin with a given serial was deposited, for
+   which denomination and face value, and when. It is signed under its own
+   protocol tag (see domain.go) by the deposit service.
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+	"golang.org/x/crypto/blake2b"
+	"time"
+)
+
+// Size of a coin serial.
+const CoinSerialSize = 32
+
+// Prefix of the coin serial hash.
+const coinSerialTag = "schnorrgs-coin-serial-v1"
+
+// A coin: a message with a partially blind signature on it under Info.
+type WIBlindCoin struct {
+	Info      []byte
+	Message   []byte
+	Signature WIBlindSignature
+}
+
+// Encodes a coin as len(info) || info || len(msg) || msg || signature,
+// with uint32 big endian lengths.
+func (c WIBlindCoin) MarshalBinary() ([]byte, error) {
+	sig, err := c.Signature.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 4, 8+len(c.Info)+len(c.Message)+len(sig))
+	binary.BigEndian.PutUint32(b, uint32(len(c.Info)))
+	b = append(b, c.Info...)
+	n := make([]byte, 4)
+	binary.BigEndian.PutUint32(n, uint32(len(c.Message)))
+	b = append(b, n...)
+	b = append(b, c.Message...)
+	return append(b, sig...), nil
+}
+
+// Decodes a coin encoded by MarshalBinary.
+func (c *WIBlindCoin) UnmarshalBinary(suite CryptoSuite, b []byte) error {
+	d := newStrictDecoder(suite, "coin", b)
+	field := func() []byte {
+		n := d.next(4)
+		if n == nil {
+			return nil
+		}
+		return d.next(int(binary.BigEndian.Uint32(n)))
+	}
+	info := field()
+	msg := field()
+	P, W, S, D := d.scalar(), d.scalar(), d.scalar(), d.scalar()
+	err := d.finish()
+	if err != nil {
+		return err
+	}
+	*c = WIBlindCoin{Info: append([]byte{}, info...),
+		Message: append([]byte{}, msg...), Signature: WIBlindSignature{P, W, S, D}}
+	return nil
+}
+
+// Computes the serial of the coin:
+// H(tag || len(info) || info || msg), with a uint32 big endian length.
+func (c WIBlindCoin) Serial() []byte {
+	h, _ := blake2b.New256(nil)
+	h.Write([]byte(coinSerialTag))
+	n := make([]byte, 4)
+	binary.BigEndian.PutUint32(n, uint32(len(c.Info)))
+	h.Write(n)
+	h.Write(c.Info)
+	h.Write(c.Message)
+	return h.Sum(nil)
+}
+
+// Checks the signature on the coin against the bank key pk.
+func (c WIBlindCoin) Verify(suite CryptoSuite, pk SchnorrPublicKV) (bool,
+	error) {
+	return VerifyBlindSignature(suite, pk, c.Signature, c.Info, c.Message)
+}
+
+// A receipt for a deposited coin. Time is in whole seconds.
+type WIDepositReceipt struct {
+	Serial       []byte
+	Denomination string
+	FaceValue    uint64
+	Time         time.Time
+	Signature    SchnorrSignature
+}
+
+// Builds the message a receipt signature covers:
+// serial || time || facevalue || len(id) || id,
+// with the time in seconds since the epoch and numbers big endian.
+func (r WIDepositReceipt) message() ([]byte, error) {
+	if len(r.Serial) != CoinSerialSize {
+		return nil, errors.New("Coin serials are 32 bytes.")
+	}
+	if r.Denomination == "" || len(r.Denomination) > 255 {
+		return nil, errors.New("Denomination IDs have to be 1 to 255 bytes.")
+	}
+	msg := append([]byte{}, r.Serial...)
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(r.Time.Unix()))
+	msg = append(msg, b...)
+	binary.BigEndian.PutUint64(b, r.FaceValue)
+	msg = append(msg, b...)
+	msg = append(msg, byte(len(r.Denomination)))
+	return append(msg, []byte(r.Denomination)...), nil
+}
+
+// Makes a receipt for a coin with the given serial, of denomination d,
+// deposited at now, signed with kv.
+func SchnorrSignDepositReceipt(suite CryptoSuite, kv SchnorrSecretKV,
+	serial []byte, d SchnorrDenomination,
+	now time.Time) (WIDepositReceipt, error) {
+
+	r := WIDepositReceipt{Serial: serial, Denomination: d.ID,
+		FaceValue: d.FaceValue, Time: now.UTC().Truncate(time.Second)}
+	msg, err := r.message()
+	if err != nil {
+		return WIDepositReceipt{}, err
+	}
+	sig, err := schnorrSignTagged(suite, kv, receiptSignatureTag, nil, msg,
+		NonceHedged)
+	if err != nil {
+		return WIDepositReceipt{}, err
+	}
+	r.Signature = sig
+	return r, nil
+}
+
+// Checks a receipt was signed by pk.
+func (r WIDepositReceipt) Verify(suite CryptoSuite, pk SchnorrPublicKV) (bool,
+	error) {
+
+	msg, err := r.message()
+	if err != nil {
+		return false, err
+	}
+	return schnorrVerifyTagged(suite, pk, receiptSignatureTag, nil, msg,
+		r.Signature)
+}
+
+// Encodes a receipt as the message its signature covers followed by the
+// signature.
+func (r WIDepositReceipt) MarshalBinary() ([]byte, error) {
+	msg, err := r.message()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := r.Signature.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return append(msg, sig...), nil
+}
+
+// Decodes a receipt encoded by MarshalBinary.
+func (r *WIDepositReceipt) UnmarshalBinary(suite CryptoSuite,
+	b []byte) error {
+
+	d := newStrictDecoder(suite, "deposit receipt", b)
+	serial := d.next(CoinSerialSize)
+	t := d.next(8)
+	facevalue := d.next(8)
+	n := d.next(1)
+	var id []byte
+	if n != nil {
+		id = d.next(int(n[0]))
+	}
+	sigsize := 2 * suite.Scalar().MarshalSize()
+	sigb := d.next(sigsize)
+	err := d.finish()
+	if err != nil {
+		return err
+	}
+	sig, err := DecodeSchnorrSignature(suite, sigb)
+	if err != nil {
+		return err
+	}
+	*r = WIDepositReceipt{
+		Serial:       append([]byte{}, serial...),
+		Denomination: string(id),
+		FaceValue:    binary.BigEndian.Uint64(facevalue),
+		Time:         time.Unix(int64(binary.BigEndian.Uint64(t)), 0).UTC(),
+		Signature:    sig,
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package schnorrgs
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Runs the partially blind protocol for a coin with the given info.
+func testCoin(t *testing.T, suite CryptoSuite, kv SchnorrSecretKV,
+	info []byte, msg []byte) WIBlindCoin {
+
+	pk := kv.GetPublicKeyset()
+	signerParams, err := NewPrivateParams(suite, info)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	challenge, userParams, err := ClientGenerateChallenge(suite,
+		signerParams.DerivePubParams(), pk, info, msg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	response, err := ServerGenerateResponse(suite, challenge, signerParams, kv)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sig, ok := ClientSignBlindly(suite, userParams, response, pk, msg)
+	if !ok {
+		t.Fatal("Blind signature failed")
+	}
+	return WIBlindCoin{Info: info, Message: msg, Signature: sig}
+}
+
+func TestBlindCoinEncoding(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+		coin := testCoin(t, suite, kv, []byte("five coin"), []byte("serial 1"))
+
+		b, err := coin.MarshalBinary()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var decoded WIBlindCoin
+		err = decoded.UnmarshalBinary(suite, b)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err.Error())
+		}
+		ok, err := decoded.Verify(suite, kv.GetPublicKeyset())
+		if err != nil || !ok {
+			t.Errorf("%s: decoded coin does not verify", name)
+		}
+		if !bytes.Equal(decoded.Serial(), coin.Serial()) {
+			t.Errorf("%s: serial changed in a round trip", name)
+		}
+
+		for n := 0; n < len(b); n++ {
+			if decoded.UnmarshalBinary(suite, b[:n]) == nil {
+				t.Errorf("%s: decoded %d of %d bytes", name, n, len(b))
+			}
+		}
+		err = decoded.UnmarshalBinary(suite, append(b, 0))
+		if !errors.Is(err, ErrDecodeLength) {
+			t.Errorf("%s: trailing byte gave %v", name, err)
+		}
+	}
+}
+
+func TestBlindCoinSerial(t *testing.T) {
+
+	a := WIBlindCoin{Info: []byte("ab"), Message: []byte("c")}
+	b := WIBlindCoin{Info: []byte("a"), Message: []byte("bc")}
+	if bytes.Equal(a.Serial(), b.Serial()) {
+		t.Error("Coins with different info share a serial")
+	}
+	if len(a.Serial()) != CoinSerialSize {
+		t.Error("Serial has the wrong size")
+	}
+}
+
+func TestDepositReceipt(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+		d := NewSchnorrDenomination("5", []byte("five coin"), 5, time.Time{})
+		coin := WIBlindCoin{Info: []byte("five coin"), Message: []byte("1")}
+
+		r, err := SchnorrSignDepositReceipt(suite, kv, coin.Serial(), d,
+			time.Now())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		b, err := r.MarshalBinary()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var decoded WIDepositReceipt
+		err = decoded.UnmarshalBinary(suite, b)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err.Error())
+		}
+		ok, err := decoded.Verify(suite, kv.GetPublicKeyset())
+		if err != nil || !ok {
+			t.Errorf("%s: decoded receipt does not verify", name)
+		}
+		if decoded.Denomination != "5" || decoded.FaceValue != 5 ||
+			!decoded.Time.Equal(r.Time) {
+			t.Errorf("%s: receipt changed in a round trip", name)
+		}
+
+		decoded.FaceValue = 500
+		ok, _ = decoded.Verify(suite, kv.GetPublicKeyset())
+		if ok {
+			t.Errorf("%s: receipt with another face value verified", name)
+		}
+		// a receipt is not a signature on its message.
+		msg, _ := r.message()
+		ok, _ = SchnorrVerify(suite, kv.GetPublicKeyset(), msg, r.Signature)
+		if ok {
+			t.Errorf("%s: receipt verified as an ordinary signature", name)
+		}
+		if decoded.UnmarshalBinary(suite, b[:len(b)-1]) == nil {
+			t.Errorf("%s: truncated receipt decoded", name)
+		}
+	}
+}
+
+func TestDenominationLookupInfo(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+	c, err := SchnorrSignDenominationCatalogue(suite, kv, testDenominations())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	d, err := c.LookupInfo([]byte("five coin"), time.Now())
+	if err != nil || d.ID != "5" {
+		t.Errorf("Lookup by info gave %v", err)
+	}
+	_, err = c.LookupInfo([]byte("old coin"), time.Now())
+	if err != ErrDenominationExpired {
+		t.Errorf("Expired denomination gave %v", err)
+	}
+	_, err = c.LookupInfo([]byte("no coin"), time.Now())
+	if err != ErrUnknownDenomination {
+		t.Errorf("Unknown info gave %v", err)
+	}
+}
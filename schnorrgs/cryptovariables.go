@@ -6,8 +6,10 @@ import (
 	"errors"
 	"github.com/dedis/kyber"
 	"github.com/dedis/kyber/group/edwards25519"
-	"golang.org/x/crypto/blake2b"
+	"github.com/dedis/kyber/group/nist"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // This represents the suite of parameters used
@@ -17,38 +19,109 @@ type CryptoSuite interface {
 	kyber.Random
 }
 
-// This function computes H(r||M) in the cryptosuite using the blake2b
-// hash function for mapping back to the scalar types.
-func SchnorrHashPointsMsgToScalar(k CryptoSuite, R kyber.Point,
-	msg []byte) (kyber.Scalar, error) {
-	h, err := blake2b.New512(nil)
-	if err != nil {
-		return nil, err
-	}
-	_, err = R.MarshalTo(h)
-	if err != nil {
-		return nil, err
+// The suite used when nobody asks for anything else.
+const DefaultSuite = "BlakeSHA256Ed25519"
+
+// A SuiteConstructor returns a fresh instance of a registered suite.
+type SuiteConstructor func() CryptoSuite
+
+// The suite registry maps a suite name, which is what gets written into
+// key files and group configurations, to its constructor. groupNames maps
+// the kyber group name (suite.String()) back to the registered name so that
+// we can label keys generated from a bare suite value.
+var (
+	suiteLock  sync.RWMutex
+	suites     = make(map[string]SuiteConstructor)
+	groupNames = make(map[string]string)
+)
+
+func init() {
+	RegisterSuite("BlakeSHA256Ed25519", func() CryptoSuite {
+		return edwards25519.NewBlakeSHA256Ed25519()
+	})
+	RegisterSuite("BlakeSHA256P256", func() CryptoSuite {
+		return nist.NewBlakeSHA256P256()
+	})
+}
+
+// Registers a new cipher suite under the given name. Each name may only be
+// registered once and each underlying group may only be registered under
+// one name, otherwise we could not tell which name a key belongs to.
+func RegisterSuite(name string, constructor SuiteConstructor) error {
+	if name == "" || strings.Contains(name, ";") {
+		return errors.New("Invalid cipher suite name.")
 	}
-	_, err = h.Write(msg)
-	if err != nil {
-		return nil, err
+	if constructor == nil {
+		return errors.New("No constructor given for cipher suite " + name)
 	}
 
-	return k.Scalar().SetBytes(h.Sum(nil)), nil
+	group := constructor().String()
+
+	suiteLock.Lock()
+	defer suiteLock.Unlock()
+
+	if _, exists := suites[name]; exists {
+		return errors.New("Cipher suite " + name + " is already registered.")
+	}
+	if other, exists := groupNames[group]; exists {
+		return errors.New("Group " + group + " is already registered as " + other)
+	}
+	suites[name] = constructor
+	groupNames[group] = name
+	return nil
 }
 
-// Returns a cryptographic suite. For now only the string
-// "BlakeSHA256Ed25519" makes any sense. The idea was to allow
-// multiple suites, but, ran out of time.
+// Returns a cryptographic suite previously registered under the
+// given name.
 func GetSuite(suite string) (CryptoSuite, error) {
-	if suite == "BlakeSHA256Ed25519" {
-		return edwards25519.NewBlakeSHA256Ed25519(), nil
-		// this should, but doesn't work.
-		//	} else if suite == "BlakeSHA256P256" {
-		//		return nist.
-	} else {
+	suiteLock.RLock()
+	constructor, ok := suites[suite]
+	suiteLock.RUnlock()
+
+	if !ok {
 		return nil, errors.New("Invalid cipher suite specified.")
 	}
+	return constructor(), nil
+}
+
+// Returns the registered name of a suite value, i.e. the inverse of
+// GetSuite.
+func SuiteName(suite CryptoSuite) (string, error) {
+	suiteLock.RLock()
+	name, ok := groupNames[suite.String()]
+	suiteLock.RUnlock()
+
+	if !ok {
+		return "", errors.New("Cipher suite " + suite.String() + " is not registered.")
+	}
+	return name, nil
+}
+
+// Lists the names of all registered suites, sorted.
+func RegisteredSuites() []string {
+	suiteLock.RLock()
+	defer suiteLock.RUnlock()
+
+	var names []string
+	for name := range suites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Checks that the suite we are about to compute with is the one
+// the key (or signature) was made for.
+func checkSuite(suite CryptoSuite, name string) error {
+	actual, err := SuiteName(suite)
+	if err != nil {
+		return err
+	}
+	if actual != name {
+		return errors.New("Suite mismatch: key material is " + name +
+			" but suite is " + actual)
+	}
+	return nil
 }
 
 // Represents a Schnorr Secret keyset.
@@ -56,6 +129,8 @@ type SchnorrSecretKV struct {
 	suite string       // identifier for the suite used.
 	s     kyber.Scalar // Secret key, represented as a kyber scalar type.
 	pP    kyber.Point  // public key represented as an encoded byte                              // string of the given point.
+	chain []byte       // chain code of a derived key, see hd.go.
+	meta  *SchnorrKeyMetadata
 }
 
 // Represents only the public key
@@ -64,6 +139,7 @@ type SchnorrSecretKV struct {
 type SchnorrPublicKV struct {
 	suite string      // identifier for the suite used
 	pP    kyber.Point // Public key represent as an encoded byte string
+	meta  *SchnorrKeyMetadata
 }
 
 // This structure is only used for JSON serialization
@@ -71,12 +147,24 @@ type SchnorrSecretDiskRepr struct {
 	Suite string
 	S     string
 	P     string
+	Chain string              `json:",omitempty"`
+	Meta  *SchnorrKeyMetadata `json:",omitempty"`
+}
+
+// Returns the name of the suite this keyset belongs to.
+func (s SchnorrSecretKV) Suite() string {
+	return s.suite
+}
+
+// Returns the name of the suite this public key belongs to.
+func (p SchnorrPublicKV) Suite() string {
+	return p.suite
 }
 
 // Retrieves the public keyset directly from a private keyset.
 // Yes, it's a little Java-ish/class-ish in design.
 func (s SchnorrSecretKV) GetPublicKeyset() SchnorrPublicKV {
-	return SchnorrPublicKV{suite: s.suite, pP: s.pP}
+	return SchnorrPublicKV{suite: s.suite, pP: s.pP, meta: s.meta}
 }
 
 // Exports a secret key for on-disk storage
@@ -88,6 +176,8 @@ func (k SchnorrSecretKV) Export() []byte {
 		Suite: k.suite,
 		S:     strs,
 		P:     k.pP.String(),
+		Chain: hex.EncodeToString(k.chain),
+		Meta:  k.meta,
 	}
 
 	b, _ := json.Marshal(diskrepr)
@@ -115,45 +205,80 @@ func NewSchnorrSecretKVFromImport(source []byte) (*SchnorrSecretKV, error) {
 
 	decodedbin_s, err := hex.DecodeString(umkv.S)
 	if err != nil {
-		return nil, err
+		return nil, decodeError("secret key", err)
 	}
 
 	decodedbin_p, err := hex.DecodeString(umkv.P)
 	if err != nil {
-		return nil, err
+		return nil, decodeError("secret key", err)
 	}
 
-	var unmashalledScalar = suite.Scalar()
-	err = unmashalledScalar.UnmarshalBinary(decodedbin_s)
+	unmashalledScalar, err := decodeScalar(suite, "secret key", decodedbin_s)
 	if err != nil {
 		return nil, err
 	}
 
-	var unmashalledPoint = suite.Point()
-	err = unmashalledPoint.UnmarshalBinary(decodedbin_p)
+	unmashalledPoint, err := decodePoint(suite, "secret key", decodedbin_p)
 	if err != nil {
 		return nil, err
 	}
+	if !suite.Point().Mul(unmashalledScalar, nil).Equal(unmashalledPoint) {
+		return nil, decodeError("secret key", ErrInconsistentSecret)
+	}
+
+	// only derived keys record a chain code.
+	var chain []byte
+	if umkv.Chain != "" {
+		chain, err = hex.DecodeString(umkv.Chain)
+		if err != nil {
+			return nil, err
+		}
+		if len(chain) != hdChainSize {
+			return nil, errors.New("Invalid chain code length.")
+		}
+	}
 
-	return &SchnorrSecretKV{
+	kv := &SchnorrSecretKV{
 		suite: umkv.Suite,
 		s:     unmashalledScalar,
 		pP:    unmashalledPoint,
-	}, nil
+		chain: chain,
+	}
+
+	// files from before metadata have none.
+	if umkv.Meta != nil {
+		err = umkv.Meta.check(kv.GetPublicKeyset())
+		if err != nil {
+			return nil, err
+		}
+		kv.meta = umkv.Meta
+	}
+	return kv, nil
 }
 
 // Exports a SchorrPublicKV to a string that can be written
 // directly to a file. The string is a base64 encoded combination
-// of the suite used and the public key point.
+// of the suite used and the public key point, followed by the
+// metadata block if the key has one.
 func (p SchnorrPublicKV) Export() string {
 	// No JSON for this, let's save ourselves the effort
-	return p.suite + ";" + p.pP.String()
+	s := p.suite + ";" + p.pP.String()
+	if p.meta != nil {
+		meta, err := p.meta.exportPublic()
+		if err == nil {
+			s = s + ";" + meta
+		}
+	}
+	return s
 }
 
 // Reads a SchnorrPublicKV encoded as a string by its export method
 // or otherwise, and produces a SchnorrPublicKV value.
 func NewSchnorrPublicKeyFromString(source string) (*SchnorrPublicKV, error) {
-	splitsource := strings.Split(source, ";")
+	splitsource := strings.Split(strings.TrimSpace(source), ";")
+	if len(splitsource) != 2 && len(splitsource) != 3 {
+		return nil, errors.New("Invalid public key encoding.")
+	}
 	suiteid := splitsource[0]
 	encodedp := splitsource[1]
 
@@ -164,12 +289,10 @@ func NewSchnorrPublicKeyFromString(source string) (*SchnorrPublicKV, error) {
 
 	decodedbin_p, err := hex.DecodeString(encodedp)
 	if err != nil {
-		return nil, err
+		return nil, decodeError("public key", err)
 	}
 
-	var unmarshalledPoint = suite.Point()
-	err = unmarshalledPoint.UnmarshalBinary(decodedbin_p)
-
+	unmarshalledPoint, err := decodePoint(suite, "public key", decodedbin_p)
 	if err != nil {
 		return nil, err
 	}
@@ -179,5 +302,17 @@ func NewSchnorrPublicKeyFromString(source string) (*SchnorrPublicKV, error) {
 		pP:    unmarshalledPoint,
 	}
 
+	if len(splitsource) == 3 {
+		meta, err := importPublicMetadata(splitsource[2])
+		if err != nil {
+			return nil, err
+		}
+		err = meta.check(result)
+		if err != nil {
+			return nil, err
+		}
+		result.meta = meta
+	}
+
 	return &result, nil
 }
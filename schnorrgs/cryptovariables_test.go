@@ -1,6 +1,7 @@
 package schnorrgs
 
 import (
+	"github.com/dedis/kyber/group/edwards25519"
 	"testing"
 )
 
@@ -11,13 +12,82 @@ func TestSuiteInstantiation(t *testing.T) {
 		t.Fatalf("BlakeSHA256Ed25519 instantiation failed.")
 	}
 
-	//	nist, err := GetSuite("BlakeSHA256P256")
-	//	if err != nil {
-	//		t.Fatalf("BlakeSHA256P256 instantiation failed.")
-	//	}
+	_, err = GetSuite("BlakeSHA256P256")
+	if err != nil {
+		t.Fatalf("BlakeSHA256P256 instantiation failed.")
+	}
 
 	_, err = GetSuite("SEsgYXJlIGtub2JzLg==")
 	if err == nil {
 		t.Fatalf("Got a valid suite when passing junk.")
 	}
 }
+
+func TestSuiteRegistry(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, err := GetSuite(name)
+		if err != nil {
+			t.Fatalf("Registered suite %s cannot be instantiated.", name)
+		}
+		back, err := SuiteName(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if back != name {
+			t.Errorf("SuiteName returned %s for %s", back, name)
+		}
+	}
+
+	err := RegisterSuite("BlakeSHA256Ed25519", func() CryptoSuite {
+		return edwards25519.NewBlakeSHA256Ed25519()
+	})
+	if err == nil {
+		t.Error("Registering a suite name twice succeeded.")
+	}
+
+	err = RegisterSuite("AnotherEd25519", func() CryptoSuite {
+		return edwards25519.NewBlakeSHA256Ed25519()
+	})
+	if err == nil {
+		t.Error("Registering the same group under two names succeeded.")
+	}
+}
+
+func TestPublicKeyExportImport(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		pk := kv.GetPublicKeyset()
+
+		imported, err := NewSchnorrPublicKeyFromString(pk.Export())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if imported.Suite() != name {
+			t.Errorf("Imported key has suite %s, wanted %s",
+				imported.Suite(), name)
+		}
+		if imported.Export() != pk.Export() {
+			t.Error("Public key did not survive export/import.")
+		}
+
+		skv, err := NewSchnorrSecretKVFromImport(kv.Export())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if skv.Suite() != name {
+			t.Errorf("Imported secret key has suite %s, wanted %s",
+				skv.Suite(), name)
+		}
+	}
+
+	_, err := NewSchnorrPublicKeyFromString("junk")
+	if err == nil {
+		t.Error("Imported a public key from junk.")
+	}
+}
@@ -0,0 +1,187 @@
+package schnorrgs
+
+/* Strict decoding of everything we read from the wire or from disk.
+
+   Every encoding has exactly one accepted form: the length has to be
+   exact, scalars have to be reduced modulo the group order and points
+   have to be in their canonical encoding, so that nobody can make a
+   second, different looking copy of a signature or commitment. Points
+   also may not be the identity, nor have a component outside the prime
+   order subgroup. On edwards25519 such points let a peer contribute
+   something that vanishes, or leaks, once multiplied by the cofactor.
+
+   Decoders return a *SchnorrDecodeError wrapping one of the Err values
+   below, and never panic on short or malformed input.
+*/
+
+import (
+	"bytes"
+	"errors"
+	"github.com/dedis/kyber"
+)
+
+// Reasons a decoder refuses its input.
+var (
+	ErrDecodeLength       = errors.New("wrong length")
+	ErrNonCanonical       = errors.New("non-canonical encoding")
+	ErrInvalidPoint       = errors.New("not a point of the group")
+	ErrIdentityPoint      = errors.New("identity point")
+	ErrSmallOrderPoint    = errors.New("point outside the prime order subgroup")
+	ErrInconsistentSecret = errors.New("public part does not match the secret")
+)
+
+// The error of a failed decoding. Type names what was being decoded and
+// Err is the reason, one of the Err values above or the error of a hex
+// or JSON decoder.
+type SchnorrDecodeError struct {
+	Type string
+	Err  error
+}
+
+func (e *SchnorrDecodeError) Error() string {
+	return "Invalid " + e.Type + ": " + e.Err.Error()
+}
+
+// Lets errors.Is find the reason.
+func (e *SchnorrDecodeError) Unwrap() error {
+	return e.Err
+}
+
+func decodeError(what string, err error) error {
+	return &SchnorrDecodeError{Type: what, Err: err}
+}
+
+// Tells whether P lies in the prime order subgroup. Scaling by 8^-1 and
+// back by 8 clears any small order component, and does nothing in groups
+// without a cofactor.
+func inPrimeOrderSubgroup(suite CryptoSuite, P kyber.Point) bool {
+	eight := suite.Scalar().SetInt64(8)
+	cleared := suite.Point().Mul(suite.Scalar().Inv(eight), P)
+	cleared.Mul(eight, cleared)
+	return cleared.Equal(P)
+}
+
+// Checks a point we did not make ourselves: neither the identity nor
+// outside the prime order subgroup.
+func checkPoint(suite CryptoSuite, what string, P kyber.Point) error {
+	if P.Equal(suite.Point().Null()) {
+		return decodeError(what, ErrIdentityPoint)
+	}
+	if !inPrimeOrderSubgroup(suite, P) {
+		return decodeError(what, ErrSmallOrderPoint)
+	}
+	return nil
+}
+
+func decodeScalar(suite CryptoSuite, what string, b []byte) (kyber.Scalar,
+	error) {
+
+	s := suite.Scalar()
+	if len(b) != s.MarshalSize() {
+		return nil, decodeError(what, ErrDecodeLength)
+	}
+	err := s.UnmarshalBinary(b)
+	if err != nil {
+		return nil, decodeError(what, ErrNonCanonical)
+	}
+	// adding zero reduces the scalar; a canonical one is unchanged.
+	reduced, err := suite.Scalar().Add(s, suite.Scalar().Zero()).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(reduced, b) {
+		return nil, decodeError(what, ErrNonCanonical)
+	}
+	return s, nil
+}
+
+func decodePoint(suite CryptoSuite, what string, b []byte) (kyber.Point,
+	error) {
+
+	P := suite.Point()
+	if len(b) != P.MarshalSize() {
+		return nil, decodeError(what, ErrDecodeLength)
+	}
+	err := P.UnmarshalBinary(b)
+	if err != nil {
+		return nil, decodeError(what, ErrInvalidPoint)
+	}
+	encoded, err := P.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(encoded, b) {
+		return nil, decodeError(what, ErrNonCanonical)
+	}
+	err = checkPoint(suite, what, P)
+	if err != nil {
+		return nil, err
+	}
+	return P, nil
+}
+
+// Decodes a scalar, which has to be exactly one canonical encoding.
+func DecodeScalar(suite CryptoSuite, b []byte) (kyber.Scalar, error) {
+	return decodeScalar(suite, "scalar", b)
+}
+
+// Decodes a point, which has to be exactly one canonical encoding of a
+// point of the prime order subgroup other than the identity.
+func DecodePoint(suite CryptoSuite, b []byte) (kyber.Point, error) {
+	return decodePoint(suite, "point", b)
+}
+
+// Reads scalars and points one after the other from an encoding,
+// remembering the first error.
+type strictDecoder struct {
+	suite CryptoSuite
+	what  string
+	b     []byte
+	err   error
+}
+
+func newStrictDecoder(suite CryptoSuite, what string,
+	b []byte) *strictDecoder {
+	return &strictDecoder{suite: suite, what: what, b: b}
+}
+
+func (d *strictDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.b) < n {
+		d.err = decodeError(d.what, ErrDecodeLength)
+		return nil
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b
+}
+
+func (d *strictDecoder) scalar() kyber.Scalar {
+	b := d.next(d.suite.Scalar().MarshalSize())
+	if d.err != nil {
+		return nil
+	}
+	s, err := decodeScalar(d.suite, d.what, b)
+	d.err = err
+	return s
+}
+
+func (d *strictDecoder) point() kyber.Point {
+	b := d.next(d.suite.Point().MarshalSize())
+	if d.err != nil {
+		return nil
+	}
+	P, err := decodePoint(d.suite, d.what, b)
+	d.err = err
+	return P
+}
+
+// Returns the first error, or an error if anything is left over.
+func (d *strictDecoder) finish() error {
+	if d.err == nil && len(d.b) != 0 {
+		d.err = decodeError(d.what, ErrDecodeLength)
+	}
+	return d.err
+}
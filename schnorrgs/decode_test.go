@@ -0,0 +1,201 @@
+package schnorrgs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// Valid encodings of every binary type, with their decoders.
+func decodeCases(t *testing.T, suite CryptoSuite) map[string]struct {
+	b      []byte
+	decode func([]byte) error
+} {
+	kv, _ := SchnorrGenerateKeypair(suite)
+	msg := []byte("strict")
+
+	sig, _ := SchnorrSign(suite, kv, msg)
+	sigb, _ := sig.Encode()
+	rsb, _ := sig.EncodeRS()
+	_, T := SchnorrAdaptorGenerateSecret(suite)
+	presig, _ := SchnorrAdaptorPreSign(suite, kv, T, nil, msg, NonceDeterministic)
+	preb, _ := presig.Encode()
+	// a ring of one: a larger ring's signature less one scalar would
+	// be a valid signature for a smaller ring.
+	ringsig, err := SchnorrRingSign(suite, kv,
+		[]SchnorrPublicKV{kv.GetPublicKeyset()}, nil, msg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ringb, _ := ringsig.Encode()
+	commit := SchnorrMSGenerateCommitment(suite)
+	commitb, _ := commit.MarshalBinary()
+	pubb, _ := commit.GetPublicCommitment().MarshalBinary()
+	frostb, _ := SchnorrFROSTCommitment{Index: 3, D: commit.T,
+		E: kv.pP}.MarshalBinary()
+	signersb, _ := SchnorrTSEncodeSigners([]int{1, 2, 5})
+
+	return map[string]struct {
+		b      []byte
+		decode func([]byte) error
+	}{
+		"signature": {sigb, func(b []byte) error {
+			_, err := DecodeSchnorrSignature(suite, b)
+			return err
+		}},
+		"(R, s) signature": {rsb, func(b []byte) error {
+			_, err := DecodeSchnorrSignatureRS(suite, b)
+			return err
+		}},
+		"pre-signature": {preb, func(b []byte) error {
+			_, err := DecodeSchnorrAdaptorPreSignature(suite, b)
+			return err
+		}},
+		"ring signature": {ringb, func(b []byte) error {
+			_, err := DecodeSchnorrRingSignature(suite, b)
+			return err
+		}},
+		"commitment": {commitb, func(b []byte) error {
+			var c SchnorrMSCommitment
+			return c.UnmarshalBinary(suite, b)
+		}},
+		"public commitment": {pubb, func(b []byte) error {
+			var c SchnorrMSPublicCommitment
+			return c.UnmarshalBinary(suite, b)
+		}},
+		"FROST commitment": {frostb, func(b []byte) error {
+			var c SchnorrFROSTCommitment
+			return c.UnmarshalBinary(suite, b)
+		}},
+		"signer list": {signersb, func(b []byte) error {
+			_, err := SchnorrTSDecodeSigners(b)
+			return err
+		}},
+	}
+}
+
+func TestDecodeExactLengths(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		for what, c := range decodeCases(t, suite) {
+			err := c.decode(c.b)
+			if err != nil {
+				t.Fatalf("%s %s: %s", name, what, err.Error())
+			}
+			// every truncation and an extension; none may panic.
+			for n := 0; n < len(c.b); n++ {
+				if c.decode(c.b[:n]) == nil {
+					t.Errorf("%s %s: decoded %d of %d bytes", name, what,
+						n, len(c.b))
+				}
+			}
+			err = c.decode(append(append([]byte{}, c.b...), 0))
+			if !errors.Is(err, ErrDecodeLength) {
+				t.Errorf("%s %s: trailing byte gave %v", name, what, err)
+			}
+			var derr *SchnorrDecodeError
+			if !errors.As(err, &derr) || derr.Type != what {
+				t.Errorf("%s %s: error is not a decode error of its type",
+					name, what)
+			}
+		}
+	}
+}
+
+func TestDecodeNonCanonicalScalars(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+		sig, _ := SchnorrSign(suite, kv, []byte("scalars"))
+		b, _ := sig.Encode()
+
+		// all ones is above the order in both byte orders.
+		size := suite.Scalar().MarshalSize()
+		high := bytes.Repeat([]byte{0xff}, size)
+		_, err := DecodeScalar(suite, high)
+		if !errors.Is(err, ErrNonCanonical) {
+			t.Errorf("%s: unreduced scalar gave %v", name, err)
+		}
+		_, err = DecodeSchnorrSignature(suite, append(high, b[size:]...))
+		if !errors.Is(err, ErrNonCanonical) {
+			t.Errorf("%s: signature with an unreduced s gave %v", name, err)
+		}
+		_, err = DecodeScalar(suite, b[:size])
+		if err != nil {
+			t.Errorf("%s: canonical scalar refused: %s", name, err.Error())
+		}
+	}
+}
+
+func TestDecodeRejectsBadPoints(t *testing.T) {
+
+	suite, _ := GetSuite("BlakeSHA256Ed25519")
+	identity, _ := suite.Point().Null().MarshalBinary()
+	// y = 0 is a point of order 4.
+	small := make([]byte, 32)
+	T := suite.Point()
+	if T.UnmarshalBinary(small) != nil {
+		t.Fatal("Order 4 point did not decode")
+	}
+	mixed, _ := suite.Point().Add(suite.Point().Base(), T).MarshalBinary()
+	// y = p + 1, another encoding of the identity's y.
+	noncanonical, _ := hex.DecodeString(
+		"eeffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f")
+
+	cases := []struct {
+		b    []byte
+		want error
+	}{
+		{identity, ErrIdentityPoint},
+		{small, ErrSmallOrderPoint},
+		{mixed, ErrSmallOrderPoint},
+		{noncanonical, ErrNonCanonical},
+		{identity[:31], ErrDecodeLength},
+	}
+	for _, c := range cases {
+		_, err := DecodePoint(suite, c.b)
+		if !errors.Is(err, c.want) {
+			t.Errorf("%x: got %v, expected %v", c.b, err, c.want)
+		}
+		var pc SchnorrMSPublicCommitment
+		if !errors.Is(pc.UnmarshalBinary(suite, c.b), c.want) {
+			t.Errorf("%x: public commitment not refused", c.b)
+		}
+		_, err = NewSchnorrPublicKeyFromString("BlakeSHA256Ed25519;" +
+			hex.EncodeToString(c.b))
+		if !errors.Is(err, c.want) {
+			t.Errorf("%x: public key not refused", c.b)
+		}
+	}
+
+	good, _ := suite.Point().Base().MarshalBinary()
+	_, err := DecodePoint(suite, good)
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestDecodeSecretKeyConsistency(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+	other, _ := SchnorrGenerateKeypair(suite)
+
+	mixed := kv
+	mixed.pP = other.pP
+	_, err := NewSchnorrSecretKVFromImport(mixed.Export())
+	if !errors.Is(err, ErrInconsistentSecret) {
+		t.Errorf("Key file with another public key gave %v", err)
+	}
+
+	commit := SchnorrMSGenerateCommitment(suite)
+	commit.T = other.pP
+	b, _ := commit.MarshalBinary()
+	var decoded SchnorrMSCommitment
+	if !errors.Is(decoded.UnmarshalBinary(suite, b), ErrInconsistentSecret) {
+		t.Error("Commitment with another point decoded")
+	}
+}
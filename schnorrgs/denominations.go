@@ -0,0 +1,282 @@
+package schnorrgs
+
+/* This file implements the denomination catalogue of a blind signing
+   server. A bank issuing coins of several values signs every one of them
+   with the same key, and tells them apart by the info agreed in the
+   partially blind signature: each denomination has its own info. The
+   catalogue lists them, with their face value and expiry, and is signed
+   by the bank key so that a client can check the info it is about to
+   blind against before it spends anything on a coin.
+
+   The catalogue is JSON with a version number; the signature covers a
+   fixed binary encoding of everything else in it, made under its own
+   protocol tag (see domain.go).
+*/
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Version of the catalogue we write.
+const DenominationCatalogueVersion = 1
+
+// Reasons a catalogue has no usable denomination for an ID.
+var (
+	ErrUnknownDenomination = errors.New("unknown denomination")
+	ErrDenominationExpired = errors.New("denomination has expired")
+)
+
+// One denomination: its ID, the info its coins are signed with, in hex,
+// and their face value. Denominations without Expires never expire.
+type SchnorrDenomination struct {
+	ID        string
+	Info      string
+	FaceValue uint64
+	Expires   *time.Time `json:",omitempty"`
+}
+
+// Represents a catalogue of denominations signed by the key KeyID of
+// suite Suite. Signature is the hex encoded signature, empty until the
+// catalogue is signed.
+type SchnorrDenominationCatalogue struct {
+	Version       int
+	Suite         string
+	KeyID         string
+	Issued        time.Time
+	Denominations []SchnorrDenomination
+	Signature     string `json:",omitempty"`
+}
+
+// Makes a denomination. A zero expires never expires.
+func NewSchnorrDenomination(id string, info []byte, facevalue uint64,
+	expires time.Time) SchnorrDenomination {
+
+	d := SchnorrDenomination{ID: id, Info: hex.EncodeToString(info),
+		FaceValue: facevalue}
+	if !expires.IsZero() {
+		e := expires.UTC().Truncate(time.Second)
+		d.Expires = &e
+	}
+	return d
+}
+
+// Returns the info coins of the denomination are signed with.
+func (d SchnorrDenomination) GetInfo() ([]byte, error) {
+	info, err := hex.DecodeString(d.Info)
+	if err != nil {
+		return nil, decodeError("denomination info", err)
+	}
+	return info, nil
+}
+
+// Tells whether the denomination has expired at now.
+func (d SchnorrDenomination) Expired(now time.Time) bool {
+	return d.Expires != nil && now.After(*d.Expires)
+}
+
+// Checks the catalogue is one we can use: a known version, and IDs and
+// infos that are all present and different. Two denominations with the
+// same info could not be told apart once signed.
+func (c SchnorrDenominationCatalogue) check() error {
+	if c.Version != DenominationCatalogueVersion {
+		return errors.New("Unknown catalogue version.")
+	}
+	if len(c.Denominations) == 0 {
+		return errors.New("Catalogue has no denominations.")
+	}
+	ids := make(map[string]bool)
+	infos := make(map[string]bool)
+	for _, d := range c.Denominations {
+		if d.ID == "" || len(d.ID) > 255 {
+			return errors.New("Denomination IDs have to be 1 to 255 bytes.")
+		}
+		if ids[d.ID] {
+			return errors.New("Denomination " + d.ID + " is listed twice.")
+		}
+		ids[d.ID] = true
+		info, err := d.GetInfo()
+		if err != nil {
+			return err
+		}
+		if len(info) == 0 {
+			return errors.New("Denomination " + d.ID + " has no info.")
+		}
+		if infos[string(info)] {
+			return errors.New("Denomination " + d.ID +
+				" has the info of another denomination.")
+		}
+		infos[string(info)] = true
+	}
+	return nil
+}
+
+// Builds the message the catalogue signature covers:
+// version || len(suite) || suite || len(keyid) || keyid || issued ||
+// count, then for every denomination
+// len(id) || id || len(info) || info || facevalue || expires,
+// with times in seconds since the epoch, 0 for never, and every number
+// big endian.
+func (c SchnorrDenominationCatalogue) message() ([]byte, error) {
+	u32 := func(msg []byte, n int) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(n))
+		return append(msg, b...)
+	}
+	u64 := func(msg []byte, n uint64) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, n)
+		return append(msg, b...)
+	}
+
+	msg := u32(nil, c.Version)
+	msg = append(msg, byte(len(c.Suite)))
+	msg = append(msg, []byte(c.Suite)...)
+	msg = append(msg, byte(len(c.KeyID)))
+	msg = append(msg, []byte(c.KeyID)...)
+	msg = u64(msg, uint64(c.Issued.Unix()))
+	msg = u32(msg, len(c.Denominations))
+	for _, d := range c.Denominations {
+		info, err := d.GetInfo()
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, byte(len(d.ID)))
+		msg = append(msg, []byte(d.ID)...)
+		msg = u32(msg, len(info))
+		msg = append(msg, info...)
+		msg = u64(msg, d.FaceValue)
+		var expires uint64
+		if d.Expires != nil {
+			expires = uint64(d.Expires.Unix())
+		}
+		msg = u64(msg, expires)
+	}
+	return msg, nil
+}
+
+// Makes a catalogue of denominations, issued now and signed with kv.
+func SchnorrSignDenominationCatalogue(suite CryptoSuite, kv SchnorrSecretKV,
+	denominations []SchnorrDenomination) (SchnorrDenominationCatalogue,
+	error) {
+
+	id, err := SchnorrKeyID(kv.GetPublicKeyset())
+	if err != nil {
+		return SchnorrDenominationCatalogue{}, err
+	}
+	c := SchnorrDenominationCatalogue{
+		Version:       DenominationCatalogueVersion,
+		Suite:         kv.Suite(),
+		KeyID:         id,
+		Issued:        time.Now().UTC().Truncate(time.Second),
+		Denominations: denominations,
+	}
+	err = c.check()
+	if err != nil {
+		return SchnorrDenominationCatalogue{}, err
+	}
+	msg, err := c.message()
+	if err != nil {
+		return SchnorrDenominationCatalogue{}, err
+	}
+	sig, err := schnorrSignTagged(suite, kv, catalogueSignatureTag, nil, msg,
+		NonceHedged)
+	if err != nil {
+		return SchnorrDenominationCatalogue{}, err
+	}
+	b, err := sig.Encode()
+	if err != nil {
+		return SchnorrDenominationCatalogue{}, err
+	}
+	c.Signature = hex.EncodeToString(b)
+	return c, nil
+}
+
+// Checks the catalogue is well formed and signed by pk.
+func (c SchnorrDenominationCatalogue) Verify(suite CryptoSuite,
+	pk SchnorrPublicKV) error {
+
+	err := c.check()
+	if err != nil {
+		return err
+	}
+	id, err := SchnorrKeyID(pk)
+	if err != nil {
+		return err
+	}
+	if c.Suite != pk.Suite() || c.KeyID != id {
+		return errors.New("Catalogue was not made for key " + id)
+	}
+	b, err := hex.DecodeString(c.Signature)
+	if err != nil {
+		return decodeError("catalogue signature", err)
+	}
+	sig, err := DecodeSchnorrSignature(suite, b)
+	if err != nil {
+		return err
+	}
+	msg, err := c.message()
+	if err != nil {
+		return err
+	}
+	ok, err := schnorrVerifyTagged(suite, pk, catalogueSignatureTag, nil, msg,
+		sig)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("Catalogue signature does not verify.")
+	}
+	return nil
+}
+
+// Finds the denomination with the given ID, refusing it if it has
+// expired at now.
+func (c SchnorrDenominationCatalogue) Lookup(id string,
+	now time.Time) (SchnorrDenomination, error) {
+
+	for _, d := range c.Denominations {
+		if d.ID != id {
+			continue
+		}
+		if d.Expired(now) {
+			return SchnorrDenomination{}, ErrDenominationExpired
+		}
+		return d, nil
+	}
+	return SchnorrDenomination{}, ErrUnknownDenomination
+}
+
+// Finds the denomination whose coins are signed with info, refusing it if
+// it has expired at now.
+func (c SchnorrDenominationCatalogue) LookupInfo(info []byte,
+	now time.Time) (SchnorrDenomination, error) {
+
+	encoded := hex.EncodeToString(info)
+	for _, d := range c.Denominations {
+		if d.Info == encoded {
+			return c.Lookup(d.ID, now)
+		}
+	}
+	return SchnorrDenomination{}, ErrUnknownDenomination
+}
+
+// Encodes the catalogue as JSON, the form it is stored and sent in.
+func (c SchnorrDenominationCatalogue) Export() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// Parses a catalogue written by Export. It still has to be verified.
+func NewSchnorrDenominationCatalogueFromJSON(
+	b []byte) (*SchnorrDenominationCatalogue, error) {
+
+	var c SchnorrDenominationCatalogue
+	err := json.Unmarshal(b, &c)
+	if err != nil {
+		return nil, decodeError("denomination catalogue", err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,120 @@
+package schnorrgs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func testDenominations() []SchnorrDenomination {
+	return []SchnorrDenomination{
+		NewSchnorrDenomination("1", []byte("one coin"), 1, time.Time{}),
+		NewSchnorrDenomination("5", []byte("five coin"), 5,
+			time.Now().Add(24*time.Hour)),
+		NewSchnorrDenomination("old", []byte("old coin"), 10,
+			time.Now().Add(-time.Hour)),
+	}
+}
+
+func TestDenominationCatalogueRoundTrip(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+		c, err := SchnorrSignDenominationCatalogue(suite, kv, testDenominations())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		b, err := c.Export()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		decoded, err := NewSchnorrDenominationCatalogueFromJSON(b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		err = decoded.Verify(suite, kv.GetPublicKeyset())
+		if err != nil {
+			t.Fatalf("%s: %s", name, err.Error())
+		}
+
+		d, err := decoded.Lookup("5", time.Now())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		info, _ := d.GetInfo()
+		if string(info) != "five coin" || d.FaceValue != 5 {
+			t.Errorf("%s: denomination changed in a round trip", name)
+		}
+		_, err = decoded.Lookup("old", time.Now())
+		if err != ErrDenominationExpired {
+			t.Errorf("%s: expired denomination gave %v", name, err)
+		}
+		_, err = decoded.Lookup("2", time.Now())
+		if err != ErrUnknownDenomination {
+			t.Errorf("%s: unknown denomination gave %v", name, err)
+		}
+
+		other, _ := SchnorrGenerateKeypair(suite)
+		if decoded.Verify(suite, other.GetPublicKeyset()) == nil {
+			t.Errorf("%s: catalogue verified under another key", name)
+		}
+	}
+}
+
+func TestDenominationCatalogueTampering(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+	pk := kv.GetPublicKeyset()
+
+	tamper := []func(c *SchnorrDenominationCatalogue){
+		func(c *SchnorrDenominationCatalogue) { c.Denominations[0].FaceValue = 100 },
+		func(c *SchnorrDenominationCatalogue) { c.Denominations[1].Info = "00" },
+		func(c *SchnorrDenominationCatalogue) { c.Denominations[1].Expires = nil },
+		func(c *SchnorrDenominationCatalogue) { c.Denominations[0].ID = "100" },
+		func(c *SchnorrDenominationCatalogue) { c.Denominations = c.Denominations[1:] },
+		func(c *SchnorrDenominationCatalogue) { c.Issued = c.Issued.Add(time.Hour) },
+		func(c *SchnorrDenominationCatalogue) { c.Signature = "" },
+	}
+	for i, f := range tamper {
+		c, err := SchnorrSignDenominationCatalogue(suite, kv, testDenominations())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		f(&c)
+		if c.Verify(suite, pk) == nil {
+			t.Errorf("Tampered catalogue %d verified", i)
+		}
+	}
+
+	// the signature field is hex; garbage is a decode error.
+	c, _ := SchnorrSignDenominationCatalogue(suite, kv, testDenominations())
+	c.Signature = "zz"
+	var derr *SchnorrDecodeError
+	if !errors.As(c.Verify(suite, pk), &derr) {
+		t.Error("Bad signature hex is not a decode error")
+	}
+}
+
+func TestDenominationCatalogueRefusesAmbiguity(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+
+	cases := [][]SchnorrDenomination{
+		nil,
+		{NewSchnorrDenomination("", []byte("a"), 1, time.Time{})},
+		{NewSchnorrDenomination("1", nil, 1, time.Time{})},
+		{NewSchnorrDenomination("1", []byte("a"), 1, time.Time{}),
+			NewSchnorrDenomination("1", []byte("b"), 2, time.Time{})},
+		{NewSchnorrDenomination("1", []byte("a"), 1, time.Time{}),
+			NewSchnorrDenomination("2", []byte("a"), 2, time.Time{})},
+	}
+	for i, denominations := range cases {
+		_, err := SchnorrSignDenominationCatalogue(suite, kv, denominations)
+		if err == nil {
+			t.Errorf("Catalogue %d was signed", i)
+		}
+	}
+}
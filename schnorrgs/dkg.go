@@ -0,0 +1,655 @@
+package schnorrgs
+
+/* This file implements dealerless distributed key generation, so that a
+   threshold group can get its key without anybody ever knowing all of it.
+
+   It is the joint-Feldman protocol of Pedersen with a complaint phase.
+   Each of the n participants acts as a dealer: it picks a random
+   polynomial f_i of degree t-1, publishes commitments C_ik = a_ik G to its
+   coefficients and sends f_i(j) privately to each participant j. Anyone
+   whose share does not match the commitments complains; the accused dealer
+   must then reveal that share publicly, and is disqualified if it does not
+   or if the revealed share is wrong too. With QUAL the dealers that
+   survive, participant j's share of the group key is sum f_i(j) and the
+   group key is sum C_i0, both over QUAL.
+
+   Participants are identified by long-term keys (e.g. from keytool gen)
+   and numbered from 1 in the order they are listed, which is also their
+   share index. Every message is signed by its sender's long-term key and
+   bound to a session identifier, so whoever relays the messages can
+   neither forge nor replay them. Private shares are encrypted under a key
+   derived from a Diffie-Hellman exchange between the long-term keys of
+   dealer and recipient. That key is the same whenever a relay reuses a
+   session, so every share is sealed under a fresh random nonce, sent in
+   front of the ciphertext.
+
+   The relay has to hand everybody the same messages. Each participant
+   therefore reports a hash of everything it saw along with its result,
+   and the results must only be used if all of those hashes agree.
+*/
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/dedis/kyber"
+	"github.com/dedis/kyber/share"
+	"golang.org/x/crypto/blake2b"
+	"sort"
+	"strconv"
+)
+
+// Prefixes of the hashes and signatures used by the DKG.
+const (
+	dkgSignatureTag  = "schnorrgs-dkg-message-v1"
+	dkgEncryptionTag = "schnorrgs-dkg-share-key-v2"
+	dkgTranscriptTag = "schnorrgs-dkg-transcript-v1"
+)
+
+// The kinds of message exchanged during a DKG, one per round.
+const (
+	DKGDealKind          = "deal"
+	DKGComplaintKind     = "complaint"
+	DKGJustificationKind = "justification"
+	DKGResultKind        = "result"
+)
+
+// A message from one participant, signed with its long-term key over the
+// session, its index, the kind and the body.
+type SchnorrDKGSigned struct {
+	Sender    int
+	Kind      string
+	Body      []byte
+	Signature []byte
+}
+
+// A dealer's commitments to its polynomial and the encrypted shares,
+// Shares[j-1] being for participant j, each as nonce || ciphertext.
+type SchnorrDKGDeal struct {
+	Commitments [][]byte
+	Shares      [][]byte
+}
+
+// The dealers whose share to the sender did not check out.
+type SchnorrDKGComplaint struct {
+	Accused []int
+}
+
+// A dealer's answer to complaints: the plain shares of the complainers,
+// keyed by their index.
+type SchnorrDKGJustification struct {
+	Shares map[int][]byte
+}
+
+// What a participant ended up with. Everything but the share itself is
+// public: the group key, the share key and its proof of possession, the
+// qualified dealers and the hash of all the messages seen.
+type SchnorrDKGResult struct {
+	GroupKey   string
+	ShareKey   string
+	PoP        string
+	Qualified  []int
+	Transcript []byte
+}
+
+// What we know about one dealer.
+type dkgDealer struct {
+	commits []kyber.Point
+	share   kyber.Scalar // our share, once it checks out
+	bad     bool         // disqualified
+}
+
+// One participant's state during a DKG.
+type SchnorrDKG struct {
+	suite        CryptoSuite
+	suitename    string
+	session      []byte
+	t            int
+	index        int
+	identity     SchnorrSecretKV
+	participants []SchnorrPublicKV
+	poly         *share.PriPoly
+	dealers      map[int]*dkgDealer
+	complaints   map[int][]int // accused -> complainers
+	transcript   [][]byte
+	step         int
+}
+
+// Starts a DKG as the participant holding identity, among the given
+// participants (which must include identity), for a t-of-n group. All
+// participants must use the same session, which should be fresh and
+// random for every run.
+func NewSchnorrDKG(suite CryptoSuite, identity SchnorrSecretKV,
+	participants []SchnorrPublicKV, t int, session []byte) (*SchnorrDKG, error) {
+
+	err := checkSuite(suite, identity.suite)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := SuiteName(suite)
+	err = checkKeySuites(name, participants)
+	if err != nil {
+		return nil, err
+	}
+	if t < 1 || t > len(participants) {
+		return nil, errors.New("Threshold must be between 1 and the number " +
+			"of participants.")
+	}
+	if len(session) == 0 {
+		return nil, errors.New("DKG needs a session identifier.")
+	}
+
+	index := 0
+	for k, p := range participants {
+		for _, q := range participants[:k] {
+			if p.pP.Equal(q.pP) {
+				return nil, errors.New("Participant listed twice.")
+			}
+		}
+		if p.pP.Equal(identity.pP) {
+			index = k + 1
+		}
+	}
+	if index == 0 {
+		return nil, errors.New("Our key is not among the participants.")
+	}
+
+	return &SchnorrDKG{suite: suite, suitename: name, session: session,
+		t: t, index: index, identity: identity, participants: participants,
+		dealers: make(map[int]*dkgDealer), complaints: make(map[int][]int)}, nil
+}
+
+// Returns our index, which is also our share index.
+func (d *SchnorrDKG) Index() int {
+	return d.index
+}
+
+// Returns the message a signature covers.
+func (d *SchnorrDKG) signedMessage(sender int, kind string, body []byte) []byte {
+	var lenbuf [8]byte
+	msg := []byte(dkgSignatureTag)
+	binary.BigEndian.PutUint64(lenbuf[:], uint64(len(d.session)))
+	msg = append(msg, lenbuf[:]...)
+	msg = append(msg, d.session...)
+	binary.BigEndian.PutUint64(lenbuf[:], uint64(sender))
+	msg = append(msg, lenbuf[:]...)
+	binary.BigEndian.PutUint64(lenbuf[:], uint64(len(kind)))
+	msg = append(msg, lenbuf[:]...)
+	msg = append(msg, []byte(kind)...)
+	return append(msg, body...)
+}
+
+// Encodes body and signs it as ours.
+func (d *SchnorrDKG) sign(kind string, body interface{}) (SchnorrDKGSigned, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return SchnorrDKGSigned{}, err
+	}
+	sig, err := schnorrSignTagged(d.suite, d.identity, dkgSignatureTag, nil,
+		d.signedMessage(d.index, kind, b), NonceHedged)
+	if err != nil {
+		return SchnorrDKGSigned{}, err
+	}
+	bsig, err := sig.Encode()
+	if err != nil {
+		return SchnorrDKGSigned{}, err
+	}
+	return SchnorrDKGSigned{Sender: d.index, Kind: kind, Body: b,
+		Signature: bsig}, nil
+}
+
+// Checks the signature on m and decodes its body into body.
+func (d *SchnorrDKG) open(m SchnorrDKGSigned, kind string,
+	body interface{}) error {
+
+	if m.Kind != kind {
+		return errors.New("Expected a " + kind + " message, got " + m.Kind)
+	}
+	if m.Sender < 1 || m.Sender > len(d.participants) {
+		return errors.New("Message from unknown participant " +
+			strconv.Itoa(m.Sender))
+	}
+	if len(m.Signature) != 2*d.suite.Scalar().MarshalSize() {
+		return errors.New("Invalid signature length.")
+	}
+	sig, err := DecodeSchnorrSignature(d.suite, m.Signature)
+	if err != nil {
+		return err
+	}
+	ok, err := schnorrVerifyTagged(d.suite, d.participants[m.Sender-1],
+		dkgSignatureTag, nil, d.signedMessage(m.Sender, kind, m.Body), sig)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("Bad signature on message from participant " +
+			strconv.Itoa(m.Sender))
+	}
+	return json.Unmarshal(m.Body, body)
+}
+
+// Sorts the messages of a round by sender, checks there is at most one
+// per sender, and records them in the transcript.
+func (d *SchnorrDKG) collect(msgs []SchnorrDKGSigned) ([]SchnorrDKGSigned, error) {
+	sorted := append([]SchnorrDKGSigned(nil), msgs...)
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Sender < sorted[b].Sender
+	})
+	for k := 1; k < len(sorted); k++ {
+		if sorted[k].Sender == sorted[k-1].Sender {
+			return nil, errors.New("Two messages from participant " +
+				strconv.Itoa(sorted[k].Sender))
+		}
+	}
+	for _, m := range sorted {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		d.transcript = append(d.transcript, b)
+	}
+	return sorted, nil
+}
+
+// Derives the key that encrypts dealer's share for recipient, from the
+// Diffie-Hellman point of their long-term keys.
+func (d *SchnorrDKG) shareCipher(dealer int, recipient int,
+	other SchnorrPublicKV) (cipher.AEAD, error) {
+
+	dh, err := d.suite.Point().Mul(d.identity.s, other.pP).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var idx [16]byte
+	binary.BigEndian.PutUint64(idx[:8], uint64(dealer))
+	binary.BigEndian.PutUint64(idx[8:], uint64(recipient))
+
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		return nil, err
+	}
+	h.Write([]byte(dkgEncryptionTag))
+	h.Write(d.session)
+	h.Write(idx[:])
+	h.Write(dh)
+
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Round one: pick our polynomial and deal it out.
+func (d *SchnorrDKG) Deal() (SchnorrDKGSigned, error) {
+	if d.step != 0 {
+		return SchnorrDKGSigned{}, errors.New("DKG rounds out of order.")
+	}
+	d.step++
+
+	d.poly = share.NewPriPoly(d.suite, d.t, nil, d.suite.RandomStream())
+	_, commits := d.poly.Commit(nil).Info()
+
+	var deal SchnorrDKGDeal
+	for _, C := range commits {
+		b, err := C.MarshalBinary()
+		if err != nil {
+			return SchnorrDKGSigned{}, err
+		}
+		deal.Commitments = append(deal.Commitments, b)
+	}
+
+	for k, s := range d.poly.Shares(len(d.participants)) {
+		j := k + 1
+		if j == d.index {
+			deal.Shares = append(deal.Shares, nil)
+			continue
+		}
+		aead, err := d.shareCipher(d.index, j, d.participants[k])
+		if err != nil {
+			return SchnorrDKGSigned{}, err
+		}
+		b, err := s.V.MarshalBinary()
+		if err != nil {
+			return SchnorrDKGSigned{}, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		d.suite.RandomStream().XORKeyStream(nonce, nonce)
+		deal.Shares = append(deal.Shares, aead.Seal(nonce, nonce, b, nil))
+	}
+
+	return d.sign(DKGDealKind, deal)
+}
+
+// Checks share against the dealer's commitments at our index.
+func (d *SchnorrDKG) checkShare(commits []kyber.Point, index int,
+	s kyber.Scalar) bool {
+
+	pub := share.NewPubPoly(d.suite, nil, commits)
+	return pub.Check(&share.PriShare{I: index - 1, V: s})
+}
+
+// Decodes a dealer's commitments, which must be t points.
+func (d *SchnorrDKG) decodeCommits(deal SchnorrDKGDeal) ([]kyber.Point, error) {
+	if len(deal.Commitments) != d.t {
+		return nil, errors.New("Wrong number of commitments.")
+	}
+	var commits []kyber.Point
+	for _, b := range deal.Commitments {
+		C, err := decodePoint(d.suite, "DKG commitment", b)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, C)
+	}
+	return commits, nil
+}
+
+// Round two: check everybody's deal, including our own, and complain
+// about the dealers whose share to us is wrong. Dealers whose deal is
+// missing or malformed are disqualified outright, as everybody can see
+// that for themselves.
+func (d *SchnorrDKG) ProcessDeals(deals []SchnorrDKGSigned) (SchnorrDKGSigned, error) {
+	if d.step != 1 {
+		return SchnorrDKGSigned{}, errors.New("DKG rounds out of order.")
+	}
+	d.step++
+
+	deals, err := d.collect(deals)
+	if err != nil {
+		return SchnorrDKGSigned{}, err
+	}
+
+	for i := range d.participants {
+		d.dealers[i+1] = &dkgDealer{bad: true}
+	}
+
+	var complaint SchnorrDKGComplaint
+	for _, m := range deals {
+		var deal SchnorrDKGDeal
+		err := d.open(m, DKGDealKind, &deal)
+		if err != nil {
+			continue
+		}
+		commits, err := d.decodeCommits(deal)
+		if err != nil || len(deal.Shares) != len(d.participants) {
+			continue
+		}
+		dealer := d.dealers[m.Sender]
+		dealer.commits = commits
+		dealer.bad = false
+
+		var s kyber.Scalar
+		if m.Sender == d.index {
+			s = d.poly.Eval(d.index - 1).V
+		} else {
+			s, err = d.decryptShare(m.Sender, deal.Shares[d.index-1])
+		}
+		if err != nil || !d.checkShare(commits, d.index, s) {
+			complaint.Accused = append(complaint.Accused, m.Sender)
+			continue
+		}
+		dealer.share = s
+	}
+
+	return d.sign(DKGComplaintKind, complaint)
+}
+
+// Decrypts the share dealer sent us.
+func (d *SchnorrDKG) decryptShare(dealer int, ciphertext []byte) (kyber.Scalar, error) {
+	aead, err := d.shareCipher(dealer, d.index, d.participants[dealer-1])
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("Encrypted DKG share is too short.")
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	b, err := aead.Open(nil, nonce, ciphertext[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeScalar(d.suite, "DKG share", b)
+}
+
+// Round three: read everybody's complaints and, if any are about us,
+// reveal the shares we dealt to the complainers.
+func (d *SchnorrDKG) ProcessComplaints(complaints []SchnorrDKGSigned) (SchnorrDKGSigned, error) {
+	if d.step != 2 {
+		return SchnorrDKGSigned{}, errors.New("DKG rounds out of order.")
+	}
+	d.step++
+
+	complaints, err := d.collect(complaints)
+	if err != nil {
+		return SchnorrDKGSigned{}, err
+	}
+
+	for _, m := range complaints {
+		var complaint SchnorrDKGComplaint
+		err := d.open(m, DKGComplaintKind, &complaint)
+		if err != nil {
+			// a participant that can not complain properly loses the
+			// right to; its shares stand.
+			continue
+		}
+		for _, accused := range complaint.Accused {
+			if accused < 1 || accused > len(d.participants) {
+				continue
+			}
+			d.complaints[accused] = append(d.complaints[accused], m.Sender)
+		}
+	}
+
+	justification := SchnorrDKGJustification{Shares: make(map[int][]byte)}
+	for _, j := range d.complaints[d.index] {
+		b, err := d.poly.Eval(j - 1).V.MarshalBinary()
+		if err != nil {
+			return SchnorrDKGSigned{}, err
+		}
+		justification.Shares[j] = b
+	}
+	return d.sign(DKGJustificationKind, justification)
+}
+
+// Round four: check the revealed shares, settle who is qualified and
+// work out our share and the group key. Returns our share as a key pair,
+// and the signed result to publish. The share must only be used once all
+// participants are known to have reported the same transcript.
+func (d *SchnorrDKG) ProcessJustifications(justifications []SchnorrDKGSigned) (SchnorrSecretKV,
+	SchnorrDKGSigned, error) {
+
+	if d.step != 3 {
+		return SchnorrSecretKV{}, SchnorrDKGSigned{},
+			errors.New("DKG rounds out of order.")
+	}
+	d.step++
+
+	justifications, err := d.collect(justifications)
+	if err != nil {
+		return SchnorrSecretKV{}, SchnorrDKGSigned{}, err
+	}
+	revealed := make(map[int]map[int][]byte)
+	for _, m := range justifications {
+		var justification SchnorrDKGJustification
+		err := d.open(m, DKGJustificationKind, &justification)
+		if err != nil {
+			continue
+		}
+		revealed[m.Sender] = justification.Shares
+	}
+
+	// every complaint must be answered with a share that checks out.
+	for accused, complainers := range d.complaints {
+		dealer := d.dealers[accused]
+		if dealer.bad {
+			continue
+		}
+		for _, j := range complainers {
+			b, ok := revealed[accused][j]
+			if !ok {
+				dealer.bad = true
+				break
+			}
+			s, err := decodeScalar(d.suite, "DKG share", b)
+			if err != nil || !d.checkShare(dealer.commits, j, s) {
+				dealer.bad = true
+				break
+			}
+			if j == d.index {
+				dealer.share = s
+			}
+		}
+	}
+
+	var qualified []int
+	x := d.suite.Scalar().Zero()
+	Y := d.suite.Point().Null()
+	for i := 1; i <= len(d.participants); i++ {
+		dealer := d.dealers[i]
+		if dealer.bad {
+			continue
+		}
+		if dealer.share == nil {
+			// we complained, and nobody disqualified the dealer, so the
+			// complaint must not have been counted; we can not go on.
+			return SchnorrSecretKV{}, SchnorrDKGSigned{},
+				errors.New("No valid share from qualified dealer " +
+					strconv.Itoa(i))
+		}
+		qualified = append(qualified, i)
+		x.Add(x, dealer.share)
+		Y.Add(Y, dealer.commits[0])
+	}
+	if len(qualified) < d.t {
+		return SchnorrSecretKV{}, SchnorrDKGSigned{},
+			errors.New("Fewer qualified dealers than the threshold.")
+	}
+
+	kv := SchnorrSecretKV{s: x, pP: d.suite.Point().Mul(x, nil),
+		suite: d.suitename}
+	proof, err := SchnorrGenerateProofOfPossession(d.suite, kv)
+	if err != nil {
+		return SchnorrSecretKV{}, SchnorrDKGSigned{}, err
+	}
+	pop, err := proof.ExportProofOfPossession()
+	if err != nil {
+		return SchnorrSecretKV{}, SchnorrDKGSigned{}, err
+	}
+
+	result := SchnorrDKGResult{
+		GroupKey:   SchnorrPublicKV{suite: d.suitename, pP: Y}.Export(),
+		ShareKey:   kv.GetPublicKeyset().Export(),
+		PoP:        pop,
+		Qualified:  qualified,
+		Transcript: d.transcriptHash(),
+	}
+	signed, err := d.sign(DKGResultKind, result)
+	if err != nil {
+		return SchnorrSecretKV{}, SchnorrDKGSigned{}, err
+	}
+	return kv, signed, nil
+}
+
+// Hashes every message we took part in, round by round.
+func (d *SchnorrDKG) transcriptHash() []byte {
+	h, _ := blake2b.New256(nil)
+	var lenbuf [8]byte
+	h.Write([]byte(dkgTranscriptTag))
+	binary.BigEndian.PutUint64(lenbuf[:], uint64(len(d.session)))
+	h.Write(lenbuf[:])
+	h.Write(d.session)
+	for _, b := range d.transcript {
+		binary.BigEndian.PutUint64(lenbuf[:], uint64(len(b)))
+		h.Write(lenbuf[:])
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// Checks the signed results of all participants, in participant order,
+// and builds the threshold group from them: everybody must agree on the
+// transcript, the qualified dealers and the group key. Anyone who relays
+// a DKG can call this; it needs no secrets.
+func SchnorrDKGVerifyResults(suite CryptoSuite, participants []SchnorrPublicKV,
+	t int, session []byte, results []SchnorrDKGSigned) (SchnorrMGroupConfig, error) {
+
+	var config SchnorrMGroupConfig
+	name, err := SuiteName(suite)
+	if err != nil {
+		return config, err
+	}
+	if len(results) != len(participants) {
+		return config, errors.New("Need one result per participant.")
+	}
+
+	// a verifier-only view of the session, to check signatures.
+	d := &SchnorrDKG{suite: suite, suitename: name, session: session, t: t,
+		participants: participants}
+
+	var first SchnorrDKGResult
+	for k, m := range results {
+		if m.Sender != k+1 {
+			return config, errors.New("Results out of order.")
+		}
+		var result SchnorrDKGResult
+		err := d.open(m, DKGResultKind, &result)
+		if err != nil {
+			return config, err
+		}
+		if k == 0 {
+			first = result
+			config = SchnorrMGroupConfig{Suite: name, Threshold: t,
+				JointKey: result.GroupKey}
+		} else {
+			if string(result.Transcript) != string(first.Transcript) {
+				return config, errors.New("Participant " + strconv.Itoa(k+1) +
+					" saw different messages.")
+			}
+			if result.GroupKey != first.GroupKey {
+				return config, errors.New("Participant " + strconv.Itoa(k+1) +
+					" computed a different group key.")
+			}
+		}
+		config.Members = append(config.Members, SchnorrMMember{
+			PKey: result.ShareKey, PoP: result.PoP, ShareIndex: k + 1})
+	}
+
+	// the share keys must interpolate to the group key; Verify also
+	// checks the proofs of possession.
+	err = config.Verify()
+	if err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// The steps a DKG relay walks the participants through.
+const (
+	DKGStartStep          = "start"
+	DKGDealsStep          = "deals"
+	DKGComplaintsStep     = "complaints"
+	DKGJustificationsStep = "justifications"
+	DKGCommitStep         = "commit"
+)
+
+// What a relay sends to a participant at each step: the session details
+// at the start, then all of the previous round's messages.
+type SchnorrDKGRequest struct {
+	Step         string
+	Suite        string             `json:",omitempty"`
+	Threshold    int                `json:",omitempty"`
+	Session      []byte             `json:",omitempty"`
+	Participants []string           `json:",omitempty"`
+	Messages     []SchnorrDKGSigned `json:",omitempty"`
+}
+
+// A participant's answer to a request: its message for the next round,
+// or what went wrong.
+type SchnorrDKGReply struct {
+	Message SchnorrDKGSigned
+	Error   string `json:",omitempty"`
+}
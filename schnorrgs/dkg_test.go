@@ -0,0 +1,219 @@
+package schnorrgs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Runs a DKG among n fresh identities. tamper, if set, may rewrite the
+// deals before they are handed out.
+func runTestDKG(t *testing.T, n int, threshold int,
+	tamper func(dkgs []*SchnorrDKG, deals []SchnorrDKGSigned)) ([]SchnorrSecretKV,
+	[]SchnorrDKGSigned, []SchnorrPublicKV, []byte) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	session := []byte("test session")
+
+	var identities []SchnorrSecretKV
+	var participants []SchnorrPublicKV
+	for i := 0; i < n; i++ {
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		identities = append(identities, kv)
+		participants = append(participants, kv.GetPublicKeyset())
+	}
+
+	var dkgs []*SchnorrDKG
+	var deals []SchnorrDKGSigned
+	for _, kv := range identities {
+		d, err := NewSchnorrDKG(suite, kv, participants, threshold, session)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		deal, err := d.Deal()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		dkgs = append(dkgs, d)
+		deals = append(deals, deal)
+	}
+	if tamper != nil {
+		tamper(dkgs, deals)
+	}
+
+	var complaints []SchnorrDKGSigned
+	for _, d := range dkgs {
+		c, err := d.ProcessDeals(deals)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		complaints = append(complaints, c)
+	}
+	var justifications []SchnorrDKGSigned
+	for _, d := range dkgs {
+		j, err := d.ProcessComplaints(complaints)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		justifications = append(justifications, j)
+	}
+	var shares []SchnorrSecretKV
+	var results []SchnorrDKGSigned
+	for _, d := range dkgs {
+		kv, r, err := d.ProcessJustifications(justifications)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		shares = append(shares, kv)
+		results = append(results, r)
+	}
+	return shares, results, participants, session
+}
+
+// Re-signs a deal after changing it.
+func resignDeal(t *testing.T, d *SchnorrDKG, m *SchnorrDKGSigned,
+	change func(deal *SchnorrDKGDeal)) {
+
+	var deal SchnorrDKGDeal
+	err := json.Unmarshal(m.Body, &deal)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	change(&deal)
+	*m, err = d.sign(DKGDealKind, deal)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func checkDKGGroup(t *testing.T, shares []SchnorrSecretKV,
+	results []SchnorrDKGSigned, participants []SchnorrPublicKV,
+	session []byte, threshold int) SchnorrMGroupConfig {
+
+	suite, _ := GetSuite(DefaultSuite)
+	config, err := SchnorrDKGVerifyResults(suite, participants, threshold,
+		session, results)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	groupkey, err := config.GetJointKeyAsKV()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	signers := []int{len(shares), 1, 2}[:threshold]
+	sig := thresholdSign(t, suite, *groupkey, shares, signers,
+		[]byte("DKG test"))
+	v, err := SchnorrVerify(suite, *groupkey, []byte("DKG test"), sig)
+	if err != nil || !v {
+		t.Error("Signature with DKG shares did not verify")
+	}
+	return config
+}
+
+func TestDKG(t *testing.T) {
+	shares, results, participants, session := runTestDKG(t, 5, 3, nil)
+	config := checkDKGGroup(t, shares, results, participants, session, 3)
+
+	var result SchnorrDKGResult
+	json.Unmarshal(results[0].Body, &result)
+	if len(result.Qualified) != 5 {
+		t.Error("Honest dealers were disqualified:", result.Qualified)
+	}
+	if config.Threshold != 3 || len(config.Members) != 5 {
+		t.Error("Wrong group shape")
+	}
+}
+
+// A dealer that sends one bad share but reveals the right one when
+// accused stays in.
+func TestDKGJustifiedComplaint(t *testing.T) {
+	shares, results, participants, session := runTestDKG(t, 4, 2,
+		func(dkgs []*SchnorrDKG, deals []SchnorrDKGSigned) {
+			resignDeal(t, dkgs[0], &deals[0], func(deal *SchnorrDKGDeal) {
+				deal.Shares[2][0] ^= 1
+			})
+		})
+	checkDKGGroup(t, shares, results, participants, session, 2)
+
+	var result SchnorrDKGResult
+	json.Unmarshal(results[0].Body, &result)
+	if len(result.Qualified) != 4 {
+		t.Error("Justified dealer was disqualified:", result.Qualified)
+	}
+}
+
+// A dealer whose commitments do not match its shares is thrown out.
+func TestDKGDisqualifiedDealer(t *testing.T) {
+	shares, results, participants, session := runTestDKG(t, 4, 2,
+		func(dkgs []*SchnorrDKG, deals []SchnorrDKGSigned) {
+			resignDeal(t, dkgs[1], &deals[1], func(deal *SchnorrDKGDeal) {
+				deal.Commitments[1] = deal.Commitments[0]
+			})
+		})
+	checkDKGGroup(t, shares, results, participants, session, 2)
+
+	var result SchnorrDKGResult
+	json.Unmarshal(results[0].Body, &result)
+	if len(result.Qualified) != 3 || result.Qualified[1] == 2 {
+		t.Error("Cheating dealer was not disqualified:", result.Qualified)
+	}
+}
+
+// Results that disagree, or are forged, must not make a group.
+func TestDKGVerifyResults(t *testing.T) {
+	suite, _ := GetSuite(DefaultSuite)
+	_, results, participants, session := runTestDKG(t, 3, 2, nil)
+
+	swapped := append([]SchnorrDKGSigned(nil), results...)
+	swapped[0], swapped[1] = swapped[1], swapped[0]
+	_, err := SchnorrDKGVerifyResults(suite, participants, 2, session, swapped)
+	if err == nil {
+		t.Error("Accepted results out of order")
+	}
+
+	forged := append([]SchnorrDKGSigned(nil), results...)
+	forged[2].Body = forged[1].Body
+	_, err = SchnorrDKGVerifyResults(suite, participants, 2, session, forged)
+	if err == nil {
+		t.Error("Accepted a result with a bad signature")
+	}
+
+	_, err = SchnorrDKGVerifyResults(suite, participants, 2,
+		[]byte("other session"), results)
+	if err == nil {
+		t.Error("Accepted results from another session")
+	}
+}
+
+// A relay that runs a session twice must not get two shares sealed under
+// the same key and nonce.
+func TestDKGSessionReuse(t *testing.T) {
+	suite, _ := GetSuite(DefaultSuite)
+	session := []byte("reused session")
+
+	a, _ := SchnorrGenerateKeypair(suite)
+	b, _ := SchnorrGenerateKeypair(suite)
+	participants := []SchnorrPublicKV{a.GetPublicKeyset(), b.GetPublicKeyset()}
+
+	var sealed [][]byte
+	for run := 0; run < 2; run++ {
+		d, err := NewSchnorrDKG(suite, a, participants, 2, session)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		m, err := d.Deal()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var deal SchnorrDKGDeal
+		json.Unmarshal(m.Body, &deal)
+		sealed = append(sealed, deal.Shares[1])
+	}
+	nonceSize := 12
+	if string(sealed[0][:nonceSize]) == string(sealed[1][:nonceSize]) {
+		t.Error("Two runs of a session sealed shares under the same nonce")
+	}
+}
@@ -0,0 +1,95 @@
+package schnorrgs
+
+/* This file implements the challenge hash shared by every protocol in the
+   package. A challenge is
+
+       H(len(tag) || tag || len(ctx) || ctx || Y || points... || M)
+
+   where tag names the protocol, ctx is a context string chosen by the
+   application and Y is the public key the proof is made under. With the
+   tag, a signature made in one protocol never verifies in another; with
+   the context, a signature an application made for one purpose never
+   verifies for another; and with the key in the hash, as in Ed25519, a
+   signature can not be moved to a related key.
+
+   Points and keys have a fixed size in a suite, so only the tag and the
+   context need a length.
+*/
+
+import (
+	"errors"
+	"github.com/dedis/kyber"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Protocol tags of the challenge hashes. Signatures made by the
+// multisignature, threshold and FROST protocols are ordinary signatures
+// and share signatureTag. The DKG signs its messages under
+// dkgSignatureTag.
+const (
+	signatureTag    = "schnorrgs-signature-v1"
+	popSignatureTag = "schnorrgs-proof-of-possession-v1"
+	prehashTag      = "schnorrgs-prehash-blake2b512-v1"
+	blindTag        = "schnorrgs-partialblind-v1"
+
+	catalogueSignatureTag = "schnorrgs-denomination-catalogue-v1"
+	receiptSignatureTag   = "schnorrgs-deposit-receipt-v1"
+)
+
+// The longest context string, as for Ed25519ctx in RFC 8032.
+const MaxContextSize = 255
+
+// Encodes the tag and context that start a challenge hash, and every
+// hash of the secret key that derives a nonce for it.
+func challengeDomain(tag string, context []byte) ([]byte, error) {
+	if len(context) > MaxContextSize {
+		return nil, errors.New("Context string longer than 255 bytes.")
+	}
+	b := []byte{byte(len(tag))}
+	b = append(b, tag...)
+	b = append(b, byte(len(context)))
+	return append(b, context...), nil
+}
+
+// Computes H(tag || ctx || Y || points || M) reduced to a scalar.
+func schnorrHashChallenge(suite CryptoSuite, tag string, context []byte,
+	Y kyber.Point, points []kyber.Point, msg []byte) (kyber.Scalar, error) {
+
+	domain, err := challengeDomain(tag, context)
+	if err != nil {
+		return nil, err
+	}
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(domain)
+	_, err = Y.MarshalTo(h)
+	if err != nil {
+		return nil, err
+	}
+	for _, P := range points {
+		_, err = P.MarshalTo(h)
+		if err != nil {
+			return nil, err
+		}
+	}
+	h.Write(msg)
+
+	return suite.Scalar().SetBytes(h.Sum(nil)), nil
+}
+
+// Computes the challenge e = H(tag || ctx || Y || R || M) of a signature
+// with commitment R on msg under the key Y, in the given context. The
+// multisignature protocols use it to compute the challenge for the
+// joint key.
+func SchnorrHashChallenge(suite CryptoSuite, context []byte, Y SchnorrPublicKV,
+	R kyber.Point, msg []byte) (kyber.Scalar, error) {
+
+	err := checkSuite(suite, Y.suite)
+	if err != nil {
+		return nil, err
+	}
+	return schnorrHashChallenge(suite, signatureTag, context, Y.pP,
+		[]kyber.Point{R}, msg)
+}
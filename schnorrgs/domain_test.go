@@ -0,0 +1,94 @@
+package schnorrgs
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSignatureContext(t *testing.T) {
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+		pk := kv.GetPublicKeyset()
+		msg := []byte("This is a test")
+
+		sig, err := SchnorrSignWithContext(suite, kv, []byte("notary-v1"), msg,
+			NonceDeterministic)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		ok, err := SchnorrVerifyWithContext(suite, pk, []byte("notary-v1"),
+			msg, sig)
+		if err != nil || !ok {
+			t.Errorf("%s: signature does not verify in its context", name)
+		}
+		ok, _ = SchnorrVerifyWithContext(suite, pk, []byte("release-signing"),
+			msg, sig)
+		if ok {
+			t.Errorf("%s: signature verifies in another context", name)
+		}
+		ok, _ = SchnorrVerify(suite, pk, msg, sig)
+		if ok {
+			t.Errorf("%s: signature verifies without its context", name)
+		}
+
+		// same message, same deterministic mode: the nonce must still
+		// change with the context.
+		plain, _ := SchnorrSignWithMode(suite, kv, msg, NonceDeterministic)
+		if plain.R.Equal(sig.R) {
+			t.Errorf("%s: nonce does not depend on the context", name)
+		}
+
+		// an (R, s) signature checked through the batch path too.
+		sig.E = nil
+		ok, _, err = SchnorrVerifyBatchWithContext(suite, []byte("notary-v1"),
+			[]SchnorrPublicKV{pk}, [][]byte{msg}, []SchnorrSignature{sig})
+		if err != nil || !ok {
+			t.Errorf("%s: batch does not verify in the context", name)
+		}
+		ok, _, _ = SchnorrVerifyBatch(suite, []SchnorrPublicKV{pk},
+			[][]byte{msg}, []SchnorrSignature{sig})
+		if ok {
+			t.Errorf("%s: batch verifies without the context", name)
+		}
+
+		_, err = SchnorrSignWithContext(suite, kv, bytes.Repeat([]byte{'x'},
+			MaxContextSize+1), msg, NonceRandom)
+		if err == nil {
+			t.Errorf("%s: accepted an oversized context", name)
+		}
+	}
+}
+
+func TestProtocolSeparation(t *testing.T) {
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+	pk := kv.GetPublicKeyset()
+
+	// a proof of possession is not a signature on its message.
+	proof, err := SchnorrGenerateProofOfPossession(suite, kv)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	msg, _ := popMessage(pk)
+	ok, _ := SchnorrVerify(suite, pk, msg, proof)
+	if ok {
+		t.Error("Proof of possession verifies as a plain signature.")
+	}
+
+	// and a signature on that message is no proof of possession.
+	sig, _ := SchnorrSign(suite, kv, msg)
+	ok, _ = SchnorrVerifyProofOfPossession(suite, pk, sig)
+	if ok {
+		t.Error("Plain signature verifies as a proof of possession.")
+	}
+
+	// the challenge is bound to the key.
+	other, _ := SchnorrGenerateKeypair(suite)
+	e1, _ := SchnorrHashChallenge(suite, nil, pk, proof.R, msg)
+	e2, _ := SchnorrHashChallenge(suite, nil, other.GetPublicKeyset(),
+		proof.R, msg)
+	if e1.Equal(e2) {
+		t.Error("Challenge does not depend on the key.")
+	}
+}
@@ -0,0 +1,179 @@
+package schnorrgs
+
+/* This file implements standard Ed25519 signatures (RFC 8032) on top of
+   our Ed25519 keys, so that anything with an Ed25519 implementation,
+   e.g. Go's crypto/ed25519, can check them.
+
+   Our native signatures are (s, e) with e = Blake2b(R||M) and s = k - xe.
+   Ed25519 signatures are (R, S) with k = SHA-512(R||A||M) and S = r + kx.
+   The nonce r is SHA-512(prefix||M) as in RFC 8032. RFC 8032 takes the
+   prefix from the hash of the seed that x was derived from; our keys are
+   plain scalars with no seed, so we derive the prefix from x instead.
+   The signatures verify everywhere, but are not byte-identical to the
+   ones crypto/ed25519 would make from a seed.
+*/
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+)
+
+// Ed25519 signatures and public keys have fixed sizes.
+const (
+	Ed25519SignatureSize = 64
+	Ed25519PublicKeySize = 32
+)
+
+// Prefix of the hash that turns our secret scalar into an RFC 8032
+// nonce prefix.
+const ed25519PrefixTag = "schnorrgs-ed25519-prefix-v1"
+
+// l, the order of the Ed25519 base point.
+var ed25519Order, _ = new(big.Int).SetString(
+	"7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+
+// Checks that suite works over the Ed25519 group, as nothing else
+// can produce standard Ed25519 signatures.
+func checkEd25519Suite(suite CryptoSuite) error {
+	if suite.String() != "Ed25519" {
+		return errors.New("Ed25519 signatures need an Ed25519 suite, not " +
+			suite.String())
+	}
+	return nil
+}
+
+// Returns the key as a standard Ed25519 public key, i.e. the 32 byte
+// encoding of A.
+func (p SchnorrPublicKV) Ed25519PublicKey() (ed25519.PublicKey, error) {
+	suite, err := GetSuite(p.suite)
+	if err != nil {
+		return nil, err
+	}
+	err = checkEd25519Suite(suite)
+	if err != nil {
+		return nil, err
+	}
+	b, err := p.pP.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// Signs msg as RFC 8032 Ed25519 and returns the 64 byte signature R||S.
+// Signing is deterministic, so there is no nonce mode to choose.
+func SchnorrSignEd25519(suite CryptoSuite, kv SchnorrSecretKV,
+	msg []byte) ([]byte, error) {
+
+	err := checkSigningKey(suite, kv)
+	if err != nil {
+		return nil, err
+	}
+	err = checkEd25519Suite(suite)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := kv.s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	A, err := kv.pP.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	// prefix = SHA-512(tag || x)[32:], r = SHA-512(prefix || M)
+	h := sha512.New()
+	h.Write([]byte(ed25519PrefixTag))
+	h.Write(x)
+	prefix := h.Sum(nil)[32:]
+
+	h.Reset()
+	h.Write(prefix)
+	h.Write(msg)
+	r := suite.Scalar().SetBytes(h.Sum(nil))
+
+	R, err := suite.Point().Mul(r, nil).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	// k = SHA-512(R || A || M), S = r + kx
+	h.Reset()
+	h.Write(R)
+	h.Write(A)
+	h.Write(msg)
+	k := suite.Scalar().SetBytes(h.Sum(nil))
+
+	S := suite.Scalar().Mul(k, kv.s)
+	S.Add(S, r)
+
+	bS, err := S.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var sig bytes.Buffer
+	sig.Write(R)
+	sig.Write(bS)
+	return sig.Bytes(), nil
+}
+
+// Verifies an RFC 8032 Ed25519 signature R||S on msg. Like
+// crypto/ed25519, S must be reduced and the check is SB = R + kA.
+func SchnorrVerifyEd25519(suite CryptoSuite, pk SchnorrPublicKV,
+	msg []byte, sig []byte) (bool, error) {
+
+	err := checkSuite(suite, pk.suite)
+	if err != nil {
+		return false, err
+	}
+	err = checkEd25519Suite(suite)
+	if err != nil {
+		return false, err
+	}
+	if len(sig) != Ed25519SignatureSize {
+		return false, errors.New("Invalid Ed25519 signature length.")
+	}
+
+	// S is little-endian and must be below l.
+	var beS [32]byte
+	for i := 0; i < 32; i++ {
+		beS[i] = sig[63-i]
+	}
+	if new(big.Int).SetBytes(beS[:]).Cmp(ed25519Order) >= 0 {
+		return false, nil
+	}
+
+	R := suite.Point()
+	err = R.UnmarshalBinary(sig[:32])
+	if err != nil {
+		return false, nil
+	}
+	S := suite.Scalar()
+	err = S.UnmarshalBinary(sig[32:])
+	if err != nil {
+		return false, nil
+	}
+
+	A, err := pk.pP.MarshalBinary()
+	if err != nil {
+		return false, err
+	}
+
+	h := sha512.New()
+	h.Write(sig[:32])
+	h.Write(A)
+	h.Write(msg)
+	k := suite.Scalar().SetBytes(h.Sum(nil))
+
+	SB := suite.Point().Mul(S, nil)
+	kA := suite.Point().Mul(k, pk.pP)
+	RkA := suite.Point().Add(R, kA)
+
+	return SB.Equal(RkA), nil
+}
@@ -0,0 +1,114 @@
+package schnorrgs
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+func TestEd25519CompatibleSignatures(t *testing.T) {
+
+	suite, _ := GetSuite("BlakeSHA256Ed25519")
+
+	for i := 0; i < 20; i++ {
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		pk := kv.GetPublicKeyset()
+
+		message := make([]byte, 100)
+		_, err = rand.Read(message)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		sig, err := SchnorrSignEd25519(suite, kv, message)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		stdpk, err := pk.Ed25519PublicKey()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !ed25519.Verify(stdpk, message, sig) {
+			t.Error("crypto/ed25519 rejected our signature")
+		}
+
+		v, err := SchnorrVerifyEd25519(suite, pk, message, sig)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !v {
+			t.Error("Our Ed25519 signature did not verify")
+		}
+
+		v, err = SchnorrVerifyEd25519(suite, pk, []byte("wrong"), sig)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if v {
+			t.Error("Ed25519 signature verified for the wrong message")
+		}
+	}
+}
+
+func TestEd25519VerifyStandardSignatures(t *testing.T) {
+
+	suite, _ := GetSuite("BlakeSHA256Ed25519")
+
+	stdpk, stdsk, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	message := []byte("signed by crypto/ed25519")
+	sig := ed25519.Sign(stdsk, message)
+
+	pk, err := NewSchnorrPublicKeyFromString("BlakeSHA256Ed25519;" +
+		hex.EncodeToString(stdpk))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, err := SchnorrVerifyEd25519(suite, *pk, message, sig)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !v {
+		t.Error("Rejected a crypto/ed25519 signature")
+	}
+
+	// S + l is the classic malleated signature and must be refused.
+	malleated := make([]byte, len(sig))
+	copy(malleated, sig)
+	carry := 0
+	lbytes := ed25519Order.Bytes()
+	for i := 0; i < 32; i++ {
+		sum := int(malleated[32+i]) + int(lbytes[31-i]) + carry
+		malleated[32+i] = byte(sum)
+		carry = sum >> 8
+	}
+	v, _ = SchnorrVerifyEd25519(suite, *pk, message, malleated)
+	if v {
+		t.Error("Accepted a signature with S >= l")
+	}
+}
+
+func TestEd25519RejectsOtherSuites(t *testing.T) {
+
+	suite, _ := GetSuite("BlakeSHA256P256")
+	kv, err := SchnorrGenerateKeypair(suite)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = SchnorrSignEd25519(suite, kv, []byte("test"))
+	if err == nil {
+		t.Error("Made an Ed25519 signature with a P256 key")
+	}
+	_, err = kv.GetPublicKeyset().Ed25519PublicKey()
+	if err == nil {
+		t.Error("Exported a P256 key as Ed25519")
+	}
+}
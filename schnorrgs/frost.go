@@ -0,0 +1,467 @@
+package schnorrgs
+
+/* This file implements FROST (Komlo and Goldberg), a two-round threshold
+   Schnorr protocol whose first round can be done ahead of time.
+
+   In the preprocessing round each signer i picks nonce pairs (d_i, e_i)
+   and publishes the commitments (D_i, E_i) = (d_i G, e_i G). To sign M,
+   the coordinator picks one unused commitment of each of the t signers,
+   giving the list B, and sends M and B to them. Each signer computes
+
+       rho_j = H(Y, j, M, B)             for every signer j
+       R     = sum D_j + rho_j E_j
+       c     = H(tag || ctx || Y || R || M)
+       z_i   = d_i + e_i rho_i - c l_i x_i
+
+   and the coordinator adds the z_i up into an ordinary (s, e) signature
+   under the group key, as in threshold.go. The binding factors rho tie
+   every nonce to the message and to the whole set of commitments, which
+   is what stops Wagner-style attacks across concurrent sessions, and each
+   z_i can be checked against the signer's share key on its own.
+
+   A nonce pair must never sign twice; SchnorrFROSTNonceStore hands each
+   out once.
+*/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"github.com/dedis/kyber"
+	"golang.org/x/crypto/blake2b"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Prefix of the binding factor hash.
+const frostBindingTag = "schnorrgs-frost-binding-v1"
+
+// A signer's secret nonce pair, kept until it is used.
+type SchnorrFROSTNonce struct {
+	d kyber.Scalar
+	e kyber.Scalar
+	SchnorrFROSTCommitment
+}
+
+// A signer's published commitment to one nonce pair. Index is the
+// signer's share index.
+type SchnorrFROSTCommitment struct {
+	Index int
+	D     kyber.Point
+	E     kyber.Point
+}
+
+// Encodes a commitment as index (32-bit big-endian) || D || E.
+func (fc SchnorrFROSTCommitment) MarshalBinary() ([]byte, error) {
+	var result bytes.Buffer
+
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], uint32(fc.Index))
+	result.Write(idx[:])
+
+	b, err := fc.D.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	result.Write(b)
+	b, err = fc.E.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	result.Write(b)
+
+	return result.Bytes(), nil
+}
+
+// Decodes a commitment encoded by MarshalBinary.
+func (fc *SchnorrFROSTCommitment) UnmarshalBinary(suite CryptoSuite, b []byte) error {
+	if len(b) < 4 {
+		return decodeError("FROST commitment", ErrDecodeLength)
+	}
+	d := newStrictDecoder(suite, "FROST commitment", b[4:])
+	D := d.point()
+	E := d.point()
+	err := d.finish()
+	if err != nil {
+		return err
+	}
+
+	fc.Index = int(binary.BigEndian.Uint32(b[:4]))
+	fc.D = D
+	fc.E = E
+	return nil
+}
+
+// Generates count nonce pairs for the signer with share index index,
+// holding share. The nonces are hedged like SchnorrMSGenerateCommitmentHedged,
+// and likewise rely on the RNG: without it every pair would be the same.
+func SchnorrFROSTPreprocess(suite CryptoSuite, index int,
+	share SchnorrSecretKV, count int) ([]SchnorrFROSTNonce, error) {
+
+	err := checkSuite(suite, share.suite)
+	if err != nil {
+		return nil, err
+	}
+	if index < 1 {
+		return nil, errors.New("Invalid share index " + strconv.Itoa(index))
+	}
+
+	var nonces []SchnorrFROSTNonce
+	for k := 0; k < count; k++ {
+		d, err := schnorrHashNonce(suite, 'd', share, nil, NonceHedged)
+		if err != nil {
+			return nil, err
+		}
+		e, err := schnorrHashNonce(suite, 'e', share, nil, NonceHedged)
+		if err != nil {
+			return nil, err
+		}
+		nonces = append(nonces, SchnorrFROSTNonce{d: d, e: e,
+			SchnorrFROSTCommitment: SchnorrFROSTCommitment{Index: index,
+				D: suite.Point().Mul(d, nil), E: suite.Point().Mul(e, nil)}})
+	}
+	return nonces, nil
+}
+
+// Returns the public half of a nonce pair.
+func (n SchnorrFROSTNonce) GetCommitment() SchnorrFROSTCommitment {
+	return n.SchnorrFROSTCommitment
+}
+
+// Sorts the commitment list by index, refusing repeated signers, and
+// returns the signer indices along with its encoding.
+func frostEncodeCommitments(commitments []SchnorrFROSTCommitment) ([]SchnorrFROSTCommitment,
+	[]int, []byte, error) {
+
+	sorted := append([]SchnorrFROSTCommitment(nil), commitments...)
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Index < sorted[b].Index
+	})
+
+	var signers []int
+	var encoding bytes.Buffer
+	for _, fc := range sorted {
+		signers = append(signers, fc.Index)
+		b, err := fc.MarshalBinary()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		encoding.Write(b)
+	}
+	err := checkShareIndices(signers)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return sorted, signers, encoding.Bytes(), nil
+}
+
+// The public values every signer and the coordinator derive from the
+// message and the commitment list.
+type frostSession struct {
+	commitments []SchnorrFROSTCommitment // sorted by index
+	signers     []int
+	rho         map[int]kyber.Scalar
+	R           kyber.Point
+	c           kyber.Scalar
+}
+
+// Computes the binding factors, the group commitment R and the challenge
+// for the group key Y.
+func frostNewSession(suite CryptoSuite, groupkey SchnorrPublicKV,
+	context []byte, msg []byte,
+	commitments []SchnorrFROSTCommitment) (*frostSession, error) {
+
+	if len(commitments) == 0 {
+		return nil, errors.New("No FROST commitments.")
+	}
+	sorted, signers, encoding, err := frostEncodeCommitments(commitments)
+	if err != nil {
+		return nil, err
+	}
+
+	// rho_i = H(tag || Y || len(M) || M || B || i), so it depends on the
+	// group, the message and on every signer's commitment.
+	Y, err := groupkey.pP.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var lenbuf [8]byte
+	prefix := []byte(frostBindingTag)
+	prefix = append(prefix, Y...)
+	binary.BigEndian.PutUint64(lenbuf[:], uint64(len(msg)))
+	prefix = append(prefix, lenbuf[:]...)
+	prefix = append(prefix, msg...)
+	binary.BigEndian.PutUint64(lenbuf[:], uint64(len(encoding)))
+	prefix = append(prefix, lenbuf[:]...)
+	prefix = append(prefix, encoding...)
+
+	s := &frostSession{commitments: sorted, signers: signers,
+		rho: make(map[int]kyber.Scalar), R: suite.Point().Null()}
+	for _, fc := range sorted {
+		h, err := blake2b.New512(nil)
+		if err != nil {
+			return nil, err
+		}
+		var idx [4]byte
+		binary.BigEndian.PutUint32(idx[:], uint32(fc.Index))
+		h.Write(prefix)
+		h.Write(idx[:])
+		rho := suite.Scalar().SetBytes(h.Sum(nil))
+		s.rho[fc.Index] = rho
+
+		Ri := suite.Point().Add(fc.D, suite.Point().Mul(rho, fc.E))
+		s.R.Add(s.R, Ri)
+	}
+
+	s.c, err = SchnorrHashChallenge(suite, context, groupkey, s.R, msg)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Finds the commitment of signer index in the session.
+func (s *frostSession) commitment(index int) (SchnorrFROSTCommitment, bool) {
+	for _, fc := range s.commitments {
+		if fc.Index == index {
+			return fc, true
+		}
+	}
+	return SchnorrFROSTCommitment{}, false
+}
+
+// Computes our signature share z_i = d + e rho - c l x_i on msg in the
+// given context, as the holder of share and nonce in the group with key
+// groupkey, for the signers and commitments given. The list must contain
+// our commitment exactly as published. The nonce must not be used again,
+// whether this succeeds or not.
+func SchnorrFROSTSign(suite CryptoSuite, share SchnorrSecretKV,
+	nonce SchnorrFROSTNonce, groupkey SchnorrPublicKV, context []byte,
+	msg []byte, commitments []SchnorrFROSTCommitment) (kyber.Scalar, error) {
+
+	err := checkSigningKey(suite, share)
+	if err != nil {
+		return nil, err
+	}
+	s, err := frostNewSession(suite, groupkey, context, msg, commitments)
+	if err != nil {
+		return nil, err
+	}
+
+	own, ok := s.commitment(nonce.Index)
+	if !ok {
+		return nil, errors.New("Our commitment is not in the list.")
+	}
+	if !own.D.Equal(nonce.D) || !own.E.Equal(nonce.E) {
+		return nil, errors.New("Commitment in the list is not ours.")
+	}
+
+	l, err := SchnorrTSLagrangeCoefficient(suite, nonce.Index, s.signers)
+	if err != nil {
+		return nil, err
+	}
+
+	z := suite.Scalar().Mul(nonce.e, s.rho[nonce.Index])
+	z.Add(z, nonce.d)
+	clx := suite.Scalar().Mul(s.c, suite.Scalar().Mul(l, share.s))
+	return z.Sub(z, clx), nil
+}
+
+// Checks the signature share z of the signer with share index index and
+// share key sharekey: zG + c l X_i must equal D_i + rho_i E_i.
+func SchnorrFROSTVerifyShare(suite CryptoSuite, groupkey SchnorrPublicKV,
+	sharekey SchnorrPublicKV, index int, context []byte, msg []byte,
+	commitments []SchnorrFROSTCommitment, z kyber.Scalar) (bool, error) {
+
+	err := checkSuite(suite, sharekey.suite)
+	if err != nil {
+		return false, err
+	}
+	s, err := frostNewSession(suite, groupkey, context, msg, commitments)
+	if err != nil {
+		return false, err
+	}
+	fc, ok := s.commitment(index)
+	if !ok {
+		return false, errors.New("Signer " + strconv.Itoa(index) +
+			" has no commitment in the list.")
+	}
+	l, err := SchnorrTSLagrangeCoefficient(suite, index, s.signers)
+	if err != nil {
+		return false, err
+	}
+
+	zG := suite.Point().Mul(z, nil)
+	clX := suite.Point().Mul(suite.Scalar().Mul(s.c, l), sharekey.pP)
+	Ri := suite.Point().Add(fc.D, suite.Point().Mul(s.rho[index], fc.E))
+	return suite.Point().Add(zG, clX).Equal(Ri), nil
+}
+
+// Adds up the signature shares of every signer in the commitment list
+// into a signature on msg under the group key, in the given context.
+func SchnorrFROSTAggregate(suite CryptoSuite, groupkey SchnorrPublicKV,
+	context []byte, msg []byte, commitments []SchnorrFROSTCommitment,
+	shares []kyber.Scalar) (SchnorrSignature, error) {
+
+	if len(shares) != len(commitments) {
+		return SchnorrSignature{}, errors.New("Need one signature share " +
+			"per commitment.")
+	}
+	s, err := frostNewSession(suite, groupkey, context, msg, commitments)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+
+	sig, err := SchnorrMSCreateSignature(suite, s.c,
+		SchnorrMSComputeCombinedResponse(suite, shares))
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+	sig.R = s.R
+	return sig, nil
+}
+
+// Default limits of a nonce store: how many unused nonce pairs it holds
+// and how long one may wait to be used.
+const (
+	DefaultFROSTNonceCapacity = 10000
+	DefaultFROSTNonceLifetime = time.Hour
+)
+
+// Given by Add when the store has no room for the nonces.
+var ErrFROSTNonceStoreFull = errors.New("FROST nonce store is full.")
+
+// An unused nonce pair and when it expires.
+type frostStoredNonce struct {
+	nonce   SchnorrFROSTNonce
+	expires time.Time
+}
+
+// Keeps a signer's unused nonce pairs, handing each out at most once.
+// Anyone who can ask for preprocessing can fill it, so it holds at most
+// capacity pairs and forgets those not used within lifetime. It is safe
+// for concurrent use.
+type SchnorrFROSTNonceStore struct {
+	mu       sync.Mutex
+	nonces   map[string]frostStoredNonce
+	capacity int
+	lifetime time.Duration
+}
+
+// Makes a store with the default limits.
+func NewSchnorrFROSTNonceStore() *SchnorrFROSTNonceStore {
+	return NewSchnorrFROSTNonceStoreWithLimits(DefaultFROSTNonceCapacity,
+		DefaultFROSTNonceLifetime)
+}
+
+// Makes a store holding at most capacity nonce pairs, each for at most
+// lifetime.
+func NewSchnorrFROSTNonceStoreWithLimits(capacity int,
+	lifetime time.Duration) *SchnorrFROSTNonceStore {
+	return &SchnorrFROSTNonceStore{nonces: make(map[string]frostStoredNonce),
+		capacity: capacity, lifetime: lifetime}
+}
+
+// Overwrites the secret half of a nonce pair we will not use.
+func (n SchnorrFROSTNonce) destroy() {
+	n.d.Zero()
+	n.e.Zero()
+}
+
+// Drops expired nonce pairs. The caller holds the lock.
+func (st *SchnorrFROSTNonceStore) sweep(now time.Time) {
+	for key, n := range st.nonces {
+		if now.After(n.expires) {
+			n.nonce.destroy()
+			delete(st.nonces, key)
+		}
+	}
+}
+
+func frostNonceKey(fc SchnorrFROSTCommitment) (string, error) {
+	b, err := fc.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Adds nonce pairs to the store, all or none of them. Gives
+// ErrFROSTNonceStoreFull if they do not fit.
+func (st *SchnorrFROSTNonceStore) Add(nonces []SchnorrFROSTNonce) error {
+	keys := make([]string, len(nonces))
+	for i, n := range nonces {
+		key, err := frostNonceKey(n.SchnorrFROSTCommitment)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	st.sweep(now)
+	if len(st.nonces)+len(nonces) > st.capacity {
+		return ErrFROSTNonceStoreFull
+	}
+	for i, n := range nonces {
+		st.nonces[keys[i]] = frostStoredNonce{nonce: n,
+			expires: now.Add(st.lifetime)}
+	}
+	return nil
+}
+
+// Removes and returns the nonce pair behind a commitment. Asking twice
+// for the same one fails, which is what keeps nonces single use.
+func (st *SchnorrFROSTNonceStore) Take(fc SchnorrFROSTCommitment) (SchnorrFROSTNonce,
+	error) {
+
+	key, err := frostNonceKey(fc)
+	if err != nil {
+		return SchnorrFROSTNonce{}, err
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sweep(time.Now())
+	n, ok := st.nonces[key]
+	if !ok {
+		return SchnorrFROSTNonce{}, errors.New("Unknown, expired or already " +
+			"used FROST commitment.")
+	}
+	delete(st.nonces, key)
+	return n.nonce, nil
+}
+
+// Returns how many unused nonce pairs are left.
+func (st *SchnorrFROSTNonceStore) Len() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.sweep(time.Now())
+	return len(st.nonces)
+}
+
+// The requests a FROST coordinator makes of a signer.
+const (
+	FROSTPreprocessStep = "preprocess"
+	FROSTSignStep       = "sign"
+)
+
+// A request to a FROST signer: either publish Count fresh commitments, or
+// sign Message with the given encoded commitments.
+type SchnorrFROSTRequest struct {
+	Step        string
+	Count       int      `json:",omitempty"`
+	Message     []byte   `json:",omitempty"`
+	Commitments [][]byte `json:",omitempty"`
+}
+
+// A FROST signer's answer: new encoded commitments, or a signature share.
+type SchnorrFROSTReply struct {
+	Commitments [][]byte `json:",omitempty"`
+	Share       []byte   `json:",omitempty"`
+	Error       string   `json:",omitempty"`
+}
@@ -0,0 +1,214 @@
+package schnorrgs
+
+import (
+	"github.com/dedis/kyber"
+	"testing"
+	"time"
+)
+
+func TestFROSTSignature(t *testing.T) {
+
+	message := []byte("This is a test")
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+
+		groupkey, shares, err := SchnorrTSDealShares(suite, 3, 5)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		stores := make([]*SchnorrFROSTNonceStore, len(shares))
+		var published [][]SchnorrFROSTCommitment
+		for i, kv := range shares {
+			nonces, err := SchnorrFROSTPreprocess(suite, i+1, kv, 2)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			stores[i] = NewSchnorrFROSTNonceStore()
+			stores[i].Add(nonces)
+			var commits []SchnorrFROSTCommitment
+			for _, n := range nonces {
+				commits = append(commits, n.GetCommitment())
+			}
+			published = append(published, commits)
+		}
+
+		for round, signers := range [][]int{{1, 3, 5}, {4, 2, 1}} {
+			var commitments []SchnorrFROSTCommitment
+			for _, i := range signers {
+				commitments = append(commitments, published[i-1][round])
+			}
+
+			var zs []kyber.Scalar
+			for _, i := range signers {
+				nonce, err := stores[i-1].Take(published[i-1][round])
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				z, err := SchnorrFROSTSign(suite, shares[i-1], nonce,
+					groupkey, nil, message, commitments)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				v, err := SchnorrFROSTVerifyShare(suite, groupkey,
+					shares[i-1].GetPublicKeyset(), i, nil, message,
+					commitments, z)
+				if err != nil || !v {
+					t.Errorf("%s: share of signer %d did not verify", name, i)
+				}
+				zs = append(zs, z)
+			}
+
+			sig, err := SchnorrFROSTAggregate(suite, groupkey, nil, message,
+				commitments, zs)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			v, err := SchnorrVerify(suite, groupkey, message, sig)
+			if err != nil || !v {
+				t.Errorf("%s: FROST signature by %v did not verify", name, signers)
+			}
+		}
+
+		// signer 1 signed twice, everybody else once.
+		for i, left := range []int{0, 1, 1, 1, 1} {
+			if stores[i].Len() != left {
+				t.Errorf("%s: signer %d has %d nonces left, expected %d",
+					name, i+1, stores[i].Len(), left)
+			}
+		}
+	}
+}
+
+func TestFROSTMisuse(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	message := []byte("This is a test")
+
+	groupkey, shares, err := SchnorrTSDealShares(suite, 2, 3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	n1, _ := SchnorrFROSTPreprocess(suite, 1, shares[0], 1)
+	n2, _ := SchnorrFROSTPreprocess(suite, 2, shares[1], 1)
+	other, _ := SchnorrFROSTPreprocess(suite, 2, shares[1], 1)
+	commitments := []SchnorrFROSTCommitment{n1[0].GetCommitment(),
+		n2[0].GetCommitment()}
+
+	store := NewSchnorrFROSTNonceStore()
+	store.Add(n1)
+	_, err = store.Take(n1[0].GetCommitment())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = store.Take(n1[0].GetCommitment())
+	if err == nil {
+		t.Error("Handed out the same nonce twice")
+	}
+
+	// signing against a list that does not hold our commitment.
+	_, err = SchnorrFROSTSign(suite, shares[1], other[0], groupkey, nil,
+		message, commitments)
+	if err == nil {
+		t.Error("Signed with a nonce that is not in the list")
+	}
+
+	repeated := []SchnorrFROSTCommitment{n1[0].GetCommitment(),
+		n1[0].GetCommitment()}
+	_, err = SchnorrFROSTSign(suite, shares[0], n1[0], groupkey, nil,
+		message, repeated)
+	if err == nil {
+		t.Error("Signed with a repeated signer")
+	}
+
+	// a share computed for another message or list does not verify.
+	z, err := SchnorrFROSTSign(suite, shares[0], n1[0], groupkey, nil,
+		message, commitments)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	v, _ := SchnorrFROSTVerifyShare(suite, groupkey,
+		shares[0].GetPublicKeyset(), 1, nil, []byte("another message"),
+		commitments, z)
+	if v {
+		t.Error("Share verified for another message")
+	}
+	v, _ = SchnorrFROSTVerifyShare(suite, groupkey,
+		shares[0].GetPublicKeyset(), 1, []byte("another context"), message,
+		commitments, z)
+	if v {
+		t.Error("Share verified in another context")
+	}
+	v, _ = SchnorrFROSTVerifyShare(suite, groupkey,
+		shares[0].GetPublicKeyset(), 1, nil, message, commitments, z)
+	if !v {
+		t.Error("Share did not verify")
+	}
+	swapped := []SchnorrFROSTCommitment{n1[0].GetCommitment(),
+		other[0].GetCommitment()}
+	v, _ = SchnorrFROSTVerifyShare(suite, groupkey,
+		shares[0].GetPublicKeyset(), 1, nil, message, swapped, z)
+	if v {
+		t.Error("Share verified for another commitment list")
+	}
+
+	b, _ := n1[0].GetCommitment().MarshalBinary()
+	var decoded SchnorrFROSTCommitment
+	err = decoded.UnmarshalBinary(suite, b)
+	if err != nil || decoded.Index != 1 || !decoded.D.Equal(n1[0].D) {
+		t.Error("Commitment did not survive encoding")
+	}
+	err = decoded.UnmarshalBinary(suite, b[1:])
+	if err == nil {
+		t.Error("Decoded a truncated commitment")
+	}
+}
+
+func TestFROSTNonceStoreLimits(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	_, shares, err := SchnorrTSDealShares(suite, 2, 3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	store := NewSchnorrFROSTNonceStoreWithLimits(4, time.Hour)
+	nonces, _ := SchnorrFROSTPreprocess(suite, 1, shares[0], 4)
+	err = store.Add(nonces)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	more, _ := SchnorrFROSTPreprocess(suite, 1, shares[0], 1)
+	err = store.Add(more)
+	if err != ErrFROSTNonceStoreFull {
+		t.Errorf("Add to a full store gave %v", err)
+	}
+	if store.Len() != 4 {
+		t.Errorf("Refused Add changed the store to %d nonces", store.Len())
+	}
+	_, err = store.Take(more[0].GetCommitment())
+	if err == nil {
+		t.Error("Took a nonce the store refused")
+	}
+
+	// using a nonce makes room for another.
+	_, err = store.Take(nonces[0].GetCommitment())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = store.Add(more)
+	if err != nil {
+		t.Errorf("Add after Take gave %v", err)
+	}
+
+	store = NewSchnorrFROSTNonceStoreWithLimits(4, -time.Second)
+	store.Add(nonces)
+	_, err = store.Take(nonces[1].GetCommitment())
+	if err == nil {
+		t.Error("Took an expired nonce")
+	}
+	if store.Len() != 0 {
+		t.Error("Expired nonces were kept")
+	}
+}
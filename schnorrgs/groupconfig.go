@@ -0,0 +1,252 @@
+package schnorrgs
+
+/* This file holds the multisignature group configuration that keytool
+   mkgroup writes and the sthreshold client and servers read.
+*/
+
+import (
+	"errors"
+	"strconv"
+)
+
+// One member of a multisignature group: where to reach it, its exported
+// public key and the proof that it holds the matching secret key. In a
+// threshold group PKey is the member's share key and ShareIndex the index
+// of its share.
+type SchnorrMMember struct {
+	HostName   string
+	Port       int
+	PKey       string
+	PoP        string
+	ShareIndex int `json:",omitempty"`
+}
+
+func (m SchnorrMMember) GetPKeyAsKV() (*SchnorrPublicKV, error) {
+	return NewSchnorrPublicKeyFromString(m.PKey)
+}
+
+// Checks the member's proof of possession against its key.
+func (m SchnorrMMember) VerifyProofOfPossession() error {
+	pk, err := m.GetPKeyAsKV()
+	if err != nil {
+		return err
+	}
+	if m.PoP == "" {
+		// group files from before proofs of possession, legacy or not.
+		return errors.New("Member " + m.PKey + " has no proof of " +
+			"possession. A group file made before proofs of possession " +
+			"has to be made again: run keytool pop on each member's key, " +
+			"then keytool mkgroup.")
+	}
+	suite, err := GetSuite(pk.Suite())
+	if err != nil {
+		return err
+	}
+	proof, err := NewProofOfPossessionFromString(suite, m.PoP)
+	if err != nil {
+		return err
+	}
+	ok, err := SchnorrVerifyProofOfPossession(suite, *pk, proof)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("Member " + m.PKey + " has an invalid proof of " +
+			"possession.")
+	}
+	return nil
+}
+
+// A multisignature group. JointKey is the MuSig aggregate of the member
+// keys, unless LegacyAggregation is set, in which case it is their plain
+// sum as computed by older versions of keytool.
+// If Threshold is set the group is a t-of-n threshold group instead: the
+// members hold Shamir shares of the key and any Threshold of them can
+// sign. JointKey is then the key the shares interpolate to.
+type SchnorrMGroupConfig struct {
+	Suite             string
+	JointKey          string
+	LegacyAggregation bool `json:",omitempty"`
+	Threshold         int  `json:",omitempty"`
+	Members           []SchnorrMMember
+}
+
+func (m SchnorrMGroupConfig) GetJointKeyAsKV() (*SchnorrPublicKV, error) {
+	return NewSchnorrPublicKeyFromString(m.JointKey)
+}
+
+// Returns the suite of the group. Group files written before suites were
+// recorded are all in the default suite.
+func (m SchnorrMGroupConfig) GetSuite() (CryptoSuite, error) {
+	if m.Suite == "" {
+		return GetSuite(DefaultSuite)
+	}
+	return GetSuite(m.Suite)
+}
+
+// Decodes the member keys, in member order, checking they are all in the
+// suite of the group.
+func (m SchnorrMGroupConfig) GetMemberKeys() ([]SchnorrPublicKV, error) {
+	suitename := m.Suite
+	if suitename == "" {
+		suitename = DefaultSuite
+	}
+
+	var keys []SchnorrPublicKV
+	for _, member := range m.Members {
+		pk, err := member.GetPKeyAsKV()
+		if err != nil {
+			return nil, err
+		}
+		if pk.Suite() != suitename {
+			return nil, errors.New("Member key suite " + pk.Suite() +
+				" does not match group suite " + suitename)
+		}
+		keys = append(keys, *pk)
+	}
+	return keys, nil
+}
+
+// Returns how many members have to take part in a signature.
+func (m SchnorrMGroupConfig) SignersNeeded() int {
+	if m.Threshold > 0 {
+		return m.Threshold
+	}
+	return len(m.Members)
+}
+
+// Returns the share indices of the members, in member order.
+func (m SchnorrMGroupConfig) GetShareIndices() []int {
+	var indices []int
+	for _, member := range m.Members {
+		indices = append(indices, member.ShareIndex)
+	}
+	return indices
+}
+
+// Checks the threshold settings are sane and that the member share keys
+// all lie on one polynomial of degree Threshold-1, i.e. that any
+// Threshold of them interpolate to the same group key.
+func (m SchnorrMGroupConfig) verifyShares(suite CryptoSuite,
+	keys []SchnorrPublicKV) error {
+
+	if m.LegacyAggregation {
+		return errors.New("Threshold groups can not use legacy aggregation.")
+	}
+	if m.Threshold > len(m.Members) {
+		return errors.New("Threshold " + strconv.Itoa(m.Threshold) +
+			" is more than the " + strconv.Itoa(len(m.Members)) + " members.")
+	}
+	indices := m.GetShareIndices()
+	err := checkShareIndices(indices)
+	if err != nil {
+		return err
+	}
+
+	t := m.Threshold
+	for k := t; k < len(keys); k++ {
+		expected, err := SchnorrTSInterpolatePublicKey(suite, indices[:t],
+			keys[:t], indices[k])
+		if err != nil {
+			return err
+		}
+		if !expected.pP.Equal(keys[k].pP) {
+			return errors.New("Share key of member " + strconv.Itoa(k) +
+				" is not consistent with the other shares.")
+		}
+	}
+	return nil
+}
+
+// Computes the joint key of the members, with whichever aggregation the
+// group uses.
+func (m SchnorrMGroupConfig) ComputeJointKey() (SchnorrPublicKV, error) {
+	suite, err := m.GetSuite()
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+	keys, err := m.GetMemberKeys()
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+	if m.Threshold > 0 {
+		err = m.verifyShares(suite, keys)
+		if err != nil {
+			return SchnorrPublicKV{}, err
+		}
+		return SchnorrTSInterpolatePublicKey(suite,
+			m.GetShareIndices()[:m.Threshold], keys[:m.Threshold], 0)
+	}
+	if m.LegacyAggregation {
+		return SchnorrMSComputeSharedPublicKeyLegacy(suite, keys)
+	}
+	return SchnorrMSComputeSharedPublicKey(suite, keys)
+}
+
+// Checks that every member proves possession of its key and that
+// JointKey really is the aggregate of the member keys, or for threshold
+// groups what their shares interpolate to.
+func (m SchnorrMGroupConfig) Verify() error {
+	if len(m.Members) == 0 {
+		return errors.New("Group has no members.")
+	}
+	if m.Threshold < 0 {
+		return errors.New("Negative threshold.")
+	}
+	for _, member := range m.Members {
+		err := member.VerifyProofOfPossession()
+		if err != nil {
+			return err
+		}
+	}
+	joint, err := m.GetJointKeyAsKV()
+	if err != nil {
+		return err
+	}
+	expected, err := m.ComputeJointKey()
+	if err != nil {
+		return err
+	}
+	if !joint.pP.Equal(expected.pP) || joint.suite != expected.suite {
+		if !m.LegacyAggregation && m.Threshold == 0 {
+			m.LegacyAggregation = true
+			legacy, lerr := m.ComputeJointKey()
+			if lerr == nil && joint.pP.Equal(legacy.pP) {
+				return errors.New("Group joint key is the naive sum of the " +
+					"member keys; it was made with legacy aggregation.")
+			}
+		}
+		return errors.New("Group joint key does not match the member keys.")
+	}
+	return nil
+}
+
+// Returns the index of the member holding the public half of kv, or an
+// error if kv is not in the group.
+func (m SchnorrMGroupConfig) MemberIndex(kv SchnorrSecretKV) (int, error) {
+	pk := kv.GetPublicKeyset()
+	keys, err := m.GetMemberKeys()
+	if err != nil {
+		return -1, err
+	}
+	for i, k := range keys {
+		if k.suite == pk.suite && k.pP.Equal(pk.pP) {
+			return i, nil
+		}
+	}
+	return -1, errors.New("Key is not a member of the group.")
+}
+
+// Checks the group can make partially blind multisignatures: it has to
+// verify, and be an n-of-n MuSig group, as that is the only aggregation
+// the signers in partialBlindMulti.go answer for.
+func (m SchnorrMGroupConfig) VerifyBlindSigners() error {
+	if m.Threshold > 0 {
+		return errors.New("Threshold groups can not sign partially blindly.")
+	}
+	if m.LegacyAggregation {
+		return errors.New("Groups with legacy aggregation can not sign " +
+			"partially blindly.")
+	}
+	return m.Verify()
+}
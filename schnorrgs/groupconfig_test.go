@@ -0,0 +1,179 @@
+package schnorrgs
+
+import (
+	"strings"
+	"testing"
+)
+
+func makeTestGroup(t *testing.T, n int) SchnorrMGroupConfig {
+	suite, _ := GetSuite(DefaultSuite)
+
+	config := SchnorrMGroupConfig{Suite: DefaultSuite}
+	for i := 0; i < n; i++ {
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		proof, err := SchnorrGenerateProofOfPossession(suite, kv)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		pop, err := proof.ExportProofOfPossession()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		config.Members = append(config.Members, SchnorrMMember{
+			HostName: "localhost", Port: 2220 + i,
+			PKey: kv.GetPublicKeyset().Export(), PoP: pop})
+	}
+	joint, err := config.ComputeJointKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	config.JointKey = joint.Export()
+	return config
+}
+
+func TestGroupConfigVerify(t *testing.T) {
+
+	config := makeTestGroup(t, 3)
+	err := config.Verify()
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// a legacy group must only verify when marked as one.
+	legacy := makeTestGroup(t, 3)
+	legacy.LegacyAggregation = true
+	joint, err := legacy.ComputeJointKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	legacy.JointKey = joint.Export()
+	err = legacy.Verify()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	legacy.LegacyAggregation = false
+	err = legacy.Verify()
+	if err == nil {
+		t.Error("Legacy group verified as a MuSig group")
+	}
+
+	// swapping in another member must break the joint key.
+	other := makeTestGroup(t, 1)
+	config.Members[1] = other.Members[0]
+	err = config.Verify()
+	if err == nil {
+		t.Error("Tampered group verified")
+	}
+}
+
+func TestGroupConfigProofOfPossession(t *testing.T) {
+
+	config := makeTestGroup(t, 3)
+
+	// a member without a proof is refused.
+	missing := config
+	missing.Members = append([]SchnorrMMember(nil), config.Members...)
+	missing.Members[2].PoP = ""
+	if missing.Verify() == nil {
+		t.Error("Group with a missing proof verified")
+	}
+
+	// legacy aggregation does not excuse it, but the error says how to
+	// bring an old group file up to date.
+	old := missing
+	old.LegacyAggregation = true
+	joint, err := old.ComputeJointKey()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	old.JointKey = joint.Export()
+	err = old.Verify()
+	if err == nil || !strings.Contains(err.Error(), "keytool pop") {
+		t.Errorf("Legacy group with a missing proof gave %v", err)
+	}
+
+	// as is one carrying somebody else's proof.
+	swapped := config
+	swapped.Members = append([]SchnorrMMember(nil), config.Members...)
+	swapped.Members[2].PoP = config.Members[0].PoP
+	if swapped.Verify() == nil {
+		t.Error("Group with another member's proof verified")
+	}
+
+	if config.Verify() != nil {
+		t.Error("Untouched group did not verify")
+	}
+}
+
+func TestGroupConfigThreshold(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	groupkey, shares, err := SchnorrTSDealShares(suite, 2, 4)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	config := SchnorrMGroupConfig{Suite: DefaultSuite, Threshold: 2,
+		JointKey: groupkey.Export()}
+	for i, kv := range shares {
+		proof, err := SchnorrGenerateProofOfPossession(suite, kv)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		pop, _ := proof.ExportProofOfPossession()
+		config.Members = append(config.Members, SchnorrMMember{
+			HostName: "localhost", Port: 2220 + i,
+			PKey: kv.GetPublicKeyset().Export(), PoP: pop,
+			ShareIndex: i + 1})
+	}
+
+	err = config.Verify()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if config.SignersNeeded() != 2 {
+		t.Error("Threshold group needs", config.SignersNeeded(), "signers")
+	}
+
+	// a share key off the polynomial is caught even with a valid proof.
+	other := makeTestGroup(t, 1)
+	bad := config
+	bad.Members = append([]SchnorrMMember(nil), config.Members...)
+	bad.Members[3].PKey = other.Members[0].PKey
+	bad.Members[3].PoP = other.Members[0].PoP
+	if bad.Verify() == nil {
+		t.Error("Group with an inconsistent share verified")
+	}
+
+	bad.Members = append([]SchnorrMMember(nil), config.Members...)
+	bad.Members[1].ShareIndex = 1
+	if bad.Verify() == nil {
+		t.Error("Group with a repeated share index verified")
+	}
+}
+
+func TestGroupConfigBlindSigners(t *testing.T) {
+
+	config := makeTestGroup(t, 3)
+	err := config.VerifyBlindSigners()
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	legacy := makeTestGroup(t, 3)
+	legacy.LegacyAggregation = true
+	joint, _ := legacy.ComputeJointKey()
+	legacy.JointKey = joint.Export()
+	if legacy.VerifyBlindSigners() == nil {
+		t.Error("Legacy group accepted as blind signers")
+	}
+
+	other := makeTestGroup(t, 1)
+	config.Members[1] = other.Members[0]
+	if config.VerifyBlindSigners() == nil {
+		t.Error("Tampered group accepted as blind signers")
+	}
+}
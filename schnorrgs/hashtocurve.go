@@ -0,0 +1,151 @@
+package schnorrgs
+
+/* This file implements hashing arbitrary data onto a curve point such that
+   nobody knows the discrete logarithm of the result with respect to the
+   base point. The construction is try-and-increment: we expand the input
+   with a domain-separated Blake2b in counter mode and try to decode each
+   candidate block as a point until one works.
+
+   The decoding is group specific, so each group we support has a mapper
+   below, keyed on the kyber group name (suite.String()).
+*/
+
+import (
+	"crypto/elliptic"
+	"encoding/binary"
+	"errors"
+	"github.com/dedis/kyber"
+	"golang.org/x/crypto/blake2b"
+	"math/big"
+)
+
+// Prefix of every hash-to-curve computation, so nothing else we hash
+// can collide with it.
+const hashToCurveTag = "schnorrgs-hash-to-curve-v1"
+
+// How many candidates we try before giving up. Each candidate succeeds
+// with probability roughly 1/2, so running out means something is broken.
+const hashToCurveMaxTries = 256
+
+// A pointMapper tries to turn one 64-byte candidate into a point of
+// unknown discrete log, returning false if the candidate does not map.
+type pointMapper func(suite CryptoSuite, candidate []byte) (kyber.Point, bool)
+
+var pointMappers = map[string]pointMapper{
+	"Ed25519": mapEd25519,
+	"P256":    mapP256,
+}
+
+// Ed25519: read the first 32 bytes as a compressed point and clear the
+// cofactor by multiplying by 8, so that the result lies in the prime order
+// subgroup. Small order points end up at the identity and are rejected.
+func mapEd25519(suite CryptoSuite, candidate []byte) (kyber.Point, bool) {
+	P := suite.Point()
+	err := P.UnmarshalBinary(candidate[:32])
+	if err != nil {
+		return nil, false
+	}
+
+	cofactor := suite.Scalar().SetInt64(8)
+	P.Mul(cofactor, P)
+	if P.Equal(suite.Point().Null()) {
+		return nil, false
+	}
+	return P, true
+}
+
+// P-256: read the first 32 bytes as a big-endian x coordinate, solve
+// y^2 = x^3 - 3x + b and pick the root whose parity matches the low bit of
+// the 33rd byte. The cofactor is 1.
+func mapP256(suite CryptoSuite, candidate []byte) (kyber.Point, bool) {
+	params := elliptic.P256().Params()
+
+	x := new(big.Int).SetBytes(candidate[:32])
+	if x.Cmp(params.P) >= 0 {
+		return nil, false
+	}
+
+	x3 := new(big.Int).Exp(x, big.NewInt(3), params.P)
+	threeX := new(big.Int).Mul(x, big.NewInt(3))
+	rhs := new(big.Int).Sub(x3, threeX)
+	rhs.Add(rhs, params.B)
+	rhs.Mod(rhs, params.P)
+
+	y := new(big.Int).ModSqrt(rhs, params.P)
+	if y == nil {
+		return nil, false
+	}
+	if y.Bit(0) != uint(candidate[32]&1) {
+		y.Sub(params.P, y)
+	}
+
+	// kyber's NIST points use the uncompressed SEC1 encoding.
+	encoded := make([]byte, 65)
+	encoded[0] = 4
+	x.FillBytes(encoded[1:33])
+	y.FillBytes(encoded[33:])
+
+	P := suite.Point()
+	err := P.UnmarshalBinary(encoded)
+	if err != nil {
+		return nil, false
+	}
+	return P, true
+}
+
+// Computes candidate number ctr for the given domain and data, i.e.
+// Blake2b(tag || group || len(domain) || domain || len(data) || data || ctr).
+// Lengths are 64-bit big-endian so that no two inputs share an encoding.
+func hashToCurveCandidate(group string, domain string, data []byte,
+	ctr uint32) ([]byte, error) {
+
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var lenbuf [8]byte
+	h.Write([]byte(hashToCurveTag))
+	binary.BigEndian.PutUint64(lenbuf[:], uint64(len(group)))
+	h.Write(lenbuf[:])
+	h.Write([]byte(group))
+	binary.BigEndian.PutUint64(lenbuf[:], uint64(len(domain)))
+	h.Write(lenbuf[:])
+	h.Write([]byte(domain))
+	binary.BigEndian.PutUint64(lenbuf[:], uint64(len(data)))
+	h.Write(lenbuf[:])
+	h.Write(data)
+
+	var ctrbuf [4]byte
+	binary.BigEndian.PutUint32(ctrbuf[:], ctr)
+	h.Write(ctrbuf[:])
+
+	return h.Sum(nil), nil
+}
+
+// Hashes data onto a point of the suite's group. Different domains give
+// independent points for the same data, so each protocol should use its
+// own. The result is deterministic, so two parties hashing the same
+// (domain, data) always agree on the point byte for byte.
+func HashToPoint(suite CryptoSuite, domain string,
+	data []byte) (kyber.Point, error) {
+
+	group := suite.String()
+	mapper, ok := pointMappers[group]
+	if !ok {
+		return nil, errors.New("No hash to curve function for group " + group)
+	}
+
+	for ctr := uint32(0); ctr < hashToCurveMaxTries; ctr++ {
+		candidate, err := hashToCurveCandidate(group, domain, data, ctr)
+		if err != nil {
+			return nil, err
+		}
+		P, ok := mapper(suite, candidate)
+		if ok {
+			return P, nil
+		}
+	}
+
+	return nil, errors.New("Hash to curve failed to find a point.")
+}
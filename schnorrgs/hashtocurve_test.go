@@ -0,0 +1,78 @@
+package schnorrgs
+
+import (
+	"testing"
+)
+
+// Known answer vectors for GenerateZ, so that every implementation of the
+// partially blind client and server agrees on Z byte for byte.
+var generateZVectors = []struct {
+	suite string
+	info  string
+	z     string
+}{
+	{"BlakeSHA256Ed25519", "",
+		"ce0e2f3785b1bf58a48fb19e74e84a9ad4de0dd833e5d688b8403038d3163228"},
+	{"BlakeSHA256Ed25519", "abc",
+		"d81f3d6299c4b0bc2cef81a07f270de1e05f17e74a18f18b4898488cac62d3eb"},
+	{"BlakeSHA256Ed25519", "\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f",
+		"ec92b65638eb5395274f5c08ff2dfb030d586f3528cec91af1a03f11636a1f75"},
+	{"BlakeSHA256P256", "",
+		"04ddc810b8821da6555e5893b2136b2b6c3a9135ce5eba253250b7ab3f6aaf8bed" +
+			"422de5f52d23a5b9459e584f6e0e70b8a99c051e91beee475c4150f136ff6ca7"},
+	{"BlakeSHA256P256", "abc",
+		"041c2cf3d62b312bcbd84da65a43a610b16c71f04692dba0c3d7f62d5eb0a56414" +
+			"420ed8e0e23c6e570f25fbc6d1674ce9a476f40978c7cfff6cc6ca8d2cb8847c"},
+	{"BlakeSHA256P256", "\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f",
+		"049da6f338ebd9941ec47cafe12ba00a0e8f0563f202657b05e18c264ec9c3a3b6" +
+			"1cb0c6c5e82dc0976eac107333dc26cf4e8ba94b30bdaf776cc7a75c521431e9"},
+}
+
+func TestGenerateZKnownAnswers(t *testing.T) {
+
+	for _, v := range generateZVectors {
+		suite, err := GetSuite(v.suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		z, err := GenerateZ(suite, []byte(v.info))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if z.String() != v.z {
+			t.Errorf("%s: Z for info %x is %s, expected %s",
+				v.suite, v.info, z.String(), v.z)
+		}
+	}
+}
+
+func TestHashToPointDomainSeparation(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		data := []byte("some agreed information")
+
+		p1, err := HashToPoint(suite, "domain-one", data)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		p2, err := HashToPoint(suite, "domain-two", data)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		p3, err := HashToPoint(suite, "domain-one", data)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if p1.Equal(p2) {
+			t.Errorf("%s: different domains gave the same point", name)
+		}
+		if !p1.Equal(p3) {
+			t.Errorf("%s: hashing is not deterministic", name)
+		}
+		if p1.Equal(suite.Point().Null()) {
+			t.Errorf("%s: hashed to the identity", name)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package schnorrgs
+
+/* This file implements hierarchical deterministic key derivation in the
+   manner of BIP32, so that one master key stands for any number of server
+   keys named by paths such as m/notary/3.
+
+   Every key in the tree has a chain code c next to it. A master key, that
+   is any key not derived from another, takes c = H(x). The child named
+   label is derived with
+
+       I = H_c(hardened || data || label),  x' = x + t,  P' = P + tG
+
+   where t and the child chain code c' both come from I, and data is the
+   parent public key P for a normal child and the secret key x for a
+   hardened child, written label'. Normal children can thus be derived
+   from the extended public key (P, c) alone, so a verifier holding it can
+   compute the key of any normal child without asking its server; hardened
+   ones need x.
+
+   As in BIP32, the secret key of a normal child together with the parent
+   extended public key gives away the parent secret key. Use hardened
+   steps wherever a child key may end up in less careful hands than the
+   parent.
+*/
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/dedis/kyber"
+	"golang.org/x/crypto/blake2b"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Prefixes of the derivation hashes.
+const (
+	hdMasterTag = "schnorrgs-hd-master-chain-v1"
+	hdChildTag  = "schnorrgs-hd-child-v1"
+)
+
+// Size of a chain code in bytes.
+const hdChainSize = 32
+
+// Represents an extended public key: a public key and its chain code.
+type SchnorrHDPublicKV struct {
+	suite string
+	pP    kyber.Point
+	chain []byte
+}
+
+// Returns the name of the suite this key belongs to.
+func (p SchnorrHDPublicKV) Suite() string {
+	return p.suite
+}
+
+// Returns the plain public key, for verifying signatures.
+func (p SchnorrHDPublicKV) GetPublicKeyset() SchnorrPublicKV {
+	return SchnorrPublicKV{suite: p.suite, pP: p.pP}
+}
+
+// Exports an extended public key as suite;P;chaincode, like a public key
+// with the chain code added.
+func (p SchnorrHDPublicKV) Export() string {
+	return p.suite + ";" + p.pP.String() + ";" + hex.EncodeToString(p.chain)
+}
+
+// Reads an extended public key written by Export.
+func NewSchnorrHDPublicKeyFromString(source string) (*SchnorrHDPublicKV,
+	error) {
+
+	splitsource := strings.Split(strings.TrimSpace(source), ";")
+	if len(splitsource) != 3 {
+		return nil, errors.New("Invalid extended public key encoding.")
+	}
+	pk, err := NewSchnorrPublicKeyFromString(splitsource[0] + ";" +
+		splitsource[1])
+	if err != nil {
+		return nil, err
+	}
+	chain, err := hex.DecodeString(splitsource[2])
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) != hdChainSize {
+		return nil, errors.New("Invalid chain code length.")
+	}
+	return &SchnorrHDPublicKV{suite: pk.suite, pP: pk.pP, chain: chain}, nil
+}
+
+// Returns the chain code of a key: the recorded one for derived keys, and
+// H(x) for master keys.
+func (k SchnorrSecretKV) chainCode() ([]byte, error) {
+	if k.chain != nil {
+		return k.chain, nil
+	}
+	x, err := k.s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		return nil, err
+	}
+	h.Write([]byte(hdMasterTag))
+	h.Write([]byte(k.suite))
+	h.Write(x)
+	return h.Sum(nil), nil
+}
+
+// Returns the extended public key of a key, from which its normal
+// children's public keys can be derived.
+func (k SchnorrSecretKV) GetHDPublicKeyset() (SchnorrHDPublicKV, error) {
+	chain, err := k.chainCode()
+	if err != nil {
+		return SchnorrHDPublicKV{}, err
+	}
+	return SchnorrHDPublicKV{suite: k.suite, pP: k.pP, chain: chain}, nil
+}
+
+// One step of a derivation path.
+type hdStep struct {
+	label    string
+	hardened bool
+}
+
+// Splits a path m/a/b'/c into its steps. Labels are any non-empty
+// strings without a slash; a trailing ' makes a step hardened.
+func parseHDPath(path string) ([]hdStep, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, errors.New("Derivation path does not start with m: " + path)
+	}
+	var steps []hdStep
+	for _, part := range parts[1:] {
+		step := hdStep{label: part}
+		if strings.HasSuffix(part, "'") {
+			step = hdStep{label: strings.TrimSuffix(part, "'"), hardened: true}
+		}
+		if step.label == "" || len(step.label) > 255 {
+			return nil, errors.New("Invalid step in derivation path: " + path)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// Computes the tweak t and child chain code of one step from the parent
+// chain code and data, which is P or x.
+func hdChildTweak(suite CryptoSuite, chain []byte, step hdStep,
+	data []byte) (kyber.Scalar, []byte, error) {
+
+	hash := func(purpose byte) ([]byte, error) {
+		h, err := blake2b.New512(chain)
+		if err != nil {
+			return nil, err
+		}
+		var hardened byte
+		if step.hardened {
+			hardened = 1
+		}
+		h.Write([]byte(hdChildTag))
+		h.Write([]byte{purpose, hardened})
+		h.Write(data)
+		h.Write([]byte{byte(len(step.label))})
+		h.Write([]byte(step.label))
+		return h.Sum(nil), nil
+	}
+
+	tb, err := hash('t')
+	if err != nil {
+		return nil, nil, err
+	}
+	cb, err := hash('c')
+	if err != nil {
+		return nil, nil, err
+	}
+	t := suite.Scalar().SetBytes(tb)
+	if t.Equal(suite.Scalar().Zero()) {
+		return nil, nil, errors.New("Derivation step gives no key, skip it.")
+	}
+	return t, cb[:hdChainSize], nil
+}
+
+// Derives the secret key at path, such as m/notary/3 or m/notary'/3, below
+// kv. The path m gives kv back with its chain code.
+func SchnorrHDDeriveSecret(suite CryptoSuite, kv SchnorrSecretKV,
+	path string) (SchnorrSecretKV, error) {
+
+	err := checkSuite(suite, kv.suite)
+	if err != nil {
+		return SchnorrSecretKV{}, err
+	}
+	steps, err := parseHDPath(path)
+	if err != nil {
+		return SchnorrSecretKV{}, err
+	}
+	chain, err := kv.chainCode()
+	if err != nil {
+		return SchnorrSecretKV{}, err
+	}
+
+	x := kv.s
+	P := kv.pP
+	for _, step := range steps {
+		var data []byte
+		if step.hardened {
+			data, err = x.MarshalBinary()
+		} else {
+			data, err = P.MarshalBinary()
+		}
+		if err != nil {
+			return SchnorrSecretKV{}, err
+		}
+		var t kyber.Scalar
+		t, chain, err = hdChildTweak(suite, chain, step, data)
+		if err != nil {
+			return SchnorrSecretKV{}, err
+		}
+		x = suite.Scalar().Add(x, t)
+		P = suite.Point().Mul(x, nil)
+	}
+
+	return SchnorrSecretKV{suite: kv.suite, s: x, pP: P, chain: chain}, nil
+}
+
+// Derives the extended public key at path below xpub. Every step has to
+// be a normal one.
+func SchnorrHDDerivePublic(suite CryptoSuite, xpub SchnorrHDPublicKV,
+	path string) (SchnorrHDPublicKV, error) {
+
+	err := checkSuite(suite, xpub.suite)
+	if err != nil {
+		return SchnorrHDPublicKV{}, err
+	}
+	steps, err := parseHDPath(path)
+	if err != nil {
+		return SchnorrHDPublicKV{}, err
+	}
+
+	chain := xpub.chain
+	P := xpub.pP
+	for _, step := range steps {
+		if step.hardened {
+			return SchnorrHDPublicKV{}, errors.New("Hardened step " +
+				step.label + "' needs the secret key.")
+		}
+		data, err := P.MarshalBinary()
+		if err != nil {
+			return SchnorrHDPublicKV{}, err
+		}
+		var t kyber.Scalar
+		t, chain, err = hdChildTweak(suite, chain, step, data)
+		if err != nil {
+			return SchnorrHDPublicKV{}, err
+		}
+		P = suite.Point().Add(P, suite.Point().Mul(t, nil))
+	}
+
+	return SchnorrHDPublicKV{suite: xpub.suite, pP: P, chain: chain}, nil
+}
+
+// Loads an extended public key from disk.
+func SchnorrLoadHDPubkey(path string) (*SchnorrHDPublicKV, error) {
+	fcontents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchnorrHDPublicKeyFromString(string(fcontents))
+}
+
+// Saves an extended public key to disk.
+func SchnorrSaveHDPubkey(path string, k SchnorrHDPublicKV) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write([]byte(k.Export()))
+	return err
+}
@@ -0,0 +1,136 @@
+package schnorrgs
+
+import (
+	"testing"
+)
+
+func TestSchnorrHDDerivation(t *testing.T) {
+
+	msg := []byte("notary receipt")
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		master, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		xpub, err := master.GetHDPublicKeyset()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		child, err := SchnorrHDDeriveSecret(suite, master, "m/notary/3")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		pubchild, err := SchnorrHDDerivePublic(suite, xpub, "m/notary/3")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !pubchild.GetPublicKeyset().pP.Equal(child.pP) {
+			t.Errorf("%s: public derivation disagrees with secret derivation",
+				name)
+		}
+
+		// the child is an ordinary key.
+		sig, err := SchnorrSign(suite, child, msg)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		ok, _ := SchnorrVerify(suite, pubchild.GetPublicKeyset(), msg, sig)
+		if !ok {
+			t.Errorf("%s: child signature did not verify", name)
+		}
+
+		// derivation is deterministic and step by step.
+		notary, _ := SchnorrHDDeriveSecret(suite, master, "m/notary")
+		again, _ := SchnorrHDDeriveSecret(suite, notary, "m/3")
+		if !again.s.Equal(child.s) {
+			t.Errorf("%s: m/notary then m/3 differs from m/notary/3", name)
+		}
+		notarypub, _ := notary.GetHDPublicKeyset()
+		againpub, _ := SchnorrHDDerivePublic(suite, notarypub, "m/3")
+		if againpub.Export() != pubchild.Export() {
+			t.Errorf("%s: extended public keys differ", name)
+		}
+
+		// siblings, hardened children and m itself.
+		sibling, _ := SchnorrHDDeriveSecret(suite, master, "m/notary/4")
+		hardened, _ := SchnorrHDDeriveSecret(suite, master, "m/notary'/3")
+		if sibling.pP.Equal(child.pP) || hardened.pP.Equal(child.pP) {
+			t.Errorf("%s: different paths gave the same key", name)
+		}
+		self, _ := SchnorrHDDeriveSecret(suite, master, "m")
+		if !self.s.Equal(master.s) {
+			t.Errorf("%s: path m changed the key", name)
+		}
+	}
+}
+
+func TestSchnorrHDHardenedNeedsSecret(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		master, _ := SchnorrGenerateKeypair(suite)
+		xpub, _ := master.GetHDPublicKeyset()
+
+		_, err := SchnorrHDDerivePublic(suite, xpub, "m/notary'/3")
+		if err == nil {
+			t.Errorf("%s: derived a hardened child from a public key", name)
+		}
+
+		// the public key of a normal child below a hardened one comes
+		// from the hardened key's extended public key.
+		hard, _ := SchnorrHDDeriveSecret(suite, master, "m/notary'")
+		hardpub, _ := hard.GetHDPublicKeyset()
+		child, _ := SchnorrHDDeriveSecret(suite, master, "m/notary'/3")
+		pubchild, err := SchnorrHDDerivePublic(suite, hardpub, "m/3")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !pubchild.pP.Equal(child.pP) {
+			t.Errorf("%s: normal child of a hardened key differs", name)
+		}
+	}
+}
+
+func TestSchnorrHDExport(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		master, _ := SchnorrGenerateKeypair(suite)
+		child, _ := SchnorrHDDeriveSecret(suite, master, "m/notary/3")
+
+		// the chain code survives a round trip through a key file, so
+		// the child derives the same grandchildren afterwards.
+		imported, err := NewSchnorrSecretKVFromImport(child.Export())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		a, _ := SchnorrHDDeriveSecret(suite, child, "m/x")
+		b, _ := SchnorrHDDeriveSecret(suite, *imported, "m/x")
+		if !a.s.Equal(b.s) {
+			t.Errorf("%s: chain code lost in export", name)
+		}
+
+		xpub, _ := child.GetHDPublicKeyset()
+		parsed, err := NewSchnorrHDPublicKeyFromString(xpub.Export())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if parsed.Export() != xpub.Export() {
+			t.Errorf("%s: extended public key did not round trip", name)
+		}
+	}
+}
+
+func TestSchnorrHDBadPaths(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	master, _ := SchnorrGenerateKeypair(suite)
+	for _, path := range []string{"", "notary/3", "m/", "m//3", "m/'", "x/1"} {
+		_, err := SchnorrHDDeriveSecret(suite, master, path)
+		if err == nil {
+			t.Errorf("Path %q accepted", path)
+		}
+	}
+}
@@ -1,27 +1,52 @@
 package schnorrgs
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 )
 
-// Loads the key pair as a binary blob from a file on disk
+// Loads the key pair as a binary blob from a file on disk. Encrypted key
+// files give ErrEncryptedSecretKV; use SchnorrLoadSecretKVWithPassphrase
+// for those.
 func SchnorrLoadSecretKV(path string) (*SchnorrSecretKV,
 	error) {
 
+	kv, _, err := SchnorrLoadSecretKVWithPassphrase(path, nil)
+	return kv, err
+}
+
+// Loads a key pair from disk, encrypted or not. passphrase is only called
+// if the file is encrypted; if it is nil, encrypted files give
+// ErrEncryptedSecretKV. Also returns whether the file was encrypted, so
+// that callers can warn about plaintext keys.
+func SchnorrLoadSecretKVWithPassphrase(path string,
+	passphrase func() ([]byte, error)) (*SchnorrSecretKV, bool, error) {
+
 	fcontents, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+	if !IsEncryptedSecretKV(fcontents) {
+		kv, err := NewSchnorrSecretKVFromImport(fcontents)
+		return kv, false, err
 	}
-	kv, err := NewSchnorrSecretKVFromImport(fcontents)
 
-	return kv, err
+	if passphrase == nil {
+		return nil, true, ErrEncryptedSecretKV
+	}
+	pass, err := passphrase()
+	if err != nil {
+		return nil, true, err
+	}
+	kv, err := NewSchnorrSecretKVFromEncryptedImport(fcontents, pass)
+	return kv, true, err
 }
 
 // Saves the keypair as a binary blob on disk. The file format
 // matches abstract.Write(...) so whatever that uses, we're using here.
 func SchnorrSaveSecretKV(path string, kv SchnorrSecretKV) error {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
 	if err != nil {
 		return err
 	}
@@ -31,6 +56,23 @@ func SchnorrSaveSecretKV(path string, kv SchnorrSecretKV) error {
 	return err
 }
 
+// Saves the keypair on disk encrypted under passphrase.
+func SchnorrSaveEncryptedSecretKV(path string, kv SchnorrSecretKV,
+	passphrase []byte) error {
+
+	binkey, err := kv.ExportEncrypted(passphrase)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(binkey)
+	return err
+}
+
 // Loads only the public key from disk.
 func SchnorrLoadPubkey(path string) (*SchnorrPublicKV,
 	error) {
@@ -49,7 +91,7 @@ func SchnorrLoadPubkey(path string) (*SchnorrPublicKV,
 // Saves only the public key to disk.
 func SchnorrSavePubkey(path string, k SchnorrPublicKV) error {
 	buf := []byte(k.Export())
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
 	if err != nil {
 		return err
 	}
@@ -57,3 +99,86 @@ func SchnorrSavePubkey(path string, k SchnorrPublicKV) error {
 	_, err = f.Write(buf)
 	return err
 }
+
+// Loads a multisignature group configuration from disk.
+func SchnorrLoadGroupConfig(path string) (*SchnorrMGroupConfig, error) {
+	fcontents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config SchnorrMGroupConfig
+	err = json.Unmarshal(fcontents, &config)
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Saves a multisignature group configuration to disk as JSON.
+func SchnorrSaveGroupConfig(path string, config SchnorrMGroupConfig) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+// Loads a proof of possession, as written by SchnorrSaveProofOfPossession.
+func SchnorrLoadProofOfPossession(path string,
+	suite CryptoSuite) (SchnorrSignature, error) {
+
+	fcontents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+	return NewProofOfPossessionFromString(suite, string(fcontents))
+}
+
+// Saves a proof of possession to disk as hex.
+func SchnorrSaveProofOfPossession(path string, proof SchnorrSignature) error {
+	s, err := proof.ExportProofOfPossession()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write([]byte(s))
+	return err
+}
+
+// Loads a denomination catalogue from disk. It still has to be verified.
+func SchnorrLoadDenominationCatalogue(path string) (*SchnorrDenominationCatalogue,
+	error) {
+
+	fcontents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchnorrDenominationCatalogueFromJSON(fcontents)
+}
+
+// Saves a denomination catalogue to disk as JSON.
+func SchnorrSaveDenominationCatalogue(path string,
+	c SchnorrDenominationCatalogue) error {
+
+	data, err := c.Export()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
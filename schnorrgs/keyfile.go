@@ -0,0 +1,209 @@
+package schnorrgs
+
+/* This file implements passphrase-encrypted private key files.
+
+   The secret key is serialised exactly as in a plaintext key file and
+   sealed with AES-256-GCM under a key derived from the passphrase with
+   scrypt. The envelope is JSON, like the plaintext file, and records the
+   format version, the scrypt parameters and salt, the cipher and nonce,
+   and the suite and public key in the clear, so that the public half can
+   be told without the passphrase. Everything in the envelope except the
+   ciphertext is bound to it as additional data, so none of it can be
+   changed without the decryption failing.
+*/
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"golang.org/x/crypto/scrypt"
+	"strconv"
+)
+
+// Version of the encrypted key file format written by this package.
+const EncryptedKeyFileVersion = 1
+
+const (
+	keyFileKDF    = "scrypt"
+	keyFileCipher = "AES-256-GCM"
+)
+
+// scrypt parameters for new files: N = 2^15, r = 8, p = 1 take about
+// 32MB and a tenth of a second, the values the scrypt paper suggests for
+// interactive logins.
+const (
+	keyFileScryptN = 1 << 15
+	keyFileScryptR = 8
+	keyFileScryptP = 1
+)
+
+// Limits on the parameters we accept, so that a hostile key file can not
+// make us spend unbounded memory or time.
+const (
+	keyFileMaxScryptN  = 1 << 20
+	keyFileMaxScryptRP = 64
+)
+
+// This structure is only used for JSON serialization of encrypted key
+// files.
+type SchnorrEncryptedSecretDiskRepr struct {
+	Version    int
+	Suite      string
+	P          string
+	KDF        string
+	N          int
+	R          int
+	Parallel   int
+	Salt       string
+	Cipher     string
+	Nonce      string
+	Ciphertext string
+}
+
+// Returned when a key file is encrypted and no passphrase was given.
+var ErrEncryptedSecretKV = errors.New("Key file is encrypted; a passphrase " +
+	"is needed.")
+
+// Returned when an encrypted key file does not open with the passphrase.
+var ErrWrongPassphrase = errors.New("Wrong passphrase, or the key file has " +
+	"been tampered with.")
+
+// Tells whether the contents of a key file are an encrypted key.
+func IsEncryptedSecretKV(source []byte) bool {
+	var probe struct {
+		Version    int
+		Ciphertext string
+	}
+	err := json.Unmarshal(source, &probe)
+	return err == nil && (probe.Version != 0 || probe.Ciphertext != "")
+}
+
+// Everything but the nonce and ciphertext, as additional data for the AEAD.
+func (e SchnorrEncryptedSecretDiskRepr) additionalData() []byte {
+	ad := "schnorrgs-encrypted-key;" + strconv.Itoa(e.Version) + ";" +
+		e.Suite + ";" + e.P + ";" + e.KDF + ";" + strconv.Itoa(e.N) + ";" +
+		strconv.Itoa(e.R) + ";" + strconv.Itoa(e.Parallel) + ";" + e.Salt +
+		";" + e.Cipher
+	return []byte(ad)
+}
+
+// Derives the AES-256 key from the passphrase and opens a GCM instance.
+func (e SchnorrEncryptedSecretDiskRepr) aead(passphrase []byte) (cipher.AEAD,
+	error) {
+
+	if e.KDF != keyFileKDF {
+		return nil, errors.New("Unsupported key derivation " + e.KDF)
+	}
+	if e.Cipher != keyFileCipher {
+		return nil, errors.New("Unsupported cipher " + e.Cipher)
+	}
+	if e.N < 2 || e.N > keyFileMaxScryptN || e.R < 1 || e.Parallel < 1 ||
+		e.R*e.Parallel > keyFileMaxScryptRP {
+		return nil, errors.New("Key file scrypt parameters out of range.")
+	}
+	salt, err := hex.DecodeString(e.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := scrypt.Key(passphrase, salt, e.N, e.R, e.Parallel, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Exports a secret key for on-disk storage, encrypted under passphrase.
+func (k SchnorrSecretKV) ExportEncrypted(passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("Empty passphrase.")
+	}
+
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	e := SchnorrEncryptedSecretDiskRepr{
+		Version:  EncryptedKeyFileVersion,
+		Suite:    k.suite,
+		P:        k.pP.String(),
+		KDF:      keyFileKDF,
+		N:        keyFileScryptN,
+		R:        keyFileScryptR,
+		Parallel: keyFileScryptP,
+		Salt:     hex.EncodeToString(salt),
+		Cipher:   keyFileCipher,
+	}
+
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := aead.Seal(nil, nonce, k.Export(), e.additionalData())
+	e.Nonce = hex.EncodeToString(nonce)
+	e.Ciphertext = hex.EncodeToString(sealed)
+
+	return json.Marshal(e)
+}
+
+// Creates a SchnorrSecretKV from an encrypted key file's contents and its
+// passphrase.
+func NewSchnorrSecretKVFromEncryptedImport(source []byte,
+	passphrase []byte) (*SchnorrSecretKV, error) {
+
+	var e SchnorrEncryptedSecretDiskRepr
+	err := json.Unmarshal(source, &e)
+	if err != nil {
+		return nil, err
+	}
+	if e.Version != EncryptedKeyFileVersion {
+		return nil, errors.New("Unsupported key file version " +
+			strconv.Itoa(e.Version))
+	}
+
+	aead, err := e.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(e.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("Invalid key file nonce length.")
+	}
+	sealed, err := hex.DecodeString(e.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := aead.Open(nil, nonce, sealed, e.additionalData())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	kv, err := NewSchnorrSecretKVFromImport(plain)
+	if err != nil {
+		return nil, err
+	}
+	if kv.suite != e.Suite || kv.pP.String() != e.P {
+		return nil, errors.New("Key file public key does not match the " +
+			"encrypted key.")
+	}
+	return kv, nil
+}
@@ -0,0 +1,153 @@
+package schnorrgs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncryptedKeyFile(t *testing.T) {
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		dir, err := ioutil.TempDir("", "keyfile")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "key.pri")
+
+		err = SchnorrSaveEncryptedSecretKV(path, kv, []byte("correct horse"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		contents, _ := ioutil.ReadFile(path)
+		if !IsEncryptedSecretKV(contents) {
+			t.Error("Encrypted key file not detected as encrypted.")
+		}
+
+		_, err = SchnorrLoadSecretKV(path)
+		if err != ErrEncryptedSecretKV {
+			t.Error("Loading an encrypted key without a passphrase did not " +
+				"ask for one.")
+		}
+
+		loaded, encrypted, err := SchnorrLoadSecretKVWithPassphrase(path,
+			func() ([]byte, error) { return []byte("correct horse"), nil })
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !encrypted || !loaded.s.Equal(kv.s) || !loaded.pP.Equal(kv.pP) ||
+			loaded.suite != kv.suite {
+			t.Error("Encrypted key did not load back as the same key.")
+		}
+
+		_, _, err = SchnorrLoadSecretKVWithPassphrase(path,
+			func() ([]byte, error) { return []byte("battery staple"), nil })
+		if err != ErrWrongPassphrase {
+			t.Error("Wrong passphrase was not refused.")
+		}
+
+		// the parameters in the clear are bound to the ciphertext.
+		var e SchnorrEncryptedSecretDiskRepr
+		json.Unmarshal(contents, &e)
+		e.N = 1 << 14
+		tampered, _ := json.Marshal(e)
+		_, err = NewSchnorrSecretKVFromEncryptedImport(tampered,
+			[]byte("correct horse"))
+		if err == nil {
+			t.Error("Tampered key file parameters were accepted.")
+		}
+
+		json.Unmarshal(contents, &e)
+		e.N = 1 << 30
+		tampered, _ = json.Marshal(e)
+		_, err = NewSchnorrSecretKVFromEncryptedImport(tampered,
+			[]byte("correct horse"))
+		if err == nil {
+			t.Error("Oversized scrypt parameters were accepted.")
+		}
+	}
+}
+
+func TestPlaintextKeyFileStillLoads(t *testing.T) {
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+
+	dir, err := ioutil.TempDir("", "keyfile")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key.pri")
+
+	err = SchnorrSaveSecretKV(path, kv)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	called := false
+	loaded, encrypted, err := SchnorrLoadSecretKVWithPassphrase(path,
+		func() ([]byte, error) { called = true; return nil, nil })
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if encrypted || called {
+		t.Error("Plaintext key file treated as encrypted.")
+	}
+	if !loaded.s.Equal(kv.s) {
+		t.Error("Plaintext key did not load back as the same key.")
+	}
+}
+
+func TestOverwrittenKeyFiles(t *testing.T) {
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+	meta, err := NewSchnorrKeyMetadata(kv.GetPublicKeyset(), time.Time{},
+		UsageNotary)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	long, err := kv.WithMetadata(meta)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dir, err := ioutil.TempDir("", "keyfile")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	pri := filepath.Join(dir, "key.pri")
+	pub := filepath.Join(dir, "key.pub")
+
+	// a key with metadata, then the same key without, which is shorter.
+	if SchnorrSaveSecretKV(pri, long) != nil ||
+		SchnorrSavePubkey(pub, long.GetPublicKeyset()) != nil ||
+		SchnorrSaveSecretKV(pri, kv) != nil ||
+		SchnorrSavePubkey(pub, kv.GetPublicKeyset()) != nil {
+		t.Fatal("Saving failed")
+	}
+
+	loaded, err := SchnorrLoadSecretKV(pri)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if loaded.Metadata() != nil {
+		t.Error("Overwritten key file kept the old metadata")
+	}
+	pk, err := SchnorrLoadPubkey(pub)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if pk.Metadata() != nil {
+		t.Error("Overwritten public key file kept the old metadata")
+	}
+}
@@ -0,0 +1,239 @@
+package schnorrgs
+
+/* This file implements the metadata block of key files. A key made by
+   keytool gen records
+
+     - a key ID, the fingerprint of the public key, so that a file can be
+       checked against the key it claims to hold;
+     - when it was created and, optionally, when it expires;
+     - what it may be used for. An empty list allows every use.
+
+   The block is JSON with a version number. Private key files carry it as
+   their Meta field; public key files as a third, base64 field after
+   suite;hex. Files without it are keys from before metadata, which are
+   loaded as they always were and may be used for anything.
+*/
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"golang.org/x/crypto/blake2b"
+	"time"
+)
+
+// Version of the metadata block we write.
+const KeyMetadataVersion = 1
+
+// Prefix of the key fingerprint hash.
+const keyIDTag = "schnorrgs-key-id-v1"
+
+// What a key may be used for.
+type SchnorrKeyUsage string
+
+const (
+	UsageNotary   SchnorrKeyUsage = "notary"
+	UsageMultisig SchnorrKeyUsage = "multisig"
+	UsageBlind    SchnorrKeyUsage = "blind"
+	UsageProof    SchnorrKeyUsage = "proof"
+)
+
+// Lists every usage, for command line parsing.
+func KeyUsages() []string {
+	return []string{string(UsageNotary), string(UsageMultisig),
+		string(UsageBlind), string(UsageProof)}
+}
+
+// Represents the metadata block of a key. Keys without Expires never
+// expire.
+type SchnorrKeyMetadata struct {
+	Version int
+	KeyID   string
+	Created time.Time
+	Expires *time.Time        `json:",omitempty"`
+	Usage   []SchnorrKeyUsage `json:",omitempty"`
+}
+
+// Computes the key ID of a public key: the first 16 bytes of
+// H(tag || suite || P), in hex.
+func SchnorrKeyID(kp SchnorrPublicKV) (string, error) {
+	p, err := kp.pP.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		return "", err
+	}
+	h.Write([]byte(keyIDTag))
+	h.Write([]byte{byte(len(kp.suite))})
+	h.Write([]byte(kp.suite))
+	h.Write(p)
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+// Makes the metadata block for a key created now. A zero expires never
+// expires; no usage allows every use.
+func NewSchnorrKeyMetadata(kp SchnorrPublicKV, expires time.Time,
+	usage ...SchnorrKeyUsage) (SchnorrKeyMetadata, error) {
+
+	id, err := SchnorrKeyID(kp)
+	if err != nil {
+		return SchnorrKeyMetadata{}, err
+	}
+	for _, u := range usage {
+		if !validUsage(u) {
+			return SchnorrKeyMetadata{}, errors.New("Unknown key usage " +
+				string(u))
+		}
+	}
+	created := time.Now().UTC().Truncate(time.Second)
+	if !expires.IsZero() && !expires.After(created) {
+		return SchnorrKeyMetadata{}, errors.New("Key would expire before it is created.")
+	}
+	meta := SchnorrKeyMetadata{
+		Version: KeyMetadataVersion,
+		KeyID:   id,
+		Created: created,
+		Usage:   usage,
+	}
+	if !expires.IsZero() {
+		e := expires.UTC()
+		meta.Expires = &e
+	}
+	return meta, nil
+}
+
+func validUsage(u SchnorrKeyUsage) bool {
+	for _, name := range KeyUsages() {
+		if string(u) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks that a metadata block read from a file is one we understand and
+// belongs to the key next to it.
+func (m SchnorrKeyMetadata) check(kp SchnorrPublicKV) error {
+	if m.Version != KeyMetadataVersion {
+		return errors.New("Unsupported key metadata version.")
+	}
+	id, err := SchnorrKeyID(kp)
+	if err != nil {
+		return err
+	}
+	if m.KeyID != id {
+		return errors.New("Key ID " + m.KeyID + " does not match the key.")
+	}
+	for _, u := range m.Usage {
+		if !validUsage(u) {
+			return errors.New("Unknown key usage " + string(u))
+		}
+	}
+	return nil
+}
+
+// Tells whether the key has expired by now.
+func (m SchnorrKeyMetadata) Expired(now time.Time) bool {
+	return m.Expires != nil && !now.Before(*m.Expires)
+}
+
+// Tells whether the key may be used for usage.
+func (m SchnorrKeyMetadata) Allows(usage SchnorrKeyUsage) bool {
+	if len(m.Usage) == 0 {
+		return true
+	}
+	for _, u := range m.Usage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns an error unless a key with metadata meta may be used for usage
+// now. Keys without metadata may always be used.
+func checkKeyUsage(meta *SchnorrKeyMetadata, usage SchnorrKeyUsage) error {
+	if meta == nil {
+		return nil
+	}
+	if meta.Expired(time.Now()) {
+		return errors.New("Key " + meta.KeyID + " expired on " +
+			meta.Expires.Format(time.RFC3339))
+	}
+	if usage != "" && !meta.Allows(usage) {
+		return errors.New("Key " + meta.KeyID + " may not be used for " +
+			string(usage))
+	}
+	return nil
+}
+
+// Returns the metadata of a key, or nil for keys from before metadata.
+func (k SchnorrSecretKV) Metadata() *SchnorrKeyMetadata {
+	return k.meta
+}
+
+// Returns the metadata of a public key, or nil for keys from before
+// metadata.
+func (p SchnorrPublicKV) Metadata() *SchnorrKeyMetadata {
+	return p.meta
+}
+
+// Returns the key with the given metadata, which has to belong to it.
+func (k SchnorrSecretKV) WithMetadata(meta SchnorrKeyMetadata) (SchnorrSecretKV,
+	error) {
+
+	err := meta.check(k.GetPublicKeyset())
+	if err != nil {
+		return SchnorrSecretKV{}, err
+	}
+	k.meta = &meta
+	return k, nil
+}
+
+// Returns an error unless the key is unexpired and may be used for usage.
+// Servers call this on their key at startup.
+func (k SchnorrSecretKV) CheckUsage(usage SchnorrKeyUsage) error {
+	return checkKeyUsage(k.meta, usage)
+}
+
+// Returns an error unless the public key is unexpired and may be used for
+// usage, for clients about to rely on it.
+func (p SchnorrPublicKV) CheckUsage(usage SchnorrKeyUsage) error {
+	return checkKeyUsage(p.meta, usage)
+}
+
+// Encodes a metadata block for the third field of a public key file.
+func (m SchnorrKeyMetadata) exportPublic() (string, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// Decodes the third field of a public key file.
+func importPublicMetadata(s string) (*SchnorrKeyMetadata, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("Invalid key metadata encoding.")
+	}
+	var m SchnorrKeyMetadata
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Checks that a key is in the suite and has not expired before it signs
+// anything. What it signs for is up to the caller to check.
+func checkSigningKey(suite CryptoSuite, kv SchnorrSecretKV) error {
+	err := checkSuite(suite, kv.suite)
+	if err != nil {
+		return err
+	}
+	return checkKeyUsage(kv.meta, "")
+}
@@ -0,0 +1,151 @@
+package schnorrgs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyMetadataRoundTrip(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+		expires := time.Now().Add(24 * time.Hour)
+		meta, err := NewSchnorrKeyMetadata(kv.GetPublicKeyset(), expires,
+			UsageNotary, UsageProof)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		kv, err = kv.WithMetadata(meta)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		imported, err := NewSchnorrSecretKVFromImport(kv.Export())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		pk, err := NewSchnorrPublicKeyFromString(kv.GetPublicKeyset().Export())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for _, m := range []*SchnorrKeyMetadata{imported.Metadata(), pk.Metadata()} {
+			if m == nil {
+				t.Fatalf("%s: metadata lost", name)
+			}
+			if m.KeyID != meta.KeyID || !m.Created.Equal(meta.Created) ||
+				!m.Expires.Equal(*meta.Expires) || len(m.Usage) != 2 {
+				t.Errorf("%s: metadata changed in a round trip", name)
+			}
+		}
+
+		if imported.CheckUsage(UsageNotary) != nil || pk.CheckUsage(UsageProof) != nil {
+			t.Errorf("%s: allowed usage refused", name)
+		}
+		if imported.CheckUsage(UsageMultisig) == nil || pk.CheckUsage(UsageBlind) == nil {
+			t.Errorf("%s: usage outside the list allowed", name)
+		}
+	}
+}
+
+func TestKeyMetadataLegacyFiles(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+
+		// files from before metadata: no Meta, and suite;hex.
+		imported, err := NewSchnorrSecretKVFromImport(kv.Export())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if strings.Contains(string(kv.Export()), "Meta") {
+			t.Errorf("%s: key without metadata wrote a Meta field", name)
+		}
+		pk, err := NewSchnorrPublicKeyFromString(name + ";" + kv.pP.String())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if imported.Metadata() != nil || pk.Metadata() != nil {
+			t.Errorf("%s: legacy key has metadata", name)
+		}
+		for _, u := range KeyUsages() {
+			if imported.CheckUsage(SchnorrKeyUsage(u)) != nil {
+				t.Errorf("%s: legacy key refused for %s", name, u)
+			}
+		}
+	}
+}
+
+func TestKeyMetadataExpiry(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+	meta, err := NewSchnorrKeyMetadata(kv.GetPublicKeyset(), time.Time{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	past := time.Now().Add(-time.Hour)
+	meta.Expires = &past
+	kv, err = kv.WithMetadata(meta)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if kv.CheckUsage(UsageNotary) == nil {
+		t.Error("Expired key allowed")
+	}
+	_, err = SchnorrSign(suite, kv, []byte("late"))
+	if err == nil {
+		t.Error("Expired key signed")
+	}
+	_, err = SchnorrMSComputeResponse(suite, suite.Scalar().One(),
+		[]SchnorrPublicKV{kv.GetPublicKeyset()}, kv,
+		SchnorrMSGenerateCommitment(suite))
+	if err == nil {
+		t.Error("Expired key answered a multisignature challenge")
+	}
+	params, err := NewPrivateParams(suite, []byte("late"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = ServerGenerateResponse(suite,
+		WISchnorrChallengeMessage{E: suite.Scalar().One()}, params, kv)
+	if err == nil {
+		t.Error("Expired key answered a blind signature challenge")
+	}
+
+	_, err = NewSchnorrKeyMetadata(kv.GetPublicKeyset(), past)
+	if err == nil {
+		t.Error("Made metadata that expired already")
+	}
+}
+
+func TestKeyMetadataRejectsMismatch(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+	other, _ := SchnorrGenerateKeypair(suite)
+	meta, _ := NewSchnorrKeyMetadata(other.GetPublicKeyset(), time.Time{})
+
+	_, err := kv.WithMetadata(meta)
+	if err == nil {
+		t.Error("Attached another key's metadata")
+	}
+
+	// a public key file with another key's metadata block.
+	own, _ := other.WithMetadata(meta)
+	fields := strings.Split(own.GetPublicKeyset().Export(), ";")
+	_, err = NewSchnorrPublicKeyFromString(DefaultSuite + ";" +
+		kv.pP.String() + ";" + fields[2])
+	if err == nil {
+		t.Error("Loaded a public key with another key's metadata")
+	}
+
+	_, err = NewSchnorrKeyMetadata(kv.GetPublicKeyset(), time.Time{},
+		SchnorrKeyUsage("everything"))
+	if err == nil {
+		t.Error("Accepted an unknown usage")
+	}
+}
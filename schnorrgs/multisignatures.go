@@ -2,12 +2,30 @@ package schnorrgs
 
 /* This file implements Schnorr-multi signatures
    based on the schnorr.go file of this library.
+
+   Keys are combined MuSig style: with L the list of member keys, member i
+   gets the coefficient a_i = H(L, X_i) and the joint key is sum a_i X_i.
+   Simply adding the keys up lets a member pick its key as Y - sum X_j and
+   sign for the whole group with y alone; the coefficients stop that because
+   nobody can choose their key after seeing their coefficient.
+   The naive sum is still available through the Legacy functions so that
+   groups created before can keep verifying.
 */
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"github.com/dedis/kyber"
+	"golang.org/x/crypto/blake2b"
 	"io"
+	"sort"
+)
+
+// Prefixes of the hashes used for MuSig key coefficients.
+const (
+	musigListTag        = "schnorrgs-musig-keylist-v1"
+	musigCoefficientTag = "schnorrgs-musig-coefficient-v1"
 )
 
 // Represents a private commitment generated by a given server
@@ -43,18 +61,17 @@ func (sc SchnorrMSCommitment) MarshalTo(w io.Writer) {
 
 // Opposite of marshallbinary; recovers a private commitment
 // from a binary string. Requires a known suite to decode correctly.
-// Ignores excess binary (can be used on larger than necessary buffers).
-func (sc SchnorrMSCommitment) UnmarshalBinary(suite CryptoSuite, b []byte) error {
-	v := suite.Scalar()
-	T := suite.Point()
-
-	err := v.UnmarshalBinary(b[:sc.v.MarshalSize()])
+// The string has to be exactly v||T with T = g^v.
+func (sc *SchnorrMSCommitment) UnmarshalBinary(suite CryptoSuite, b []byte) error {
+	d := newStrictDecoder(suite, "commitment", b)
+	v := d.scalar()
+	T := d.point()
+	err := d.finish()
 	if err != nil {
 		return err
 	}
-	err = T.UnmarshalBinary(b[sc.v.MarshalSize():sc.T.MarshalSize()])
-	if err != nil {
-		return err
+	if !suite.Point().Mul(v, nil).Equal(T) {
+		return decodeError("commitment", ErrInconsistentSecret)
 	}
 	sc.T = T
 	sc.v = v
@@ -89,11 +106,10 @@ func (pc SchnorrMSPublicCommitment) MarshalTo(w io.Writer) {
 	pc.T.MarshalTo(w)
 }
 
-// Unmarshals the public commitment from a binary string.
+// Unmarshals the public commitment from a binary string, which has to
+// be exactly one canonical point.
 func (pc *SchnorrMSPublicCommitment) UnmarshalBinary(suite CryptoSuite, b []byte) error {
-	var T = suite.Point()
-	var sz = suite.Point().MarshalSize()
-	err := T.UnmarshalBinary(b[0:sz])
+	T, err := decodePoint(suite, "public commitment", b)
 	if err != nil {
 		return err
 	}
@@ -110,6 +126,31 @@ func SchnorrMSGenerateCommitment(suite CryptoSuite) SchnorrMSCommitment {
 	return SchnorrMSCommitment{v: v, T: T}
 }
 
+// Generates a commitment whose secret is a hedged nonce derived from the
+// server's key, the message and fresh randomness. Hashing in the key only
+// keeps a biased RNG from biasing v; it does not make up for a failed one.
+// The challenge depends on the other servers' commitments, so if the RNG
+// fails v is a fixed function of the key and message, and a co-signer who
+// sends a different commitment in a second session on the same message
+// gets two responses for one v and can solve for the key. For the same
+// reason there is no deterministic variant.
+func SchnorrMSGenerateCommitmentHedged(suite CryptoSuite,
+	kv SchnorrSecretKV, msg []byte) (SchnorrMSCommitment, error) {
+
+	err := checkSuite(suite, kv.suite)
+	if err != nil {
+		return SchnorrMSCommitment{}, err
+	}
+
+	v, err := schnorrHashNonce(suite, 'c', kv, msg, NonceHedged)
+	if err != nil {
+		return SchnorrMSCommitment{}, err
+	}
+	T := suite.Point().Mul(v, nil)
+
+	return SchnorrMSCommitment{v: v, T: T}, nil
+}
+
 // Generates an aggregate commitment from provided public commitments
 func SchnorrMSAggregateCommitment(suite CryptoSuite,
 	commitments []SchnorrMSPublicCommitment) SchnorrMSPublicCommitment {
@@ -123,10 +164,158 @@ func SchnorrMSAggregateCommitment(suite CryptoSuite,
 	return SchnorrMSPublicCommitment{T: aggCommitment}
 }
 
-// Generates a shared public key from provided public keys. The result is
-// "just another" public key and not in any way a special type.
+// Checks that all keys are in the named suite.
+func checkKeySuites(name string, pubkeys []SchnorrPublicKV) error {
+	for _, key := range pubkeys {
+		if key.suite != name {
+			return errors.New("Cannot combine a " +
+				key.suite + " key into a " + name + " group.")
+		}
+	}
+	return nil
+}
+
+// Checks that no key is listed twice. A repeated key would count twice in
+// the joint key but only ever answer once, and would let a group hide a
+// member behind another's key.
+func checkDistinctKeys(pubkeys []SchnorrPublicKV) error {
+	for i := range pubkeys {
+		for j := i + 1; j < len(pubkeys); j++ {
+			if pubkeys[i].pP.Equal(pubkeys[j].pP) {
+				return errors.New("Key " + pubkeys[i].pP.String() +
+					" is listed twice.")
+			}
+		}
+	}
+	return nil
+}
+
+// Hashes the list of keys L. The encodings are sorted first, so the
+// order in which members are listed does not matter.
+func musigHashKeyList(suite CryptoSuite, pubkeys []SchnorrPublicKV) ([]byte, error) {
+
+	var encoded [][]byte
+	for _, key := range pubkeys {
+		b, err := key.pP.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, b)
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, err
+	}
+	var count [8]byte
+	binary.BigEndian.PutUint64(count[:], uint64(len(encoded)))
+	h.Write([]byte(musigListTag))
+	h.Write(count[:])
+	for _, b := range encoded {
+		h.Write(b)
+	}
+	return h.Sum(nil), nil
+}
+
+// Computes the MuSig coefficient a = H(L, X) of key within the group
+// pubkeys. The key has to be a member of the group.
+func SchnorrMSComputeKeyCoefficient(suite CryptoSuite,
+	pubkeys []SchnorrPublicKV, key SchnorrPublicKV) (kyber.Scalar, error) {
+
+	found := false
+	for _, member := range pubkeys {
+		if member.pP.Equal(key.pP) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, errors.New("Key is not a member of the group.")
+	}
+
+	L, err := musigHashKeyList(suite, pubkeys)
+	if err != nil {
+		return nil, err
+	}
+	return musigCoefficient(suite, L, key)
+}
+
+// a = H(tag || H(L) || X)
+func musigCoefficient(suite CryptoSuite, L []byte,
+	key SchnorrPublicKV) (kyber.Scalar, error) {
+
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, err
+	}
+	h.Write([]byte(musigCoefficientTag))
+	h.Write(L)
+	_, err = key.pP.MarshalTo(h)
+	if err != nil {
+		return nil, err
+	}
+	return suite.Scalar().SetBytes(h.Sum(nil)), nil
+}
+
+// Generates a shared public key from provided public keys, sum a_i X_i.
+// The result is "just another" public key and not in any way a special
+// type. All keys must belong to the given suite.
 func SchnorrMSComputeSharedPublicKey(suite CryptoSuite,
-	pubkeys []SchnorrPublicKV) SchnorrPublicKV {
+	pubkeys []SchnorrPublicKV) (SchnorrPublicKV, error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+	err = checkKeySuites(name, pubkeys)
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+	err = checkDistinctKeys(pubkeys)
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+
+	L, err := musigHashKeyList(suite, pubkeys)
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+
+	sharedpubkey := suite.Point().Null()
+
+	for _, key := range pubkeys {
+		a, err := musigCoefficient(suite, L, key)
+		if err != nil {
+			return SchnorrPublicKV{}, err
+		}
+		sharedpubkey = suite.Point().Add(sharedpubkey,
+			suite.Point().Mul(a, key.pP))
+	}
+
+	return SchnorrPublicKV{suite: name, pP: sharedpubkey}, nil
+}
+
+// Generates the shared public key the way groups were originally made,
+// by adding up the keys. This is open to rogue key attacks and is only
+// here so that old groups still verify.
+func SchnorrMSComputeSharedPublicKeyLegacy(suite CryptoSuite,
+	pubkeys []SchnorrPublicKV) (SchnorrPublicKV, error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+	err = checkKeySuites(name, pubkeys)
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+	err = checkDistinctKeys(pubkeys)
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
 
 	sharedpubkey := suite.Point().Null()
 
@@ -134,27 +323,61 @@ func SchnorrMSComputeSharedPublicKey(suite CryptoSuite,
 		sharedpubkey = suite.Point().Add(sharedpubkey, key.pP)
 	}
 
-	return SchnorrPublicKV{suite: "BlakeSHA256Ed25519", pP: sharedpubkey}
+	return SchnorrPublicKV{suite: name, pP: sharedpubkey}, nil
 }
 
-// Computes the collective challenge given an aggregate commitment and
-// the message we wish to challenge with.
-func SchnorrMSComputeCollectiveChallenge(suite CryptoSuite,
-	aggcommit SchnorrMSPublicCommitment, msg []byte) (kyber.Scalar, error) {
-	return SchnorrHashPointsMsgToScalar(suite, aggcommit.T, msg)
+// Computes the collective challenge given the joint key, an aggregate
+// commitment and the message we wish to challenge with, in the given
+// context. The result is the challenge of an ordinary signature under
+// the joint key.
+func SchnorrMSComputeCollectiveChallenge(suite CryptoSuite, context []byte,
+	jointkey SchnorrPublicKV, aggcommit SchnorrMSPublicCommitment,
+	msg []byte) (kyber.Scalar, error) {
+	return SchnorrHashChallenge(suite, context, jointkey, aggcommit.T, msg)
 }
 
 // Computes the server "response" component using its private key,
-// private commitment and collective challenge c
+// private commitment and collective challenge c, i.e. v - c a x where a
+// is the server's MuSig coefficient within the group pubkeys.
 func SchnorrMSComputeResponse(suite CryptoSuite,
+	c kyber.Scalar, pubkeys []SchnorrPublicKV, privkey SchnorrSecretKV,
+	privcommit SchnorrMSCommitment) (kyber.Scalar, error) {
+
+	err := checkSigningKey(suite, privkey)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := SchnorrMSComputeKeyCoefficient(suite, pubkeys,
+		privkey.GetPublicKeyset())
+	if err != nil {
+		return nil, err
+	}
+
+	r := suite.Scalar().Zero()
+	r = r.Mul(c, a)
+	r = r.Mul(r, privkey.s)
+	r = r.Sub(privcommit.v, r)
+
+	return r, nil
+}
+
+// Computes the server response for a group whose joint key was made with
+// SchnorrMSComputeSharedPublicKeyLegacy, i.e. v - c x.
+func SchnorrMSComputeResponseLegacy(suite CryptoSuite,
 	c kyber.Scalar, privkey SchnorrSecretKV,
-	privcommit SchnorrMSCommitment) kyber.Scalar {
+	privcommit SchnorrMSCommitment) (kyber.Scalar, error) {
+
+	err := checkSigningKey(suite, privkey)
+	if err != nil {
+		return nil, err
+	}
 
 	r := suite.Scalar().Zero()
 	r = r.Mul(c, privkey.s)
 	r = r.Sub(privcommit.v, r)
 
-	return r
+	return r, nil
 }
 
 // Computes the combined response from all server responses. This can be
@@ -174,7 +397,12 @@ func SchnorrMSComputeCombinedResponse(suite CryptoSuite,
 // Helper function to build a signature type from the combined responses and
 // combined challenge.
 func SchnorrMSCreateSignature(suite CryptoSuite, c kyber.Scalar,
-	r kyber.Scalar) SchnorrSignature {
+	r kyber.Scalar) (SchnorrSignature, error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
 
-	return SchnorrSignature{S: r, E: c}
+	return SchnorrSignature{S: r, E: c, suite: name}, nil
 }
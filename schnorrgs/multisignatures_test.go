@@ -39,8 +39,11 @@ func TestMultisignature2ServerScenario(t *testing.T) {
 	pk2 := kv2.GetPublicKeyset()
 
 	// CLIENT: compute combined public key:
-	sharedpubkey := SchnorrMSComputeSharedPublicKey(suite,
+	sharedpubkey, err := SchnorrMSComputeSharedPublicKey(suite,
 		[]SchnorrPublicKV{pk1, pk2})
+	if err != nil {
+		t.Error(err.Error())
+	}
 
 	// SERVERS: generate commitments and send to client:
 	commit1 := SchnorrMSGenerateCommitment(suite)
@@ -55,20 +58,31 @@ func TestMultisignature2ServerScenario(t *testing.T) {
 	// CLIENT OR SERVER: compute collective challenge based on
 	// message:
 	collectivechallenge, err := SchnorrMSComputeCollectiveChallenge(suite,
-		combined_pcommit, randomdata)
+		nil, sharedpubkey, combined_pcommit, randomdata)
 	if err != nil {
 		t.Error("Collective challenge computation failed.")
 		t.Error(err.Error())
 	}
 
 	// SERVERS: compute a response per server:
-	r1 := SchnorrMSComputeResponse(suite, collectivechallenge, kv1, commit1)
-	r2 := SchnorrMSComputeResponse(suite, collectivechallenge, kv2, commit2)
+	r1, err := SchnorrMSComputeResponse(suite, collectivechallenge,
+		[]SchnorrPublicKV{pk1, pk2}, kv1, commit1)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	r2, err := SchnorrMSComputeResponse(suite, collectivechallenge,
+		[]SchnorrPublicKV{pk1, pk2}, kv2, commit2)
+	if err != nil {
+		t.Error(err.Error())
+	}
 
 	// CLIENT: combine the responses:
 	r := SchnorrMSComputeCombinedResponse(suite, []kyber.Scalar{r1, r2})
 	// CLIENT: make a signature:
-	sig := SchnorrMSCreateSignature(suite, collectivechallenge, r)
+	sig, err := SchnorrMSCreateSignature(suite, collectivechallenge, r)
+	if err != nil {
+		t.Error(err.Error())
+	}
 
 	verified, err := SchnorrVerify(suite,
 		sharedpubkey,
@@ -110,7 +124,10 @@ func testMultisignatureNServerScenario(t *testing.T, n int) {
 	}
 
 	// CLIENT: compute combined public key:
-	sharedpubkey := SchnorrMSComputeSharedPublicKey(suite, publickeys)
+	sharedpubkey, err := SchnorrMSComputeSharedPublicKey(suite, publickeys)
+	if err != nil {
+		t.Error(err.Error())
+	}
 
 	// SERVERS: generate commitments and send to client:
 	var commits []SchnorrMSCommitment
@@ -127,7 +144,7 @@ func testMultisignatureNServerScenario(t *testing.T, n int) {
 	// CLIENT OR SERVER: compute collective challenge based on
 	// message:
 	collectivechallenge, err := SchnorrMSComputeCollectiveChallenge(suite,
-		combined_pcommit, randomdata)
+		nil, sharedpubkey, combined_pcommit, randomdata)
 	if err != nil {
 		t.Error("Collective challenge computation failed.")
 		t.Error(err.Error())
@@ -136,14 +153,20 @@ func testMultisignatureNServerScenario(t *testing.T, n int) {
 	// SERVERS: compute a response per server:
 	var responses []kyber.Scalar
 	for i := 0; i < n; i++ {
-		resp := SchnorrMSComputeResponse(suite, collectivechallenge,
-			privatekeys[i], commits[i])
+		resp, err := SchnorrMSComputeResponse(suite, collectivechallenge,
+			publickeys, privatekeys[i], commits[i])
+		if err != nil {
+			t.Error(err.Error())
+		}
 		responses = append(responses, resp)
 	}
 	// CLIENT: combine the responses:
 	r := SchnorrMSComputeCombinedResponse(suite, responses)
 	// CLIENT: make a signature:
-	sig := SchnorrMSCreateSignature(suite, collectivechallenge, r)
+	sig, err := SchnorrMSCreateSignature(suite, collectivechallenge, r)
+	if err != nil {
+		t.Error(err.Error())
+	}
 
 	verified, err := SchnorrVerify(suite,
 		sharedpubkey,
@@ -165,3 +188,169 @@ func TestMultisignature5ServerScenario(t *testing.T) {
 func TestMultisignature100ServerScenario(t *testing.T) {
 	testMultisignatureNServerScenario(t, 100)
 }
+
+// The old naive aggregation must keep working for existing groups.
+func TestMultisignatureLegacyAggregation(t *testing.T) {
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	message := []byte("This is a test")
+
+	var privatekeys []SchnorrSecretKV
+	var publickeys []SchnorrPublicKV
+	for i := 0; i < 3; i++ {
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		privatekeys = append(privatekeys, kv)
+		publickeys = append(publickeys, kv.GetPublicKeyset())
+	}
+
+	legacykey, err := SchnorrMSComputeSharedPublicKeyLegacy(suite, publickeys)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	musigkey, err := SchnorrMSComputeSharedPublicKey(suite, publickeys)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if legacykey.Export() == musigkey.Export() {
+		t.Error("MuSig and legacy aggregation gave the same key")
+	}
+
+	var commits []SchnorrMSCommitment
+	var pcommits []SchnorrMSPublicCommitment
+	for i := 0; i < 3; i++ {
+		commit := SchnorrMSGenerateCommitment(suite)
+		commits = append(commits, commit)
+		pcommits = append(pcommits, commit.GetPublicCommitment())
+	}
+	agg := SchnorrMSAggregateCommitment(suite, pcommits)
+	c, err := SchnorrMSComputeCollectiveChallenge(suite, nil, legacykey,
+		agg, message)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var responses []kyber.Scalar
+	for i := 0; i < 3; i++ {
+		r, err := SchnorrMSComputeResponseLegacy(suite, c, privatekeys[i],
+			commits[i])
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		responses = append(responses, r)
+	}
+	sig, err := SchnorrMSCreateSignature(suite, c,
+		SchnorrMSComputeCombinedResponse(suite, responses))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, err := SchnorrVerify(suite, legacykey, message, sig)
+	if err != nil || !v {
+		t.Error("Legacy multisignature did not verify")
+	}
+}
+
+// A member who picks its key as Y - X_honest controls a naively
+// aggregated group on its own, but not a MuSig one.
+func TestMultisignatureRogueKey(t *testing.T) {
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+	message := []byte("transfer everything to mallory")
+
+	honest, err := SchnorrGenerateKeypair(suite)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	target, err := SchnorrGenerateKeypair(suite)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	rogue := SchnorrPublicKV{suite: target.suite,
+		pP: suite.Point().Sub(target.pP, honest.pP)}
+	group := []SchnorrPublicKV{honest.GetPublicKeyset(), rogue}
+
+	sig, err := SchnorrSign(suite, target, message)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	legacykey, err := SchnorrMSComputeSharedPublicKeyLegacy(suite, group)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	v, _ := SchnorrVerify(suite, legacykey, message, sig)
+	if !v {
+		t.Error("Rogue key attack on legacy aggregation did not work")
+	}
+
+	musigkey, err := SchnorrMSComputeSharedPublicKey(suite, group)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	v, _ = SchnorrVerify(suite, musigkey, message, sig)
+	if v {
+		t.Error("Rogue key attack on MuSig aggregation worked")
+	}
+}
+
+func TestMultisignatureKeyOrder(t *testing.T) {
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+
+	var publickeys []SchnorrPublicKV
+	for i := 0; i < 4; i++ {
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		publickeys = append(publickeys, kv.GetPublicKeyset())
+	}
+	reversed := []SchnorrPublicKV{publickeys[3], publickeys[2],
+		publickeys[1], publickeys[0]}
+
+	k1, err := SchnorrMSComputeSharedPublicKey(suite, publickeys)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	k2, err := SchnorrMSComputeSharedPublicKey(suite, reversed)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if k1.Export() != k2.Export() {
+		t.Error("Joint key depends on the order of the members")
+	}
+
+	outsider, _ := SchnorrGenerateKeypair(suite)
+	_, err = SchnorrMSComputeKeyCoefficient(suite, publickeys,
+		outsider.GetPublicKeyset())
+	if err == nil {
+		t.Error("Computed a coefficient for a key outside the group")
+	}
+}
+
+func TestMultisignatureDuplicateKeys(t *testing.T) {
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+
+	a, _ := SchnorrGenerateKeypair(suite)
+	b, _ := SchnorrGenerateKeypair(suite)
+	keys := []SchnorrPublicKV{a.GetPublicKeyset(), b.GetPublicKeyset(),
+		a.GetPublicKeyset()}
+
+	_, err := SchnorrMSComputeSharedPublicKey(suite, keys)
+	if err == nil {
+		t.Error("Joint key computed with a key listed twice")
+	}
+	_, err = SchnorrMSComputeSharedPublicKeyLegacy(suite, keys)
+	if err == nil {
+		t.Error("Legacy joint key computed with a key listed twice")
+	}
+	_, err = SchnorrMSComputeSharedPublicKey(suite, keys[:2])
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
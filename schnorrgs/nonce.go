@@ -0,0 +1,95 @@
+package schnorrgs
+
+/* This file derives signing nonces. A Schnorr signature leaks the private
+   key as soon as two signatures share a nonce, or the nonce is biased, so
+   taking it straight from the RNG means a bad RNG on a server gives the
+   key away.
+
+   Deterministic nonces follow RFC 6979 and RFC 8032 in spirit: k is a hash
+   of the secret key and the message, so the same message always gives
+   the same signature and different messages give unrelated nonces.
+   Hedged nonces also mix in fresh randomness. They are as safe as the
+   deterministic ones if the RNG fails, and the randomness protects against
+   fault attacks that rely on signing the same message twice.
+*/
+
+import (
+	"errors"
+	"github.com/dedis/kyber"
+	"golang.org/x/crypto/blake2b"
+)
+
+// How a signing nonce is chosen.
+type SchnorrNonceMode int
+
+const (
+	// k is picked from the suite's random stream.
+	NonceRandom SchnorrNonceMode = iota
+	// k = H(x || M).
+	NonceDeterministic
+	// k = H(x || Z || M), Z fresh randomness.
+	NonceHedged
+)
+
+// Prefix of the nonce hash, keeping it apart from every other hash
+// of the secret key.
+const nonceTag = "schnorrgs-nonce-v1"
+
+// Bytes of fresh randomness mixed into a hedged nonce.
+const nonceRandomSize = 32
+
+// Parses the name of a nonce mode as used on the command line.
+func ParseNonceMode(name string) (SchnorrNonceMode, error) {
+	switch name {
+	case "random":
+		return NonceRandom, nil
+	case "deterministic":
+		return NonceDeterministic, nil
+	case "hedged":
+		return NonceHedged, nil
+	}
+	return NonceRandom, errors.New("Unknown nonce mode " + name)
+}
+
+// Computes Blake2b(tag || purpose || x || Z || M) reduced to a scalar.
+// Z is all zeroes unless the mode is hedged. x and Z are fixed length, so
+// the message can follow without a length prefix.
+func schnorrHashNonce(suite CryptoSuite, purpose byte, kv SchnorrSecretKV,
+	msg []byte, mode SchnorrNonceMode) (kyber.Scalar, error) {
+
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := kv.s.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	z := make([]byte, nonceRandomSize)
+	if mode == NonceHedged {
+		suite.RandomStream().XORKeyStream(z, z)
+	}
+
+	h.Write([]byte(nonceTag))
+	h.Write([]byte{purpose, byte(mode)})
+	h.Write(x)
+	h.Write(z)
+	h.Write(msg)
+
+	return suite.Scalar().SetBytes(h.Sum(nil)), nil
+}
+
+// Picks the nonce k for signing msg with kv.
+func schnorrDeriveNonce(suite CryptoSuite, kv SchnorrSecretKV, msg []byte,
+	mode SchnorrNonceMode) (kyber.Scalar, error) {
+
+	switch mode {
+	case NonceRandom:
+		return suite.Scalar().Pick(suite.RandomStream()), nil
+	case NonceDeterministic, NonceHedged:
+		return schnorrHashNonce(suite, 's', kv, msg, mode)
+	}
+	return nil, errors.New("Unknown nonce mode.")
+}
@@ -0,0 +1,162 @@
+package schnorrgs
+
+import (
+	"encoding/hex"
+	"github.com/dedis/kyber"
+	"golang.org/x/crypto/blake2b"
+	"testing"
+)
+
+// Builds a fixed keypair from a seed string, for known answer tests.
+func testKeypairFromSeed(t testing.TB, suitename string,
+	seed string) SchnorrSecretKV {
+
+	suite, err := GetSuite(suitename)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	h := blake2b.Sum512([]byte(seed))
+	x := suite.Scalar().SetBytes(h[:])
+	return SchnorrSecretKV{suite: suitename, s: x, pP: suite.Point().Mul(x, nil)}
+}
+
+// Deterministic signatures on "This is a test" under the key from the
+// seed "schnorrgs test key", encoded as (s, e).
+var deterministicVectors = map[string]string{
+	"BlakeSHA256Ed25519": "da2ace081e2ec3442d58198addb6b12c9a071001d4bc74b72495646c05eeca07" +
+		"4fa2f70568a37d2dc305df340a3e4e765ca4cf4d8d14b49e3a57abc72d0f2a0d",
+	"BlakeSHA256P256": "5695693b73e6b6b38affa94b8dbb85510a9ce402d2fcae3d6d77aed9a55f49a4" +
+		"a0d1b3d983fde3cffbe9fe96a78665f175c9fda9786aea95a5dad7d8d341ca9f",
+}
+
+func TestDeterministicNonceKnownAnswers(t *testing.T) {
+
+	message := []byte("This is a test")
+
+	for name, expected := range deterministicVectors {
+		suite, _ := GetSuite(name)
+		kv := testKeypairFromSeed(t, name, "schnorrgs test key")
+
+		sig, err := SchnorrSignBinaryWithMode(suite, kv, message,
+			NonceDeterministic)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if hex.EncodeToString(sig) != expected {
+			t.Errorf("%s: deterministic signature %x, expected %s",
+				name, sig, expected)
+		}
+	}
+}
+
+func TestNonceModes(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	kv, err := SchnorrGenerateKeypair(suite)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	pk := kv.GetPublicKeyset()
+	message := []byte("This is a test")
+	other := []byte("This is another test")
+
+	for _, mode := range []SchnorrNonceMode{NonceRandom, NonceDeterministic,
+		NonceHedged} {
+
+		sig1, err := SchnorrSignWithMode(suite, kv, message, mode)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sig2, err := SchnorrSignWithMode(suite, kv, message, mode)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sig3, err := SchnorrSignWithMode(suite, kv, other, mode)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		v, err := SchnorrVerify(suite, pk, message, sig1)
+		if err != nil || !v {
+			t.Errorf("Signature in mode %d did not verify", mode)
+		}
+
+		if sig1.R.Equal(sig3.R) {
+			t.Errorf("Mode %d reused a nonce for different messages", mode)
+		}
+		if mode == NonceDeterministic && !sig1.R.Equal(sig2.R) {
+			t.Error("Deterministic signing is not reproducible")
+		}
+		if mode != NonceDeterministic && sig1.R.Equal(sig2.R) {
+			t.Errorf("Mode %d repeated a nonce", mode)
+		}
+	}
+
+	_, err = ParseNonceMode("sometimes")
+	if err == nil {
+		t.Error("Parsed an unknown nonce mode")
+	}
+}
+
+func TestHedgedCommitments(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+	message := []byte("This is a test")
+
+	var keys []SchnorrSecretKV
+	var pubkeys []SchnorrPublicKV
+	var commits []SchnorrMSCommitment
+	var pcommits []SchnorrMSPublicCommitment
+
+	for i := 0; i < 3; i++ {
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		commit, err := SchnorrMSGenerateCommitmentHedged(suite, kv, message)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		again, err := SchnorrMSGenerateCommitmentHedged(suite, kv, message)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if commit.T.Equal(again.T) {
+			t.Error("Hedged commitment repeated itself")
+		}
+		keys = append(keys, kv)
+		pubkeys = append(pubkeys, kv.GetPublicKeyset())
+		commits = append(commits, commit)
+		pcommits = append(pcommits, commit.GetPublicCommitment())
+	}
+
+	shared, err := SchnorrMSComputeSharedPublicKey(suite, pubkeys)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	agg := SchnorrMSAggregateCommitment(suite, pcommits)
+	c, err := SchnorrMSComputeCollectiveChallenge(suite, nil, shared, agg,
+		message)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var responses []kyber.Scalar
+	for i := range keys {
+		r, err := SchnorrMSComputeResponse(suite, c, pubkeys, keys[i], commits[i])
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		responses = append(responses, r)
+	}
+	sig, err := SchnorrMSCreateSignature(suite, c,
+		SchnorrMSComputeCombinedResponse(suite, responses))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, err := SchnorrVerify(suite, shared, message, sig)
+	if err != nil || !v {
+		t.Error("Multisignature with hedged commitments did not verify")
+	}
+}
@@ -11,23 +11,21 @@ given we are building a server/client architecture. In this case:
    can sign messages
    given agreed information witht he user/client by answering challenges.
 
-Of particular concern in my implementation is the Z-generation. The paper
-describes F(info) as a public key with no known private key; in order to
-generate a point on the curve we have effectively taken g^{F(info)} which
-means F(info) could act as a private key in a schnorr signature scheme. I am
-not sure if this breaks the security of the system. However, given some info we
-need to deterministically find a curve point, which we cannot do with the
-underlying library at present.
+The paper describes F(info) as a public key with no known private key. We
+originally took g^{F(info)}, which meant anyone could compute the discrete
+log of Z. Z is now produced by HashToPoint (see hashtocurve.go), which maps
+info directly onto the curve so that nobody knows its discrete log.
 */
 
 import (
-	//"bytes"
-	"crypto/rand"
+	"bytes"
 	"github.com/dedis/kyber"
-	"golang.org/x/crypto/blake2b"
 	"io"
 )
 
+// Domain used when hashing the agreed information onto the curve.
+const partialBlindZDomain = "schnorrgs/partialblind/Z"
+
 // Represents he prviate parameters
 // generated in Fig 1. "signer"
 // You'll also want to use Schnorr.go to generate
@@ -50,34 +48,53 @@ func (b WISchnorrBlindPrivateParams) MarshalTo(w io.Writer) {
 	b.B.MarshalTo(w)
 }
 
-func (b WISchnorrBlindPrivateParams) UnmarshalBinary(raw []byte) {
-	b.U.UnmarshalBinary(raw[:b.U.MarshalSize()])
-	b.S.UnmarshalBinary(raw[b.U.MarshalSize():b.S.MarshalSize()])
-	b.D.UnmarshalBinary(raw[b.S.MarshalSize():b.D.MarshalSize()])
-	b.Z.UnmarshalBinary(raw[b.D.MarshalSize():b.Z.MarshalSize()])
-	b.A.UnmarshalBinary(raw[b.Z.MarshalSize():b.A.MarshalSize()])
-	b.B.UnmarshalBinary(raw[b.A.MarshalSize():b.B.MarshalSize()])
+// Encodes the private parameters as u||s||d||z||a||b, for a signer that
+// keeps them across connections.
+func (b WISchnorrBlindPrivateParams) MarshalBinary() ([]byte, error) {
+	return blindMarshal(b.U, b.S, b.D, b.Z, b.A, b.B)
+}
+
+// Decodes private parameters encoded by MarshalBinary, checking that
+// a = g^u and b = g^s z^d.
+func (b *WISchnorrBlindPrivateParams) UnmarshalBinary(suite CryptoSuite,
+	raw []byte) error {
+
+	d := newStrictDecoder(suite, "blind private parameters", raw)
+	U, S, D := d.scalar(), d.scalar(), d.scalar()
+	Z, A, B := d.point(), d.point(), d.point()
+	err := d.finish()
+	if err != nil {
+		return err
+	}
+	gs := suite.Point().Mul(S, nil)
+	zd := suite.Point().Mul(D, Z)
+	if !suite.Point().Mul(U, nil).Equal(A) ||
+		!suite.Point().Add(gs, zd).Equal(B) {
+		return decodeError("blind private parameters", ErrInconsistentSecret)
+	}
+	*b = WISchnorrBlindPrivateParams{U, S, D, Z, A, B}
+	return nil
+}
+
+// Encodes points and scalars one after the other.
+func blindMarshal(items ...kyber.Marshaling) ([]byte, error) {
+	var b bytes.Buffer
+	for _, item := range items {
+		_, err := item.MarshalTo(&b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
 }
 
 /* GenerateZ takes some random agreed information and creates
    Z the "public-only" key that is witness-independent as per
-   the paper. We've probably broken that slightly in this implementation
-   because I could not pick a point without generating it
-   via a Secret, instead of directly via a Point - that is, even as a
-   32-byte string, we cannot decode on C25519 (and this wouldn't work
-   for CryptoSuites anyway).
-
-   However, it demonstrates the idea.
+   the paper. Z is hashed directly onto the curve, so its discrete log
+   is unknown to the signer and the user alike.
 */
 func GenerateZ(suite CryptoSuite, info []byte) (kyber.Point, error) {
-
-	hasher, _ := blake2b.New512(nil)
-	hasher.Write(info)
-
-	zfactor := suite.Scalar().SetBytes(hasher.Sum(nil))
-	Z := suite.Point().Mul(zfactor, nil)
-
-	return Z, nil
+	return HashToPoint(suite, partialBlindZDomain, info)
 }
 
 // public parameters that can be transmitted to
@@ -95,9 +112,23 @@ func (pp WISchnorrPublicParams) MarshalTo(w io.Writer) {
 	pp.B.MarshalTo(w)
 }
 
-func (pp WISchnorrPublicParams) UnmarshalBinary(b []byte) {
-	pp.A.UnmarshalBinary(b[:pp.A.MarshalSize()])
-	pp.B.UnmarshalBinary(b[pp.A.MarshalSize():pp.B.MarshalSize()])
+// Encodes the public parameters as a||b.
+func (pp WISchnorrPublicParams) MarshalBinary() ([]byte, error) {
+	return blindMarshal(pp.A, pp.B)
+}
+
+// Decodes public parameters encoded by MarshalBinary.
+func (pp *WISchnorrPublicParams) UnmarshalBinary(suite CryptoSuite,
+	b []byte) error {
+
+	d := newStrictDecoder(suite, "blind public parameters", b)
+	A, B := d.point(), d.point()
+	err := d.finish()
+	if err != nil {
+		return err
+	}
+	*pp = WISchnorrPublicParams{A, B}
+	return nil
 }
 
 /* The challenge message is the structure the user
@@ -113,8 +144,33 @@ func (cm WISchnorrChallengeMessage) MarshalTo(w io.Writer) {
 	cm.E.MarshalTo(w)
 }
 
-func (cm WISchnorrChallengeMessage) UnmarshalBinary(b []byte) {
+// Encodes the challenge message, which is just e.
+func (cm WISchnorrChallengeMessage) MarshalBinary() ([]byte, error) {
+	return blindMarshal(cm.E)
+}
 
+// Decodes a challenge message encoded by MarshalBinary.
+func (cm *WISchnorrChallengeMessage) UnmarshalBinary(suite CryptoSuite,
+	b []byte) error {
+
+	E, err := decodeScalar(suite, "blind challenge", b)
+	if err != nil {
+		return err
+	}
+	cm.E = E
+	return nil
+}
+
+/* Draws a scalar uniformly from the whole group: 64 bytes of the suite's
+   stream reduced modulo the group order, so that a seeded suite reproduces
+   it for the test vectors. Shorter draws are not safe here. With u, s and
+   d much smaller than the group order, r = u - cx gives the key away to a
+   few signing sessions, and short t's let the signer tell which session
+   a signature came from. */
+func blindRandomScalar(suite CryptoSuite) kyber.Scalar {
+	b := make([]byte, 64)
+	suite.RandomStream().XORKeyStream(b, b)
+	return suite.Scalar().SetBytes(b)
 }
 
 // Generates all of the private parameters aside
@@ -122,36 +178,14 @@ func (cm WISchnorrChallengeMessage) UnmarshalBinary(b []byte) {
 // separately.
 func NewPrivateParams(suite CryptoSuite, info []byte) (WISchnorrBlindPrivateParams, error) {
 
-	r1 := make([]byte, 16)
-	r2 := make([]byte, 16)
-	r3 := make([]byte, 16)
-
-	v := make([]byte, 16)
-	_, err := rand.Read(r1)
-	if err != nil {
-		return WISchnorrBlindPrivateParams{}, err
-	}
-	_, err = rand.Read(r2)
-	if err != nil {
-		return WISchnorrBlindPrivateParams{}, err
-	}
-	_, err = rand.Read(r3)
-	if err != nil {
-		return WISchnorrBlindPrivateParams{}, err
-	}
-	_, err = rand.Read(v)
-	if err != nil {
-		return WISchnorrBlindPrivateParams{}, err
-	}
-
 	z, err := GenerateZ(suite, info)
 	if err != nil {
 		return WISchnorrBlindPrivateParams{}, err
 	}
 
-	u := suite.Scalar().SetBytes(r1)
-	s := suite.Scalar().SetBytes(r2)
-	d := suite.Scalar().SetBytes(r3)
+	u := blindRandomScalar(suite)
+	s := blindRandomScalar(suite)
+	d := blindRandomScalar(suite)
 
 	a := suite.Point().Mul(u, nil)  // g^u
 	b1 := suite.Point().Mul(s, nil) // g^s
@@ -167,6 +201,21 @@ func (this *WISchnorrBlindPrivateParams) DerivePubParams() WISchnorrPublicParams
 	return WISchnorrPublicParams{this.A, this.B}
 }
 
+/* Computes the challenge H(tag || Y || alpha || beta || z || msg) of the
+   paper, tagged and key-prefixed like every other challenge (see
+   domain.go). The agreed info is bound through z, which is derived from
+   it, so it needs no context string of its own. */
+func blindHashChallenge(suite CryptoSuite, pk SchnorrPublicKV, alpha kyber.Point,
+	beta kyber.Point, z kyber.Point, msg []byte) (kyber.Scalar, error) {
+
+	err := checkSuite(suite, pk.suite)
+	if err != nil {
+		return nil, err
+	}
+	return schnorrHashChallenge(suite, blindTag, nil, pk.pP,
+		[]kyber.Point{alpha, beta, z}, msg)
+}
+
 /* The client parameter list is the structure
    packing all those elements that the client owns
    but does not transmit. */
@@ -178,6 +227,27 @@ type WISchnorrClientParamersList struct {
 	Z  kyber.Point
 }
 
+// Encodes the client parameters as t1||t2||t3||t4||z, for a user that
+// finishes the signature in another process.
+func (cp WISchnorrClientParamersList) MarshalBinary() ([]byte, error) {
+	return blindMarshal(cp.T1, cp.T2, cp.T3, cp.T4, cp.Z)
+}
+
+// Decodes client parameters encoded by MarshalBinary.
+func (cp *WISchnorrClientParamersList) UnmarshalBinary(suite CryptoSuite,
+	b []byte) error {
+
+	d := newStrictDecoder(suite, "blind client parameters", b)
+	T1, T2, T3, T4 := d.scalar(), d.scalar(), d.scalar(), d.scalar()
+	Z := d.point()
+	err := d.finish()
+	if err != nil {
+		return err
+	}
+	*cp = WISchnorrClientParamersList{T1, T2, T3, T4, Z}
+	return nil
+}
+
 /* This function is responsible for producing the challenge message E to send
    back to the signer. */
 func ClientGenerateChallenge(suite CryptoSuite,
@@ -185,39 +255,16 @@ func ClientGenerateChallenge(suite CryptoSuite,
 	info []byte, msg []byte) (WISchnorrChallengeMessage,
 	WISchnorrClientParamersList, error) {
 
-	r1 := make([]byte, 16)
-	r2 := make([]byte, 16)
-	r3 := make([]byte, 16)
-	r4 := make([]byte, 16)
-	_, err := rand.Read(r1)
-	if err != nil {
-		return WISchnorrChallengeMessage{}, WISchnorrClientParamersList{}, err
-	}
-	_, err = rand.Read(r2)
-	if err != nil {
-		return WISchnorrChallengeMessage{}, WISchnorrClientParamersList{}, err
-	}
-	_, err = rand.Read(r3)
-	if err != nil {
-		return WISchnorrChallengeMessage{}, WISchnorrClientParamersList{}, err
-	}
-	_, err = rand.Read(r4)
-	if err != nil {
-		return WISchnorrChallengeMessage{}, WISchnorrClientParamersList{}, err
-	}
-
-	t1 := suite.Scalar().SetBytes(r1)
-	t2 := suite.Scalar().SetBytes(r2)
-	t3 := suite.Scalar().SetBytes(r3)
-	t4 := suite.Scalar().SetBytes(r4)
+	t1 := blindRandomScalar(suite)
+	t2 := blindRandomScalar(suite)
+	t3 := blindRandomScalar(suite)
+	t4 := blindRandomScalar(suite)
 
 	z, err := GenerateZ(suite, info)
 	if err != nil {
 		return WISchnorrChallengeMessage{}, WISchnorrClientParamersList{}, err
 	}
 
-	zraw, _ := z.MarshalBinary()
-
 	packedParameters := WISchnorrClientParamersList{t1, t2, t3, t4, z}
 
 	// There might be a better way to lay out this
@@ -238,21 +285,10 @@ func ClientGenerateChallenge(suite CryptoSuite,
 	beta := suite.Point()
 	beta.Mul(t4, z).Add(beta, beta1).Add(beta, publicParameters.B)
 
-	var combinedmsg []byte
-
-	bAlpha, _ := alpha.MarshalBinary()
-	bBeta, _ := beta.MarshalBinary()
-
-	//zraw, _ := publicParameters.Z.MarshalBinary()
-
-	combinedmsg = append(combinedmsg, bAlpha...)
-	combinedmsg = append(combinedmsg, bBeta...)
-	combinedmsg = append(combinedmsg, zraw...)
-	combinedmsg = append(combinedmsg, msg...)
-
-	hasher, _ := blake2b.New512(nil)
-	hasher.Write(combinedmsg)
-	epsilon := suite.Scalar().SetBytes(hasher.Sum(nil))
+	epsilon, err := blindHashChallenge(suite, pk, alpha, beta, z, msg)
+	if err != nil {
+		return WISchnorrChallengeMessage{}, WISchnorrClientParamersList{}, err
+	}
 
 	e := suite.Scalar()
 	e.Sub(epsilon, t2).Sub(e, t4)
@@ -268,9 +304,34 @@ type WISchnorrResponseMessage struct {
 	D kyber.Scalar
 }
 
+// Encodes the response message as r||c||s||d.
+func (rm WISchnorrResponseMessage) MarshalBinary() ([]byte, error) {
+	return blindMarshal(rm.R, rm.C, rm.S, rm.D)
+}
+
+// Decodes a response message encoded by MarshalBinary.
+func (rm *WISchnorrResponseMessage) UnmarshalBinary(suite CryptoSuite,
+	b []byte) error {
+
+	d := newStrictDecoder(suite, "blind response", b)
+	R, C, S, D := d.scalar(), d.scalar(), d.scalar(), d.scalar()
+	err := d.finish()
+	if err != nil {
+		return err
+	}
+	*rm = WISchnorrResponseMessage{R, C, S, D}
+	return nil
+}
+
 /* The servergenerateresponse function is fairly self explanatory - this
-   function provides an answer to the challenge message provided by the user.*/
-func ServerGenerateResponse(suite CryptoSuite, challenge WISchnorrChallengeMessage, privateParameters WISchnorrBlindPrivateParams, privKey SchnorrSecretKV) WISchnorrResponseMessage {
+   function provides an answer to the challenge message provided by the user.
+   A key that has expired or is in another suite answers nothing. */
+func ServerGenerateResponse(suite CryptoSuite, challenge WISchnorrChallengeMessage, privateParameters WISchnorrBlindPrivateParams, privKey SchnorrSecretKV) (WISchnorrResponseMessage, error) {
+
+	err := checkSigningKey(suite, privKey)
+	if err != nil {
+		return WISchnorrResponseMessage{}, err
+	}
 
 	c := suite.Scalar()
 	c.Sub(challenge.E, privateParameters.D)
@@ -278,7 +339,7 @@ func ServerGenerateResponse(suite CryptoSuite, challenge WISchnorrChallengeMessa
 	r.Mul(c, privKey.s).Sub(privateParameters.U, r)
 
 	return WISchnorrResponseMessage{r, c, privateParameters.S,
-		privateParameters.D}
+		privateParameters.D}, nil
 }
 
 /* This structure implements the elements of the blind signature as described
@@ -291,6 +352,25 @@ type WIBlindSignature struct {
 	D kyber.Scalar
 }
 
+// Encodes a blind signature as rho||omega||sigma||delta.
+func (sig WIBlindSignature) MarshalBinary() ([]byte, error) {
+	return blindMarshal(sig.P, sig.W, sig.S, sig.D)
+}
+
+// Decodes a blind signature encoded by MarshalBinary.
+func (sig *WIBlindSignature) UnmarshalBinary(suite CryptoSuite,
+	b []byte) error {
+
+	d := newStrictDecoder(suite, "blind signature", b)
+	P, W, S, D := d.scalar(), d.scalar(), d.scalar(), d.scalar()
+	err := d.finish()
+	if err != nil {
+		return err
+	}
+	*sig = WIBlindSignature{P, W, S, D}
+	return nil
+}
+
 /* This is the function that given the client's challenge and response from the
    server is able to compute the final blind signature. This is done on the
    user side (blindly to the signer). */
@@ -314,7 +394,6 @@ func ClientSignBlindly(suite CryptoSuite, clientParameters WISchnorrClientParame
 	gpyw := suite.Point()
 
 	gpyw.Add(gp, yw)
-	bGpyw, _ := gpyw.MarshalBinary()
 
 	gs := suite.Point()
 	gs.Mul(sigma, nil)
@@ -322,20 +401,12 @@ func ClientSignBlindly(suite CryptoSuite, clientParameters WISchnorrClientParame
 	zd.Mul(delta, clientParameters.Z)
 	gszd := suite.Point()
 	gszd.Add(gs, zd)
-	bGszd, _ := gszd.MarshalBinary()
 
-	bZ, _ := clientParameters.Z.MarshalBinary()
-
-	var combinedmsg []byte
-
-	combinedmsg = append(combinedmsg, bGpyw...)
-	combinedmsg = append(combinedmsg, bGszd...)
-	combinedmsg = append(combinedmsg, bZ...)
-	combinedmsg = append(combinedmsg, msg...)
-
-	hasher, _ := blake2b.New512(nil)
-	hasher.Write(combinedmsg)
-	sig := suite.Scalar().SetBytes(hasher.Sum(nil))
+	sig, err := blindHashChallenge(suite, pubKey, gpyw, gszd,
+		clientParameters.Z, msg)
+	if err != nil {
+		return WIBlindSignature{}, false
+	}
 
 	vsig := suite.Scalar()
 	vsig.Add(omega, delta)
@@ -366,22 +437,10 @@ func VerifyBlindSignature(suite CryptoSuite, pk SchnorrPublicKV,
 	zd := suite.Point().Mul(sig.D, z)
 	gszd := suite.Point().Add(gs, zd)
 
-	bP1, _ := gpyw.MarshalBinary()
-	bP2, _ := gszd.MarshalBinary()
-	bZ, _ := z.MarshalBinary()
-
-	var combinedmsg []byte
-
-	combinedmsg = append(combinedmsg, bP1...)
-	combinedmsg = append(combinedmsg, bP2...)
-	combinedmsg = append(combinedmsg, bZ...)
-	combinedmsg = append(combinedmsg, msg...)
-
-	hasher, _ := blake2b.New512(nil)
-	hasher.Write(combinedmsg)
-	bSig := hasher.Sum(nil)
-
-	hsig := suite.Scalar().SetBytes(bSig)
+	hsig, err := blindHashChallenge(suite, pk, gpyw, gszd, z, msg)
+	if err != nil {
+		return false, err
+	}
 
 	vsig := suite.Scalar()
 	vsig.Add(sig.W, sig.D)
@@ -0,0 +1,284 @@
+package schnorrgs
+
+/*
+This file extends the partially blind signature scheme of partialBlind.go to
+several signers, so that a signature needs every one of them and no single
+server can issue one on its own.
+
+Each signer i holds a key x_i and the group signs with the MuSig joint key
+y = sum a_i X_i (see multisignatures.go). Each signer draws its own Fig. 1
+parameters u_i, s_i, d_i, so that
+
+    A = sum A_i = g^{sum u_i}
+    B = sum B_i = g^{sum s_i} z^{sum d_i}
+
+are exactly the parameters of a single signer with u = sum u_i, s = sum s_i
+and d = sum d_i. The user blinds A and B with ClientGenerateChallenge as
+before and sends every signer the same e. All signers then have to answer
+with the same c = e - d, which needs d, so the protocol takes one more
+round than the single signer one:
+
+1. every signer publishes A_i and B_i,
+2. the user sends e along with everybody's A_j and B_j,
+3. every signer opens B_i by revealing s_i and d_i,
+4. the user sends all openings; every signer checks each against B_j,
+   computes c = e - sum d_j and answers r_i = u_i - c a_i x_i.
+
+A signer only reveals d_i once it has seen e and every commitment, and the
+commitments bind the other d_j (nobody knows the discrete log of z), so
+nobody can choose c after seeing d_i. The user adds everything up into a
+single signer response and finishes with ClientSignBlindly; the signature
+verifies with VerifyBlindSignature against the joint key.
+
+Every u_i, s_i and d_i is drawn over the whole group, as in the single
+signer scheme: a short u_i would give a signer's key away through r_i.
+
+A signer session is good for one signature only. As with the single signer
+scheme, signers should not run an unbounded number of sessions at once.
+*/
+
+import (
+	"errors"
+	"github.com/dedis/kyber"
+	"strconv"
+)
+
+// A signer's opening of its commitment B_i = g^{s_i} z^{d_i}, sent once the
+// user has committed to its challenge.
+type WISchnorrBlindingOpening struct {
+	S kyber.Scalar
+	D kyber.Scalar
+}
+
+// Encodes an opening as s||d.
+func (o WISchnorrBlindingOpening) MarshalBinary() ([]byte, error) {
+	return blindMarshal(o.S, o.D)
+}
+
+// Decodes an opening encoded by MarshalBinary.
+func (o *WISchnorrBlindingOpening) UnmarshalBinary(suite CryptoSuite,
+	b []byte) error {
+
+	d := newStrictDecoder(suite, "blinding opening", b)
+	S, D := d.scalar(), d.scalar()
+	err := d.finish()
+	if err != nil {
+		return err
+	}
+	*o = WISchnorrBlindingOpening{S, D}
+	return nil
+}
+
+// The state one signer keeps across the rounds of a partially blind
+// multisignature.
+type WISchnorrMultiSignerSession struct {
+	suite   CryptoSuite
+	privKey SchnorrSecretKV
+	keys    []SchnorrPublicKV
+	index   int
+	params  WISchnorrBlindPrivateParams
+
+	e           kyber.Scalar
+	commitments []WISchnorrPublicParams
+	done        bool
+}
+
+// Starts a signing session for the signer holding privKey within the group
+// groupKeys, for the agreed info. Returns the session and the public
+// parameters A_i, B_i to send to the user.
+func NewMultiSignerSession(suite CryptoSuite, privKey SchnorrSecretKV,
+	groupKeys []SchnorrPublicKV,
+	info []byte) (*WISchnorrMultiSignerSession, WISchnorrPublicParams, error) {
+
+	err := checkSigningKey(suite, privKey)
+	if err != nil {
+		return nil, WISchnorrPublicParams{}, err
+	}
+	err = checkKeySuites(privKey.suite, groupKeys)
+	if err != nil {
+		return nil, WISchnorrPublicParams{}, err
+	}
+
+	pk := privKey.GetPublicKeyset()
+	index := -1
+	for i, k := range groupKeys {
+		if k.pP.Equal(pk.pP) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return nil, WISchnorrPublicParams{}, errors.New("Key is not a " +
+			"member of the group.")
+	}
+
+	params, err := NewPrivateParams(suite, info)
+	if err != nil {
+		return nil, WISchnorrPublicParams{}, err
+	}
+
+	session := &WISchnorrMultiSignerSession{suite: suite, privKey: privKey,
+		keys: groupKeys, index: index, params: params}
+	return session, params.DerivePubParams(), nil
+}
+
+// Takes the user's challenge and the public parameters of every signer, in
+// group order, and opens our commitment. Our own parameters must be in the
+// list unchanged.
+func (ss *WISchnorrMultiSignerSession) Challenge(challenge WISchnorrChallengeMessage,
+	commitments []WISchnorrPublicParams) (WISchnorrBlindingOpening, error) {
+
+	if ss.done || ss.e != nil {
+		return WISchnorrBlindingOpening{}, errors.New("Session has already " +
+			"been challenged.")
+	}
+	if len(commitments) != len(ss.keys) {
+		return WISchnorrBlindingOpening{}, errors.New("Need one commitment " +
+			"per signer.")
+	}
+	own := commitments[ss.index]
+	if own.A == nil || own.B == nil || !own.A.Equal(ss.params.A) ||
+		!own.B.Equal(ss.params.B) {
+		return WISchnorrBlindingOpening{}, errors.New("Our commitment is " +
+			"missing from the list.")
+	}
+	for i, c := range commitments {
+		if c.A == nil || c.B == nil {
+			return WISchnorrBlindingOpening{}, errors.New("Commitment " +
+				strconv.Itoa(i) + " is incomplete.")
+		}
+	}
+
+	ss.e = challenge.E
+	ss.commitments = commitments
+	return WISchnorrBlindingOpening{S: ss.params.S, D: ss.params.D}, nil
+}
+
+// Checks every opening against the commitment it opens, and returns
+// sum d_j.
+func checkBlindingOpenings(suite CryptoSuite, z kyber.Point,
+	commitments []WISchnorrPublicParams,
+	openings []WISchnorrBlindingOpening) (kyber.Scalar, error) {
+
+	if len(openings) != len(commitments) {
+		return nil, errors.New("Need one opening per signer.")
+	}
+	d := suite.Scalar().Zero()
+	for i, o := range openings {
+		if o.S == nil || o.D == nil {
+			return nil, errors.New("Opening " + strconv.Itoa(i) +
+				" is incomplete.")
+		}
+		B := suite.Point().Add(suite.Point().Mul(o.S, nil),
+			suite.Point().Mul(o.D, z))
+		if !B.Equal(commitments[i].B) {
+			return nil, errors.New("Opening " + strconv.Itoa(i) +
+				" does not match its commitment.")
+		}
+		d.Add(d, o.D)
+	}
+	return d, nil
+}
+
+// Takes the openings of every signer, in group order, and answers with our
+// share of the response, r_i = u_i - c a_i x_i where c = e - sum d_j. The
+// session can not be used again afterwards.
+func (ss *WISchnorrMultiSignerSession) Respond(
+	openings []WISchnorrBlindingOpening) (kyber.Scalar, error) {
+
+	if ss.done {
+		return nil, errors.New("Session has already been used.")
+	}
+	if ss.e == nil {
+		return nil, errors.New("Session has not been challenged.")
+	}
+	d, err := checkBlindingOpenings(ss.suite, ss.params.Z, ss.commitments,
+		openings)
+	if err != nil {
+		return nil, err
+	}
+	own := openings[ss.index]
+	if !own.S.Equal(ss.params.S) || !own.D.Equal(ss.params.D) {
+		return nil, errors.New("Our opening is missing from the list.")
+	}
+
+	a, err := SchnorrMSComputeKeyCoefficient(ss.suite, ss.keys,
+		ss.privKey.GetPublicKeyset())
+	if err != nil {
+		return nil, err
+	}
+
+	// whatever happens from here on, u_i must not be used again.
+	ss.done = true
+
+	c := ss.suite.Scalar().Sub(ss.e, d)
+	cax := ss.suite.Scalar().Mul(c, a)
+	cax.Mul(cax, ss.privKey.s)
+	return ss.suite.Scalar().Sub(ss.params.U, cax), nil
+}
+
+// Overwrites the secret parameters of the session, which can not be used
+// afterwards. Anything made from them, such as an opening, has to be
+// encoded first.
+func (ss *WISchnorrMultiSignerSession) Destroy() {
+	ss.params.U.Zero()
+	ss.params.S.Zero()
+	ss.params.D.Zero()
+	ss.done = true
+}
+
+// Adds up the public parameters of all signers into those of the group,
+// to pass to ClientGenerateChallenge along with the joint key.
+func ClientAggregatePublicParams(suite CryptoSuite,
+	params []WISchnorrPublicParams) WISchnorrPublicParams {
+
+	A := suite.Point().Null()
+	B := suite.Point().Null()
+	for _, p := range params {
+		A.Add(A, p.A)
+		B.Add(B, p.B)
+	}
+	return WISchnorrPublicParams{A, B}
+}
+
+/* ClientCombineResponses checks every signer's opening and response and
+   combines them into the response of a single signer holding the joint
+   key, which ClientSignBlindly turns into the signature. An error names
+   the first signer that misbehaved. */
+func ClientCombineResponses(suite CryptoSuite, groupKeys []SchnorrPublicKV,
+	clientParameters WISchnorrClientParamersList,
+	challenge WISchnorrChallengeMessage, commitments []WISchnorrPublicParams,
+	openings []WISchnorrBlindingOpening,
+	responses []kyber.Scalar) (WISchnorrResponseMessage, error) {
+
+	if len(commitments) != len(groupKeys) || len(responses) != len(groupKeys) {
+		return WISchnorrResponseMessage{}, errors.New("Need one commitment " +
+			"and response per signer.")
+	}
+	d, err := checkBlindingOpenings(suite, clientParameters.Z, commitments,
+		openings)
+	if err != nil {
+		return WISchnorrResponseMessage{}, err
+	}
+	c := suite.Scalar().Sub(challenge.E, d)
+
+	r := suite.Scalar().Zero()
+	s := suite.Scalar().Zero()
+	for i, ri := range responses {
+		a, err := SchnorrMSComputeKeyCoefficient(suite, groupKeys, groupKeys[i])
+		if err != nil {
+			return WISchnorrResponseMessage{}, err
+		}
+		// g^{r_i} (X_i)^{c a_i} must give back A_i.
+		rG := suite.Point().Mul(ri, nil)
+		caX := suite.Point().Mul(suite.Scalar().Mul(c, a), groupKeys[i].pP)
+		if !suite.Point().Add(rG, caX).Equal(commitments[i].A) {
+			return WISchnorrResponseMessage{}, errors.New("Response " +
+				strconv.Itoa(i) + " is invalid.")
+		}
+		r.Add(r, ri)
+		s.Add(s, openings[i].S)
+	}
+
+	return WISchnorrResponseMessage{R: r, C: c, S: s, D: d}, nil
+}
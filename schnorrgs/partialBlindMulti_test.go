@@ -0,0 +1,211 @@
+package schnorrgs
+
+import (
+	"crypto/rand"
+	"github.com/dedis/kyber"
+	"github.com/dedis/kyber/group/edwards25519"
+	"testing"
+)
+
+// Runs the four rounds of a partially blind multisignature between the
+// given signers and a user, returning the user's view of the transcript.
+func multiBlindRounds(t *testing.T, suite CryptoSuite,
+	privKeys []SchnorrSecretKV, keys []SchnorrPublicKV, joint SchnorrPublicKV,
+	info []byte, message []byte) ([]*WISchnorrMultiSignerSession,
+	WISchnorrClientParamersList, WISchnorrChallengeMessage,
+	[]WISchnorrPublicParams, []WISchnorrBlindingOpening, []kyber.Scalar) {
+
+	var sessions []*WISchnorrMultiSignerSession
+	var commitments []WISchnorrPublicParams
+	for _, k := range privKeys {
+		ss, pp, err := NewMultiSignerSession(suite, k, keys, info)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sessions = append(sessions, ss)
+		commitments = append(commitments, pp)
+	}
+
+	agg := ClientAggregatePublicParams(suite, commitments)
+	challenge, userParams, err := ClientGenerateChallenge(suite, agg, joint,
+		info, message)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var openings []WISchnorrBlindingOpening
+	for _, ss := range sessions {
+		o, err := ss.Challenge(challenge, commitments)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		openings = append(openings, o)
+	}
+
+	var responses []kyber.Scalar
+	for _, ss := range sessions {
+		r, err := ss.Respond(openings)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		responses = append(responses, r)
+	}
+	return sessions, userParams, challenge, commitments, openings, responses
+}
+
+func TestPartialBlindMultiSignature(t *testing.T) {
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+
+	var privKeys []SchnorrSecretKV
+	var keys []SchnorrPublicKV
+	for i := 0; i < 3; i++ {
+		k, _ := SchnorrGenerateKeypair(suite)
+		privKeys = append(privKeys, k)
+		keys = append(keys, k.GetPublicKeyset())
+	}
+	joint, err := SchnorrMSComputeSharedPublicKey(suite, keys)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	info := []byte("denomination=10")
+	message := make([]byte, 16)
+	rand.Read(message)
+
+	sessions, userParams, challenge, commitments, openings, responses :=
+		multiBlindRounds(t, suite, privKeys, keys, joint, info, message)
+
+	response, err := ClientCombineResponses(suite, keys, userParams,
+		challenge, commitments, openings, responses)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	sig, worked := ClientSignBlindly(suite, userParams, response, joint, message)
+	if !worked {
+		t.Error("ClientSignBlindly did not accept the combined response.")
+	}
+
+	ok, err := VerifyBlindSignature(suite, joint, sig, info, message)
+	if err != nil || !ok {
+		t.Error("Partially blind multisignature does not verify.")
+	}
+	ok, _ = VerifyBlindSignature(suite, joint, sig, []byte("denomination=100"),
+		message)
+	if ok {
+		t.Error("Signature verifies with the wrong info.")
+	}
+	for i, k := range keys {
+		ok, _ = VerifyBlindSignature(suite, k, sig, info, message)
+		if ok {
+			t.Error("Signature verifies under the key of signer", i)
+		}
+	}
+
+	// sessions are single use.
+	_, err = sessions[0].Respond(openings)
+	if err == nil {
+		t.Error("Signer answered twice in one session.")
+	}
+	_, err = sessions[1].Challenge(challenge, commitments)
+	if err == nil {
+		t.Error("Signer accepted a second challenge.")
+	}
+
+	// a bad response is caught and blamed on its signer.
+	responses[2] = suite.Scalar().Add(responses[2], suite.Scalar().One())
+	_, err = ClientCombineResponses(suite, keys, userParams, challenge,
+		commitments, openings, responses)
+	if err == nil {
+		t.Error("ClientCombineResponses accepted a bad response.")
+	}
+}
+
+func TestPartialBlindMultiSignatureOpenings(t *testing.T) {
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+
+	var privKeys []SchnorrSecretKV
+	var keys []SchnorrPublicKV
+	for i := 0; i < 2; i++ {
+		k, _ := SchnorrGenerateKeypair(suite)
+		privKeys = append(privKeys, k)
+		keys = append(keys, k.GetPublicKeyset())
+	}
+	joint, _ := SchnorrMSComputeSharedPublicKey(suite, keys)
+	info := []byte("info")
+
+	ss0, pp0, err := NewMultiSignerSession(suite, privKeys[0], keys, info)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ss1, pp1, _ := NewMultiSignerSession(suite, privKeys[1], keys, info)
+	commitments := []WISchnorrPublicParams{pp0, pp1}
+
+	agg := ClientAggregatePublicParams(suite, commitments)
+	challenge, _, _ := ClientGenerateChallenge(suite, agg, joint, info,
+		[]byte("coin"))
+
+	// a signer refuses a list that leaves out its own commitment.
+	_, err = ss0.Challenge(challenge, []WISchnorrPublicParams{pp1, pp1})
+	if err == nil {
+		t.Error("Signer accepted a list without its commitment.")
+	}
+
+	o0, err := ss0.Challenge(challenge, commitments)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	o1, _ := ss1.Challenge(challenge, commitments)
+
+	// changing d_1 after seeing d_0 would let the user pick c.
+	forged := WISchnorrBlindingOpening{S: o1.S,
+		D: suite.Scalar().Add(o1.D, suite.Scalar().One())}
+	_, err = ss0.Respond([]WISchnorrBlindingOpening{o0, forged})
+	if err == nil {
+		t.Error("Signer accepted an opening that does not match its commitment.")
+	}
+	_, err = ss0.Respond([]WISchnorrBlindingOpening{o0, o1})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// a destroyed session answers nothing.
+	ss1.Destroy()
+	_, err = ss1.Respond([]WISchnorrBlindingOpening{o0, o1})
+	if err == nil {
+		t.Error("Destroyed session answered.")
+	}
+
+	// outsiders can not start a session.
+	outsider, _ := SchnorrGenerateKeypair(suite)
+	_, _, err = NewMultiSignerSession(suite, outsider, keys, info)
+	if err == nil {
+		t.Error("Non-member started a signing session.")
+	}
+}
+
+func TestPartialBlindMultiParametersFullSize(t *testing.T) {
+
+	suite := edwards25519.NewBlakeSHA256Ed25519()
+
+	var privKeys []SchnorrSecretKV
+	var keys []SchnorrPublicKV
+	for i := 0; i < 2; i++ {
+		k, _ := SchnorrGenerateKeypair(suite)
+		privKeys = append(privKeys, k)
+		keys = append(keys, k.GetPublicKeyset())
+	}
+	for _, k := range privKeys {
+		ss, _, err := NewMultiSignerSession(suite, k, keys, []byte("info"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for i, s := range []kyber.Scalar{ss.params.U, ss.params.S,
+			ss.params.D} {
+			if nonZeroBytes(s) <= 16 {
+				t.Errorf("Signer parameter %d is short", i)
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package schnorrgs
 import (
 	// "fmt"
 	"crypto/rand"
+	"github.com/dedis/kyber"
 	"github.com/dedis/kyber/group/edwards25519"
 	"testing"
 )
@@ -54,7 +55,11 @@ func TestPartialBlindSignatureScheme(t *testing.T) {
 	}
 
 	// and now we compute a response on the server side.
-	response := ServerGenerateResponse(suite, challenge, signerParams, privKey)
+	response, err := ServerGenerateResponse(suite, challenge, signerParams,
+		privKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
 
 	// finally, we can sign the message and check it verifies.
 	sig, worked := ClientSignBlindly(suite, userPrivateParams, response, pubKey, message)
@@ -87,3 +92,135 @@ func TestPartialBlindSignatureScheme(t *testing.T) {
 		t.Error("VerifyBlindSignature succeeded with bad info - this should fail.")
 	}
 }
+
+// Runs the protocol with every message going through its encoding, as
+// between the server and the client.
+func TestPartialBlindEncoding(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		privKey, _ := SchnorrGenerateKeypair(suite)
+		pubKey := privKey.GetPublicKeyset()
+		info := []byte("denomination 10")
+		message := []byte("coin serial 1")
+
+		signerParams, err := NewPrivateParams(suite, info)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		b, _ := signerParams.MarshalBinary()
+		var storedParams WISchnorrBlindPrivateParams
+		err = storedParams.UnmarshalBinary(suite, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		b, _ = signerParams.DerivePubParams().MarshalBinary()
+		var publicParams WISchnorrPublicParams
+		err = publicParams.UnmarshalBinary(suite, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		challenge, clientParams, err := ClientGenerateChallenge(suite,
+			publicParams, pubKey, info, message)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		b, _ = challenge.MarshalBinary()
+		var receivedChallenge WISchnorrChallengeMessage
+		err = receivedChallenge.UnmarshalBinary(suite, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		b, _ = clientParams.MarshalBinary()
+		var storedClientParams WISchnorrClientParamersList
+		err = storedClientParams.UnmarshalBinary(suite, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		response, err := ServerGenerateResponse(suite, receivedChallenge,
+			storedParams, privKey)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		b, _ = response.MarshalBinary()
+		var receivedResponse WISchnorrResponseMessage
+		err = receivedResponse.UnmarshalBinary(suite, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		sig, worked := ClientSignBlindly(suite, storedClientParams,
+			receivedResponse, pubKey, message)
+		if !worked {
+			t.Fatalf("%s: signing over the encodings failed", name)
+		}
+		b, _ = sig.MarshalBinary()
+		var decoded WIBlindSignature
+		err = decoded.UnmarshalBinary(suite, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		result, err := VerifyBlindSignature(suite, pubKey, decoded, info, message)
+		if err != nil || !result {
+			t.Errorf("%s: decoded signature does not verify", name)
+		}
+
+		if decoded.UnmarshalBinary(suite, b[1:]) == nil ||
+			decoded.UnmarshalBinary(suite, append(b, 0)) == nil {
+			t.Errorf("%s: signature of the wrong length decoded", name)
+		}
+		if publicParams.UnmarshalBinary(suite, nil) == nil ||
+			receivedResponse.UnmarshalBinary(suite, b[:3]) == nil ||
+			receivedChallenge.UnmarshalBinary(suite, b) == nil {
+			t.Errorf("%s: message of the wrong length decoded", name)
+		}
+
+		// private parameters whose points do not match their scalars.
+		tampered := signerParams
+		tampered.U = suite.Scalar().Add(tampered.U, suite.Scalar().One())
+		b, _ = tampered.MarshalBinary()
+		if storedParams.UnmarshalBinary(suite, b) == nil {
+			t.Errorf("%s: inconsistent private parameters decoded", name)
+		}
+	}
+}
+
+// Counts the non-zero bytes of an encoded scalar.
+func nonZeroBytes(s kyber.Scalar) int {
+	b, _ := s.MarshalBinary()
+	n := 0
+	for _, c := range b {
+		if c != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPartialBlindParametersFullSize(t *testing.T) {
+
+	// a 128 bit scalar has at most 16 non-zero bytes, whatever the byte
+	// order; a uniform one almost never does.
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+		params, err := NewPrivateParams(suite, []byte("info"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		_, client, err := ClientGenerateChallenge(suite,
+			params.DerivePubParams(), kv.GetPublicKeyset(), []byte("info"),
+			[]byte("msg"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		for i, s := range []kyber.Scalar{params.U, params.S, params.D,
+			client.T1, client.T2, client.T3, client.T4} {
+			if nonZeroBytes(s) <= 16 {
+				t.Errorf("%s: blind scalar %d is short", name, i)
+			}
+		}
+	}
+}
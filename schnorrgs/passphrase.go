@@ -0,0 +1,62 @@
+package schnorrgs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Environment variable the binaries read a key file passphrase from when
+// no passphrase file is given.
+const PassphraseEnvVar = "SCHNORRGS_PASSPHRASE"
+
+// Reads a passphrase from the file at path if one is given, else from
+// PassphraseEnvVar if it is set, else by prompting on the terminal with
+// echo turned off. A trailing newline is not part of the passphrase.
+func SchnorrReadPassphrase(path string, prompt string) ([]byte, error) {
+	if path != "" {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.TrimRight(string(contents), "\r\n")), nil
+	}
+	if pass, ok := os.LookupEnv(PassphraseEnvVar); ok {
+		return []byte(pass), nil
+	}
+	return promptPassphrase(prompt)
+}
+
+// Prompts for a passphrase on the controlling terminal. stty is the only
+// portable way to turn echo off without pulling in a terminal library.
+func promptPassphrase(prompt string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.New("No terminal to ask for the passphrase; " +
+			"use a passphrase file or " + PassphraseEnvVar)
+	}
+	defer tty.Close()
+
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = tty
+		return cmd.Run()
+	}
+	err = stty("-echo")
+	if err != nil {
+		return nil, err
+	}
+	defer stty("echo")
+
+	fmt.Fprint(tty, prompt)
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(tty)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
@@ -0,0 +1,73 @@
+package schnorrgs
+
+/* This file implements proofs of possession: a key signs its own suite
+   and encoded public key, showing that whoever published the key also
+   holds the secret half. Groups refuse members without one, so nobody can
+   join with a key they made up from the other members' keys.
+
+   Proofs are made under their own protocol tag (see domain.go), so a
+   proof can not be passed off as a signature on anything else, nor
+   anything else as a proof.
+*/
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// Builds the message a proof of possession signs:
+// len(suite) || suite || encoded public key.
+func popMessage(pk SchnorrPublicKV) ([]byte, error) {
+	b, err := pk.pP.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	msg := []byte{byte(len(pk.suite))}
+	msg = append(msg, []byte(pk.suite)...)
+	msg = append(msg, b...)
+	return msg, nil
+}
+
+// Generates a proof that we hold the secret key of kv, to be published
+// along with the public key.
+func SchnorrGenerateProofOfPossession(suite CryptoSuite,
+	kv SchnorrSecretKV) (SchnorrSignature, error) {
+
+	msg, err := popMessage(kv.GetPublicKeyset())
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+	return schnorrSignTagged(suite, kv, popSignatureTag, nil, msg, NonceHedged)
+}
+
+// Checks a proof of possession for pk.
+func SchnorrVerifyProofOfPossession(suite CryptoSuite, pk SchnorrPublicKV,
+	proof SchnorrSignature) (bool, error) {
+
+	msg, err := popMessage(pk)
+	if err != nil {
+		return false, err
+	}
+	return schnorrVerifyTagged(suite, pk, popSignatureTag, nil, msg, proof)
+}
+
+// Exports a proof of possession as a hex string, the form used in key
+// files and group configurations.
+func (sig SchnorrSignature) ExportProofOfPossession() (string, error) {
+	b, err := sig.Encode()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Parses a proof of possession exported by ExportProofOfPossession.
+func NewProofOfPossessionFromString(suite CryptoSuite,
+	source string) (SchnorrSignature, error) {
+
+	b, err := hex.DecodeString(strings.TrimSpace(source))
+	if err != nil {
+		return SchnorrSignature{}, decodeError("proof of possession", err)
+	}
+	return DecodeSchnorrSignature(suite, b)
+}
@@ -0,0 +1,62 @@
+package schnorrgs
+
+import (
+	"testing"
+)
+
+func TestProofOfPossession(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		other, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		proof, err := SchnorrGenerateProofOfPossession(suite, kv)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		s, err := proof.ExportProofOfPossession()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		decoded, err := NewProofOfPossessionFromString(suite, s+"\n")
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		v, err := SchnorrVerifyProofOfPossession(suite, kv.GetPublicKeyset(),
+			decoded)
+		if err != nil || !v {
+			t.Errorf("%s: proof of possession did not verify", name)
+		}
+
+		v, _ = SchnorrVerifyProofOfPossession(suite, other.GetPublicKeyset(),
+			decoded)
+		if v {
+			t.Errorf("%s: proof verified for another key", name)
+		}
+
+		// a signature on the bare key is not a proof.
+		b, _ := kv.pP.MarshalBinary()
+		sig, err := SchnorrSign(suite, kv, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		v, _ = SchnorrVerifyProofOfPossession(suite, kv.GetPublicKeyset(), sig)
+		if v {
+			t.Errorf("%s: plain signature accepted as a proof", name)
+		}
+
+		_, err = NewProofOfPossessionFromString(suite, s[:len(s)-2])
+		if err == nil {
+			t.Errorf("%s: accepted a truncated proof", name)
+		}
+	}
+}
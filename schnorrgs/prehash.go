@@ -0,0 +1,64 @@
+package schnorrgs
+
+/* This file implements prehashed signatures over streams.
+
+   A plain signature hashes R || M, and R is only known once signing
+   starts, so the whole message has to be in memory. In prehash mode the
+   message is first digested on its own with Blake2b-512, as it streams
+   in, and the signature is made over the digest instead. Prehash
+   signatures have their own protocol tag (see domain.go), so one is never
+   taken for a plain signature on the digest, or the other way round.
+*/
+
+import (
+	"golang.org/x/crypto/blake2b"
+	"io"
+)
+
+// Digests everything r yields with Blake2b-512, the message a prehash
+// signature is made over. Only a small buffer is held at a time, however
+// long the input.
+func SchnorrPrehashReader(r io.Reader) ([]byte, error) {
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// Signs everything r yields in prehash mode, in the given context (for
+// example "release-signing"), using the given nonce mode.
+func SchnorrSignReader(suite CryptoSuite, kv SchnorrSecretKV, context []byte,
+	r io.Reader, mode SchnorrNonceMode) (SchnorrSignature, error) {
+
+	err := checkSuite(suite, kv.suite)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+	digest, err := SchnorrPrehashReader(r)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+	return schnorrSignTagged(suite, kv, prehashTag, context, digest, mode)
+}
+
+// Checks a prehash signature against everything r yields, in the given
+// context.
+func SchnorrVerifyReader(suite CryptoSuite, kp SchnorrPublicKV,
+	context []byte, r io.Reader, signature SchnorrSignature) (bool, error) {
+
+	err := checkSuite(suite, kp.suite)
+	if err != nil {
+		return false, err
+	}
+	digest, err := SchnorrPrehashReader(r)
+	if err != nil {
+		return false, err
+	}
+	return schnorrVerifyTagged(suite, kp, prehashTag, context, digest,
+		signature)
+}
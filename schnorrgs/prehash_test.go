@@ -0,0 +1,97 @@
+package schnorrgs
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// Yields n bytes of a repeating pattern without holding them in memory.
+type patternReader struct {
+	n    int64
+	flip bool
+}
+
+func (p *patternReader) Read(b []byte) (int, error) {
+	if p.n == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(b)) > p.n {
+		b = b[:p.n]
+	}
+	for i := range b {
+		b[i] = byte(i)
+	}
+	if p.flip && p.n <= int64(len(b)) {
+		b[len(b)-1] ^= 1
+	}
+	p.n -= int64(len(b))
+	return len(b), nil
+}
+
+func TestSignReader(t *testing.T) {
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kv, _ := SchnorrGenerateKeypair(suite)
+		pk := kv.GetPublicKeyset()
+
+		const size = 16 << 20
+		ctx := []byte("release-signing")
+		sig, err := SchnorrSignReader(suite, kv, ctx, &patternReader{n: size},
+			NonceHedged)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		ok, err := SchnorrVerifyReader(suite, pk, ctx, &patternReader{n: size}, sig)
+		if err != nil || !ok {
+			t.Error("Prehash signature does not verify.")
+		}
+
+		// the last byte differs.
+		ok, _ = SchnorrVerifyReader(suite, pk, ctx,
+			&patternReader{n: size, flip: true}, sig)
+		if ok {
+			t.Error("Prehash signature verifies over a different stream.")
+		}
+		ok, _ = SchnorrVerifyReader(suite, pk, ctx, &patternReader{n: size - 1}, sig)
+		if ok {
+			t.Error("Prehash signature verifies over a truncated stream.")
+		}
+		ok, _ = SchnorrVerifyReader(suite, pk, nil, &patternReader{n: size}, sig)
+		if ok {
+			t.Error("Prehash signature verifies without its context.")
+		}
+	}
+}
+
+func TestSignReaderIsNotPlainSignature(t *testing.T) {
+	suite, _ := GetSuite(DefaultSuite)
+	kv, _ := SchnorrGenerateKeypair(suite)
+	pk := kv.GetPublicKeyset()
+	msg := []byte("release-1.0.tar.gz")
+
+	sig, err := SchnorrSignReader(suite, kv, nil, bytes.NewReader(msg),
+		NonceDeterministic)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ok, _ := SchnorrVerify(suite, pk, msg, sig)
+	if ok {
+		t.Error("Prehash signature verifies as a plain signature.")
+	}
+
+	plain, _ := SchnorrSign(suite, kv, msg)
+	ok, _ = SchnorrVerifyReader(suite, pk, nil, bytes.NewReader(msg), plain)
+	if ok {
+		t.Error("Plain signature verifies as a prehash signature.")
+	}
+
+	// nor does a plain signature on the digest pass for a prehash one.
+	digest, _ := SchnorrPrehashReader(bytes.NewReader(msg))
+	plain, _ = SchnorrSign(suite, kv, digest)
+	ok, _ = SchnorrVerifyReader(suite, pk, nil, bytes.NewReader(msg), plain)
+	if ok {
+		t.Error("Plain signature on the digest verifies as a prehash signature.")
+	}
+}
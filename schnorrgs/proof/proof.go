@@ -0,0 +1,628 @@
+/* Package proof implements non-interactive Camenisch-Stadler proofs of
+   knowledge of discrete logarithms, of which a Schnorr signature is the
+   special case PK{(x): Y = xG}.
+
+   A statement is built from representations,
+
+       Rep("Y", "x", "G", "y", "H")     Y = xG + yH
+
+   combined with And and Or, for example PK{(x, y): X = xG & Y = xH + yG}
+   is
+
+       And(Rep("X", "x", "G"), Rep("Y", "x", "H", "y", "G"))
+
+   Names in lower case are only a convention: whatever the prover passes
+   as a secret is a secret, and whatever appears as the left hand side or
+   as a base has to be passed as a public point to both the prover and the
+   verifier.
+
+   Proofs are sigma protocols made non-interactive with Fiat-Shamir. Each
+   Or follows Cramer, Damgard and Schoenmakers: the prover simulates every
+   branch it can not prove and the branch challenges have to add up to the
+   challenge of the Or, so the verifier can not tell which branch was
+   real. A secret appearing several times under the same challenge is
+   proven to be the same secret everywhere. The branches of an Or each
+   have their own challenge, so nothing would link a secret across an Or:
+   a secret name may only be used in one scope, and a statement that
+   names the same secret inside and outside an Or, or in two of its
+   branches, is refused.
+
+   The challenge hashes a protocol tag, the suite, a caller-supplied
+   context, the statement, every public point it names and every
+   commitment. A proof is the root challenge, all but the last branch
+   challenge of every Or and one response per secret per challenge, all
+   scalars; its length is fixed by the statement.
+*/
+package proof
+
+import (
+	"bytes"
+	"errors"
+	"github.com/dedis/kyber"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"golang.org/x/crypto/blake2b"
+	"sort"
+	"strings"
+)
+
+// Protocol tag of the proof challenge hash.
+const proofTag = "schnorrgs-proof-v1"
+
+// A statement about discrete logarithms.
+type Predicate interface {
+	// Returns the canonical form of the statement, which is hashed into
+	// the challenge.
+	String() string
+
+	// Adds the statement to scope sc.
+	plan(sc *scope) error
+}
+
+type rep struct {
+	P       string
+	secrets []string
+	bases   []string
+}
+
+type and []Predicate
+
+type or []Predicate
+
+// Returns the statement P = s1 B1 + s2 B2 + ..., where SB lists secret
+// and base names alternately.
+func Rep(P string, SB ...string) Predicate {
+	r := rep{P: P}
+	for i := 0; i+1 < len(SB); i += 2 {
+		r.secrets = append(r.secrets, SB[i])
+		r.bases = append(r.bases, SB[i+1])
+	}
+	if len(SB)%2 != 0 {
+		// an odd name out; plan refuses the statement.
+		r.secrets = append(r.secrets, SB[len(SB)-1])
+	}
+	return r
+}
+
+// Returns the statement that all of sub hold.
+func And(sub ...Predicate) Predicate {
+	return and(sub)
+}
+
+// Returns the statement that at least one of sub holds.
+func Or(sub ...Predicate) Predicate {
+	return or(sub)
+}
+
+func (r rep) String() string {
+	var terms []string
+	for i := range r.secrets {
+		base := ""
+		if i < len(r.bases) {
+			base = r.bases[i]
+		}
+		terms = append(terms, r.secrets[i]+"*"+base)
+	}
+	return r.P + "=" + strings.Join(terms, "+")
+}
+
+func joinPredicates(sub []Predicate, sep string) string {
+	var parts []string
+	for _, p := range sub {
+		parts = append(parts, p.String())
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+func (a and) String() string {
+	return joinPredicates(a, "&")
+}
+
+func (o or) String() string {
+	return joinPredicates(o, "|")
+}
+
+// Names may only hold letters, digits and underscores, so that the
+// canonical form of a statement is unambiguous.
+func checkName(name string) error {
+	if name == "" {
+		return errors.New("Empty name in statement.")
+	}
+	for _, c := range name {
+		if !(c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' ||
+			c >= 'A' && c <= 'Z') {
+			return errors.New("Invalid name in statement: " + name)
+		}
+	}
+	return nil
+}
+
+/* The statement is split into scopes: the parts of it that share one
+   challenge. The whole statement is one scope, and every branch of an Or
+   starts a new one. */
+type scope struct {
+	leaves []rep
+	ors    [][]*scope
+	vars   []string // sorted
+
+	// prover state
+	real bool
+	v    map[string]kyber.Scalar
+	r    map[string]kyber.Scalar
+	c    kyber.Scalar
+}
+
+func (r rep) plan(sc *scope) error {
+	err := checkName(r.P)
+	if err != nil {
+		return err
+	}
+	if len(r.secrets) == 0 || len(r.secrets) != len(r.bases) {
+		return errors.New("Representation needs secret and base pairs: " +
+			r.String())
+	}
+	for i := range r.secrets {
+		if checkName(r.secrets[i]) != nil || checkName(r.bases[i]) != nil {
+			return errors.New("Invalid name in statement: " + r.String())
+		}
+	}
+	sc.leaves = append(sc.leaves, r)
+	return nil
+}
+
+func (a and) plan(sc *scope) error {
+	if len(a) == 0 {
+		return errors.New("Empty And in statement.")
+	}
+	for _, p := range a {
+		err := p.plan(sc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o or) plan(sc *scope) error {
+	if len(o) == 0 {
+		return errors.New("Empty Or in statement.")
+	}
+	var branches []*scope
+	for _, p := range o {
+		branch := &scope{}
+		err := p.plan(branch)
+		if err != nil {
+			return err
+		}
+		branches = append(branches, branch)
+	}
+	sc.ors = append(sc.ors, branches)
+	return nil
+}
+
+// Lists the secrets of every scope under sc. owner records the scope
+// each secret name was first seen in; a name that turns up in another
+// scope is refused, as the two would not be proven to be the same secret.
+func (sc *scope) collectVars(owner map[string]*scope) error {
+	for _, l := range sc.leaves {
+		for _, s := range l.secrets {
+			first, ok := owner[s]
+			if ok && first != sc {
+				return errors.New("Secret " + s + " is used both inside " +
+					"and outside an Or, or in two of its branches, which " +
+					"does not prove it is the same secret; rename one of them.")
+			}
+			if !ok {
+				owner[s] = sc
+				sc.vars = append(sc.vars, s)
+			}
+		}
+	}
+	sort.Strings(sc.vars)
+	for _, branches := range sc.ors {
+		for _, b := range branches {
+			err := b.collectVars(owner)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Calls f on sc and every scope under it, parents first, in statement
+// order. Prover and verifier both lay out proofs in this order.
+func (sc *scope) walk(f func(*scope)) {
+	f(sc)
+	for _, branches := range sc.ors {
+		for _, b := range branches {
+			b.walk(f)
+		}
+	}
+}
+
+// Builds the scopes of a statement.
+func planStatement(pred Predicate) (*scope, error) {
+	if pred == nil {
+		return nil, errors.New("No statement.")
+	}
+	root := &scope{}
+	err := pred.plan(root)
+	if err != nil {
+		return nil, err
+	}
+	err = root.collectVars(make(map[string]*scope))
+	if err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// Returns every public name the statement uses, sorted.
+func (sc *scope) publicNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	sc.walk(func(s *scope) {
+		for _, l := range s.leaves {
+			for _, n := range append([]string{l.P}, l.bases...) {
+				if !seen[n] {
+					seen[n] = true
+					names = append(names, n)
+				}
+			}
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+// Looks up every public point the statement uses.
+func checkPublics(root *scope,
+	public map[string]kyber.Point) ([]string, error) {
+
+	names := root.publicNames()
+	for _, n := range names {
+		if public[n] == nil {
+			return nil, errors.New("No public point " + n)
+		}
+	}
+	return names, nil
+}
+
+// Computes the commitment of a leaf from responses and challenge:
+// T = sum r_i B_i + c P.
+func simulatedCommitment(suite schnorrgs.CryptoSuite, l rep,
+	public map[string]kyber.Point, r map[string]kyber.Scalar,
+	c kyber.Scalar) kyber.Point {
+
+	T := suite.Point().Mul(c, public[l.P])
+	for i, s := range l.secrets {
+		T.Add(T, suite.Point().Mul(r[s], public[l.bases[i]]))
+	}
+	return T
+}
+
+// Computes the root challenge from the statement, its public points and
+// the commitments of every leaf, in walk order.
+func challenge(suite schnorrgs.CryptoSuite, context []byte, pred Predicate,
+	names []string, public map[string]kyber.Point,
+	commitments []kyber.Point) (kyber.Scalar, error) {
+
+	if len(context) > schnorrgs.MaxContextSize {
+		return nil, errors.New("Context string longer than 255 bytes.")
+	}
+	suitename, err := schnorrgs.SuiteName(suite)
+	if err != nil {
+		return nil, err
+	}
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		return nil, err
+	}
+	statement := pred.String()
+
+	h.Write([]byte{byte(len(proofTag))})
+	h.Write([]byte(proofTag))
+	h.Write([]byte{byte(len(suitename))})
+	h.Write([]byte(suitename))
+	h.Write([]byte{byte(len(context))})
+	h.Write(context)
+	writeLength(h, len(statement))
+	h.Write([]byte(statement))
+	for _, n := range names {
+		_, err = public[n].MarshalTo(h)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, T := range commitments {
+		_, err = T.MarshalTo(h)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return suite.Scalar().SetBytes(h.Sum(nil)), nil
+}
+
+func writeLength(h interface {
+	Write([]byte) (int, error)
+}, n int) {
+	h.Write([]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+}
+
+// Tells whether the secrets satisfy every leaf of sc and at least one
+// branch of each of its Ors.
+func canProve(suite schnorrgs.CryptoSuite, sc *scope,
+	public map[string]kyber.Point, secret map[string]kyber.Scalar) bool {
+
+	for _, l := range sc.leaves {
+		sum := suite.Point().Null()
+		for i, s := range l.secrets {
+			x := secret[s]
+			if x == nil {
+				return false
+			}
+			sum.Add(sum, suite.Point().Mul(x, public[l.bases[i]]))
+		}
+		if !sum.Equal(public[l.P]) {
+			return false
+		}
+	}
+	for _, branches := range sc.ors {
+		ok := false
+		for _, b := range branches {
+			if canProve(suite, b, public, secret) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// First pass of the prover: picks nonces for real scopes and challenges
+// and responses for simulated ones. c is the challenge of a simulated
+// scope and nil for a real one.
+func commit(suite schnorrgs.CryptoSuite, sc *scope, c kyber.Scalar,
+	public map[string]kyber.Point, secret map[string]kyber.Scalar) {
+
+	rand := suite.RandomStream()
+	sc.real = c == nil
+	sc.c = c
+	sc.v = make(map[string]kyber.Scalar)
+	sc.r = make(map[string]kyber.Scalar)
+	for _, s := range sc.vars {
+		if sc.real {
+			sc.v[s] = suite.Scalar().Pick(rand)
+		} else {
+			sc.r[s] = suite.Scalar().Pick(rand)
+		}
+	}
+
+	for _, branches := range sc.ors {
+		if sc.real {
+			// prove the first branch we can and simulate the others.
+			proven := false
+			for _, b := range branches {
+				if !proven && canProve(suite, b, public, secret) {
+					proven = true
+					commit(suite, b, nil, public, secret)
+				} else {
+					commit(suite, b, suite.Scalar().Pick(rand), public, secret)
+				}
+			}
+			continue
+		}
+		// a simulated Or splits its challenge at random.
+		rest := suite.Scalar().Set(c)
+		for i, b := range branches {
+			ci := rest
+			if i < len(branches)-1 {
+				ci = suite.Scalar().Pick(rand)
+				rest = suite.Scalar().Sub(rest, ci)
+			}
+			commit(suite, b, ci, public, secret)
+		}
+	}
+}
+
+// Second pass of the prover, for real scopes: takes the challenge c, gives
+// the real branch of each Or what the simulated ones leave over and
+// answers r = v - c x.
+func respond(suite schnorrgs.CryptoSuite, sc *scope, c kyber.Scalar,
+	secret map[string]kyber.Scalar) {
+
+	sc.c = c
+	for _, s := range sc.vars {
+		sc.r[s] = suite.Scalar().Sub(sc.v[s], suite.Scalar().Mul(c, secret[s]))
+	}
+	for _, branches := range sc.ors {
+		var real *scope
+		rest := suite.Scalar().Set(c)
+		for _, b := range branches {
+			if b.real {
+				real = b
+			} else {
+				rest.Sub(rest, b.c)
+			}
+		}
+		respond(suite, real, rest, secret)
+	}
+}
+
+// Produces a proof of the statement pred in the given context. public
+// holds every point the statement names; secret holds the secrets of
+// enough of it to make it true.
+func Prove(suite schnorrgs.CryptoSuite, pred Predicate, context []byte,
+	public map[string]kyber.Point,
+	secret map[string]kyber.Scalar) ([]byte, error) {
+
+	root, err := planStatement(pred)
+	if err != nil {
+		return nil, err
+	}
+	names, err := checkPublics(root, public)
+	if err != nil {
+		return nil, err
+	}
+	if !canProve(suite, root, public, secret) {
+		return nil, errors.New("Secrets do not satisfy the statement.")
+	}
+
+	commit(suite, root, nil, public, secret)
+
+	var commitments []kyber.Point
+	root.walk(func(sc *scope) {
+		for _, l := range sc.leaves {
+			if sc.real {
+				T := suite.Point().Null()
+				for i, s := range l.secrets {
+					T.Add(T, suite.Point().Mul(sc.v[s], public[l.bases[i]]))
+				}
+				commitments = append(commitments, T)
+			} else {
+				commitments = append(commitments,
+					simulatedCommitment(suite, l, public, sc.r, sc.c))
+			}
+		}
+	})
+
+	c, err := challenge(suite, context, pred, names, public, commitments)
+	if err != nil {
+		return nil, err
+	}
+	respond(suite, root, c, secret)
+
+	return encode(root, c)
+}
+
+// Lays a proof out as root challenge, branch challenges and responses.
+func encode(root *scope, c kyber.Scalar) ([]byte, error) {
+	var b bytes.Buffer
+	_, err := c.MarshalTo(&b)
+	if err != nil {
+		return nil, err
+	}
+	var scalars []kyber.Scalar
+	root.walk(func(sc *scope) {
+		for _, branches := range sc.ors {
+			for _, br := range branches[:len(branches)-1] {
+				scalars = append(scalars, br.c)
+			}
+		}
+	})
+	root.walk(func(sc *scope) {
+		for _, s := range sc.vars {
+			scalars = append(scalars, sc.r[s])
+		}
+	})
+	for _, s := range scalars {
+		_, err = s.MarshalTo(&b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// Returns the length of a proof of pred in suite.
+func ProofSize(suite schnorrgs.CryptoSuite, pred Predicate) (int, error) {
+	root, err := planStatement(pred)
+	if err != nil {
+		return 0, err
+	}
+	n := 1
+	root.walk(func(sc *scope) {
+		for _, branches := range sc.ors {
+			n += len(branches) - 1
+		}
+		n += len(sc.vars)
+	})
+	return n * suite.Scalar().MarshalSize(), nil
+}
+
+// Checks a proof of the statement pred in the given context. public holds
+// every point the statement names.
+func Verify(suite schnorrgs.CryptoSuite, pred Predicate, context []byte,
+	public map[string]kyber.Point, proof []byte) (bool, error) {
+
+	root, err := planStatement(pred)
+	if err != nil {
+		return false, err
+	}
+	names, err := checkPublics(root, public)
+	if err != nil {
+		return false, err
+	}
+	size, err := ProofSize(suite, pred)
+	if err != nil {
+		return false, err
+	}
+	if len(proof) != size {
+		return false, errors.New("Invalid proof length.")
+	}
+
+	ssize := suite.Scalar().MarshalSize()
+	next := func() (kyber.Scalar, error) {
+		s, err := schnorrgs.DecodeScalar(suite, proof[:ssize])
+		proof = proof[ssize:]
+		return s, err
+	}
+
+	c, err := next()
+	if err != nil {
+		return false, err
+	}
+
+	// branch challenges, then responses, in walk order.
+	root.walk(func(sc *scope) {
+		for _, branches := range sc.ors {
+			for _, br := range branches[:len(branches)-1] {
+				if err == nil {
+					br.c, err = next()
+				}
+			}
+		}
+	})
+	root.walk(func(sc *scope) {
+		sc.r = make(map[string]kyber.Scalar)
+		for _, s := range sc.vars {
+			if err == nil {
+				sc.r[s], err = next()
+			}
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// every scope's challenge follows from its parent's; the last branch
+	// of an Or gets what the others leave over.
+	root.c = c
+	root.walk(func(sc *scope) {
+		for _, branches := range sc.ors {
+			rest := suite.Scalar().Set(sc.c)
+			for _, br := range branches[:len(branches)-1] {
+				rest.Sub(rest, br.c)
+			}
+			branches[len(branches)-1].c = rest
+		}
+	})
+
+	var commitments []kyber.Point
+	root.walk(func(sc *scope) {
+		for _, l := range sc.leaves {
+			commitments = append(commitments,
+				simulatedCommitment(suite, l, public, sc.r, sc.c))
+		}
+	})
+
+	expected, err := challenge(suite, context, pred, names, public,
+		commitments)
+	if err != nil {
+		return false, err
+	}
+	return expected.Equal(c), nil
+}
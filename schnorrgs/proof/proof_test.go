@@ -0,0 +1,211 @@
+package proof
+
+import (
+	"github.com/dedis/kyber"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"testing"
+)
+
+// Returns a second generator H with unknown discrete log relative to G.
+func otherBase(suite schnorrgs.CryptoSuite) kyber.Point {
+	return suite.Point().Pick(suite.RandomStream())
+}
+
+// Proves and verifies pred, failing the test if either goes wrong.
+func proveAndVerify(t *testing.T, suite schnorrgs.CryptoSuite, pred Predicate,
+	public map[string]kyber.Point, secret map[string]kyber.Scalar) []byte {
+
+	proof, err := Prove(suite, pred, []byte("test"), public, secret)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	size, err := ProofSize(suite, pred)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(proof) != size {
+		t.Errorf("Proof of %s is %d bytes, expected %d", pred, len(proof), size)
+	}
+	ok, err := Verify(suite, pred, []byte("test"), public, proof)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !ok {
+		t.Errorf("Proof of %s did not verify", pred)
+	}
+	return proof
+}
+
+func TestProveKeyOwnership(t *testing.T) {
+
+	for _, name := range schnorrgs.RegisteredSuites() {
+		suite, _ := schnorrgs.GetSuite(name)
+		x := suite.Scalar().Pick(suite.RandomStream())
+		public := map[string]kyber.Point{
+			"G": suite.Point().Base(),
+			"X": suite.Point().Mul(x, nil),
+		}
+		secret := map[string]kyber.Scalar{"x": x}
+		pred := Rep("X", "x", "G")
+
+		proof := proveAndVerify(t, suite, pred, public, secret)
+
+		// a different context is a different statement.
+		ok, err := Verify(suite, pred, []byte("other"), public, proof)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if ok {
+			t.Errorf("%s: proof verified in the wrong context", name)
+		}
+	}
+}
+
+func TestProveDLEQ(t *testing.T) {
+
+	for _, name := range schnorrgs.RegisteredSuites() {
+		suite, _ := schnorrgs.GetSuite(name)
+		x := suite.Scalar().Pick(suite.RandomStream())
+		H := otherBase(suite)
+		public := map[string]kyber.Point{
+			"G": suite.Point().Base(),
+			"H": H,
+			"X": suite.Point().Mul(x, nil),
+			"Y": suite.Point().Mul(x, H),
+		}
+		pred := And(Rep("X", "x", "G"), Rep("Y", "x", "H"))
+
+		proveAndVerify(t, suite, pred, public, map[string]kyber.Scalar{"x": x})
+
+		// the logs differ, so no proof may exist.
+		public["Y"] = suite.Point().Mul(suite.Scalar().Pick(suite.RandomStream()), H)
+		_, err := Prove(suite, pred, []byte("test"), public,
+			map[string]kyber.Scalar{"x": x})
+		if err == nil {
+			t.Errorf("%s: proved equality of unequal logs", name)
+		}
+	}
+}
+
+func TestProveConjunction(t *testing.T) {
+
+	// PK{(x, y): X = xG & Y = xH + yG}
+	for _, name := range schnorrgs.RegisteredSuites() {
+		suite, _ := schnorrgs.GetSuite(name)
+		x := suite.Scalar().Pick(suite.RandomStream())
+		y := suite.Scalar().Pick(suite.RandomStream())
+		H := otherBase(suite)
+		public := map[string]kyber.Point{
+			"G": suite.Point().Base(),
+			"H": H,
+			"X": suite.Point().Mul(x, nil),
+			"Y": suite.Point().Add(suite.Point().Mul(x, H), suite.Point().Mul(y, nil)),
+		}
+		pred := And(Rep("X", "x", "G"), Rep("Y", "x", "H", "y", "G"))
+
+		proveAndVerify(t, suite, pred, public,
+			map[string]kyber.Scalar{"x": x, "y": y})
+	}
+}
+
+func TestProveDisjunction(t *testing.T) {
+
+	for _, name := range schnorrgs.RegisteredSuites() {
+		suite, _ := schnorrgs.GetSuite(name)
+		x := suite.Scalar().Pick(suite.RandomStream())
+		y := suite.Scalar().Pick(suite.RandomStream())
+		z := suite.Scalar().Pick(suite.RandomStream())
+		H := otherBase(suite)
+		public := map[string]kyber.Point{
+			"G": suite.Point().Base(),
+			"H": H,
+			"X": suite.Point().Mul(x, nil),
+			"Y": suite.Point().Mul(y, nil),
+			"Z": suite.Point().Mul(z, H),
+		}
+		// one of three keys, the last branch itself a conjunction.
+		pred := Or(Rep("X", "x", "G"), Rep("Y", "y", "G"),
+			And(Rep("Z", "z", "H"), Or(Rep("X", "x2", "G"), Rep("Y", "y2", "G"))))
+
+		proveAndVerify(t, suite, pred, public, map[string]kyber.Scalar{"x": x})
+		proveAndVerify(t, suite, pred, public, map[string]kyber.Scalar{"y": y})
+		proveAndVerify(t, suite, pred, public,
+			map[string]kyber.Scalar{"z": z, "y2": y})
+
+		_, err := Prove(suite, pred, []byte("test"), public,
+			map[string]kyber.Scalar{"z": z})
+		if err == nil {
+			t.Errorf("%s: proved a disjunction with no true branch", name)
+		}
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+
+	for _, name := range schnorrgs.RegisteredSuites() {
+		suite, _ := schnorrgs.GetSuite(name)
+		x := suite.Scalar().Pick(suite.RandomStream())
+		y := suite.Scalar().Pick(suite.RandomStream())
+		public := map[string]kyber.Point{
+			"G": suite.Point().Base(),
+			"X": suite.Point().Mul(x, nil),
+			"Y": suite.Point().Mul(y, nil),
+		}
+		pred := Or(Rep("X", "x", "G"), Rep("Y", "y", "G"))
+		proof := proveAndVerify(t, suite, pred, public,
+			map[string]kyber.Scalar{"x": x})
+
+		// flip one bit of every scalar in turn.
+		ssize := suite.Scalar().MarshalSize()
+		for i := 0; i < len(proof); i += ssize {
+			bad := append([]byte(nil), proof...)
+			bad[i+ssize/2] ^= 1
+			ok, _ := Verify(suite, pred, []byte("test"), public, bad)
+			if ok {
+				t.Errorf("%s: tampered scalar %d verified", name, i/ssize)
+			}
+		}
+
+		ok, err := Verify(suite, pred, []byte("test"), public, proof[1:])
+		if ok || err == nil {
+			t.Errorf("%s: truncated proof accepted", name)
+		}
+
+		// the same proof does not carry over to another statement or key.
+		ok, _ = Verify(suite, Or(Rep("Y", "y", "G"), Rep("X", "x", "G")),
+			[]byte("test"), public, proof)
+		if ok {
+			t.Errorf("%s: proof verified for a reordered statement", name)
+		}
+		public["X"] = suite.Point().Mul(suite.Scalar().Pick(suite.RandomStream()), nil)
+		ok, _ = Verify(suite, pred, []byte("test"), public, proof)
+		if ok {
+			t.Errorf("%s: proof verified for another key", name)
+		}
+	}
+}
+
+func TestInvalidStatements(t *testing.T) {
+
+	suite, _ := schnorrgs.GetSuite(schnorrgs.RegisteredSuites()[0])
+	public := map[string]kyber.Point{"G": suite.Point().Base(),
+		"X": suite.Point().Base()}
+	secret := map[string]kyber.Scalar{"x": suite.Scalar().One()}
+
+	for _, pred := range []Predicate{
+		Rep("X", "x"),
+		Rep("X"),
+		Rep("X", "x", "G*"),
+		And(),
+		Or(),
+		Rep("X", "x", "H"),
+		// a secret name belongs to one scope.
+		And(Rep("X", "x", "G"), Or(Rep("X", "x", "G"), Rep("G", "y", "G"))),
+		Or(Rep("X", "x", "G"), Rep("G", "x", "X")),
+	} {
+		_, err := Prove(suite, pred, nil, public, secret)
+		if err == nil {
+			t.Errorf("Statement %s accepted", pred)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package schnorrgs
+
+/* This file implements linkable spontaneous anonymous group (LSAG) ring
+   signatures, after Liu, Wei and Wong. Any holder of one of the keys
+   P_0 ... P_{n-1} can sign for the ring without the others taking part,
+   and the signature does not tell which key signed.
+
+   The signer with key x = x_j publishes the key image I = x H(P_j), where
+   H hashes onto the curve. Picking a, it starts the ring at j with
+
+       L_j = aG,  R_j = aH(P_j),  c_{j+1} = H(ring, I, M, L_j, R_j)
+
+   and goes round the other members with random s_i,
+
+       L_i = s_iG + c_iP_i,  R_i = s_iH(P_i) + c_iI,  c_{i+1} = H(...)
+
+   until it closes the ring with s_j = a - c_j x. The signature is
+   (c_0, s_0 ... s_{n-1}, I). The key image depends only on the signing
+   key, so two signatures carrying the same image were made by the same
+   member, in whichever rings.
+*/
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"github.com/dedis/kyber"
+)
+
+// Protocol tag of the ring challenges, and the hash to curve domain of
+// the key image base.
+const (
+	ringTag         = "schnorrgs-lsag-v1"
+	ringImageDomain = "schnorrgs-lsag-key-image-v1"
+)
+
+// Represents a ring signature. I is the key image.
+type SchnorrRingSignature struct {
+	C     kyber.Scalar
+	S     []kyber.Scalar
+	I     kyber.Point
+	suite string
+}
+
+// Returns the name of the suite this signature belongs to.
+func (sig SchnorrRingSignature) Suite() string {
+	return sig.suite
+}
+
+// Encodes a ring signature as I||c_0||s_0||...||s_{n-1}.
+func (sig SchnorrRingSignature) Encode() ([]byte, error) {
+
+	var b bytes.Buffer
+	_, err := sig.I.MarshalTo(&b)
+	if err != nil {
+		return nil, err
+	}
+	_, err = sig.C.MarshalTo(&b)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sig.S {
+		_, err = s.MarshalTo(&b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// Decodes a ring signature produced by Encode. The ring size follows from
+// the length.
+func DecodeSchnorrRingSignature(suite CryptoSuite,
+	b []byte) (SchnorrRingSignature, error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrRingSignature{}, err
+	}
+
+	// the image, c_0 and at least one s.
+	var point_size = suite.Point().MarshalSize()
+	var scalar_size = suite.Scalar().MarshalSize()
+	if len(b) < point_size+2*scalar_size ||
+		(len(b)-point_size)%scalar_size != 0 {
+		return SchnorrRingSignature{},
+			decodeError("ring signature", ErrDecodeLength)
+	}
+
+	d := newStrictDecoder(suite, "ring signature", b)
+	I := d.point()
+	C := d.scalar()
+	var S []kyber.Scalar
+	for k := 1; k < (len(b)-point_size)/scalar_size; k++ {
+		S = append(S, d.scalar())
+	}
+	err = d.finish()
+	if err != nil {
+		return SchnorrRingSignature{}, err
+	}
+
+	return SchnorrRingSignature{C: C, S: S, I: I, suite: name}, nil
+}
+
+// Exports a ring signature as a hex string, for storing in a file.
+func (sig SchnorrRingSignature) Export() (string, error) {
+	b, err := sig.Encode()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Imports a ring signature written by Export.
+func NewSchnorrRingSignatureFromString(suite CryptoSuite,
+	s string) (SchnorrRingSignature, error) {
+
+	b, err := hex.DecodeString(string(bytes.TrimSpace([]byte(s))))
+	if err != nil {
+		return SchnorrRingSignature{}, decodeError("ring signature", err)
+	}
+	return DecodeSchnorrRingSignature(suite, b)
+}
+
+// Returns the base H(P) of the key image of the key P.
+func ringImageBase(suite CryptoSuite, P kyber.Point) (kyber.Point, error) {
+	b, err := P.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return HashToPoint(suite, ringImageDomain, b)
+}
+
+// Computes the key image x H(P) of a key. It is the same in every ring
+// the key signs for.
+func SchnorrRingKeyImage(suite CryptoSuite,
+	kv SchnorrSecretKV) (kyber.Point, error) {
+
+	err := checkSuite(suite, kv.suite)
+	if err != nil {
+		return nil, err
+	}
+	H, err := ringImageBase(suite, kv.pP)
+	if err != nil {
+		return nil, err
+	}
+	return suite.Point().Mul(kv.s, H), nil
+}
+
+// Checks that the ring is usable and returns its points.
+func ringPoints(suite CryptoSuite,
+	ring []SchnorrPublicKV) ([]kyber.Point, error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return nil, err
+	}
+	if len(ring) < 1 {
+		return nil, errors.New("Empty ring.")
+	}
+	err = checkKeySuites(name, ring)
+	if err != nil {
+		return nil, err
+	}
+	var points []kyber.Point
+	for i, k := range ring {
+		for _, P := range points[:i] {
+			if P.Equal(k.pP) {
+				return nil, errors.New("Ring lists a key twice.")
+			}
+		}
+		points = append(points, k.pP)
+	}
+	return points, nil
+}
+
+// c = H(tag || ctx || I || ring || L || R || M)
+func ringChallenge(suite CryptoSuite, context []byte, ring []kyber.Point,
+	I kyber.Point, L kyber.Point, R kyber.Point,
+	msg []byte) (kyber.Scalar, error) {
+
+	points := append(append([]kyber.Point{}, ring...), L, R)
+	return schnorrHashChallenge(suite, ringTag, context, I, points, msg)
+}
+
+// Signs msg on behalf of the ring, in the given context. The key of kv
+// has to be one of the ring's.
+func SchnorrRingSign(suite CryptoSuite, kv SchnorrSecretKV,
+	ring []SchnorrPublicKV, context []byte,
+	msg []byte) (SchnorrRingSignature, error) {
+
+	err := checkSigningKey(suite, kv)
+	if err != nil {
+		return SchnorrRingSignature{}, err
+	}
+	points, err := ringPoints(suite, ring)
+	if err != nil {
+		return SchnorrRingSignature{}, err
+	}
+	n := len(points)
+	j := -1
+	for i, P := range points {
+		if P.Equal(kv.pP) {
+			j = i
+		}
+	}
+	if j < 0 {
+		return SchnorrRingSignature{}, errors.New("Key is not in the ring.")
+	}
+
+	var H []kyber.Point
+	for _, P := range points {
+		h, err := ringImageBase(suite, P)
+		if err != nil {
+			return SchnorrRingSignature{}, err
+		}
+		H = append(H, h)
+	}
+	I := suite.Point().Mul(kv.s, H[j])
+
+	rand := suite.RandomStream()
+	c := make([]kyber.Scalar, n)
+	s := make([]kyber.Scalar, n)
+
+	a := suite.Scalar().Pick(rand)
+	L := suite.Point().Mul(a, nil)
+	R := suite.Point().Mul(a, H[j])
+	for k := 1; k <= n; k++ {
+		i := (j + k) % n
+		c[i], err = ringChallenge(suite, context, points, I, L, R, msg)
+		if err != nil {
+			return SchnorrRingSignature{}, err
+		}
+		if i == j {
+			break
+		}
+		s[i] = suite.Scalar().Pick(rand)
+		L = suite.Point().Add(suite.Point().Mul(s[i], nil),
+			suite.Point().Mul(c[i], points[i]))
+		R = suite.Point().Add(suite.Point().Mul(s[i], H[i]),
+			suite.Point().Mul(c[i], I))
+	}
+	s[j] = suite.Scalar().Sub(a, suite.Scalar().Mul(c[j], kv.s)) // a - c x
+
+	return SchnorrRingSignature{C: c[0], S: s, I: I, suite: kv.suite}, nil
+}
+
+// Checks a ring signature on msg in the given context. The ring has to
+// list the keys in the order they were signed with.
+func SchnorrRingVerify(suite CryptoSuite, ring []SchnorrPublicKV,
+	context []byte, msg []byte, sig SchnorrRingSignature) (bool, error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return false, err
+	}
+	if sig.suite != name {
+		return false, errors.New("Signature suite " + sig.suite +
+			" does not match suite " + name)
+	}
+	points, err := ringPoints(suite, ring)
+	if err != nil {
+		return false, err
+	}
+	if len(sig.S) != len(points) {
+		return false, errors.New("Signature is for a ring of another size.")
+	}
+
+	// an image with a small order component would let one key make
+	// images that do not link.
+	if sig.I.Equal(suite.Point().Null()) {
+		return false, nil
+	}
+	if !inPrimeOrderSubgroup(suite, sig.I) {
+		return false, nil
+	}
+
+	c := sig.C
+	for i, P := range points {
+		H, err := ringImageBase(suite, P)
+		if err != nil {
+			return false, err
+		}
+		L := suite.Point().Add(suite.Point().Mul(sig.S[i], nil),
+			suite.Point().Mul(c, P))
+		R := suite.Point().Add(suite.Point().Mul(sig.S[i], H),
+			suite.Point().Mul(c, sig.I))
+		c, err = ringChallenge(suite, context, points, sig.I, L, R, msg)
+		if err != nil {
+			return false, err
+		}
+	}
+	return c.Equal(sig.C), nil
+}
+
+// Tells whether two ring signatures were made with the same key. Only
+// meaningful for signatures that verify.
+func SchnorrRingLinked(a SchnorrRingSignature, b SchnorrRingSignature) bool {
+	return a.suite == b.suite && a.I.Equal(b.I)
+}
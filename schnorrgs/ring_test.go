@@ -0,0 +1,154 @@
+package schnorrgs
+
+import (
+	"testing"
+)
+
+// Generates n keys and returns them with the ring of their public keys.
+func makeRing(t *testing.T, suite CryptoSuite, n int) ([]SchnorrSecretKV,
+	[]SchnorrPublicKV) {
+
+	var kvs []SchnorrSecretKV
+	var ring []SchnorrPublicKV
+	for i := 0; i < n; i++ {
+		kv, err := SchnorrGenerateKeypair(suite)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		kvs = append(kvs, kv)
+		ring = append(ring, kv.GetPublicKeyset())
+	}
+	return kvs, ring
+}
+
+func TestSchnorrRingSignature(t *testing.T) {
+
+	msg := []byte("the group approves")
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		for _, n := range []int{1, 2, 5} {
+			kvs, ring := makeRing(t, suite, n)
+			for j, kv := range kvs {
+				sig, err := SchnorrRingSign(suite, kv, ring, []byte("vote"), msg)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+
+				s, err := sig.Export()
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				sig, err = NewSchnorrRingSignatureFromString(suite, s)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+
+				ok, err := SchnorrRingVerify(suite, ring, []byte("vote"), msg, sig)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				if !ok {
+					t.Errorf("%s: ring of %d, member %d: signature did not verify",
+						name, n, j)
+				}
+
+				ok, _ = SchnorrRingVerify(suite, ring, []byte("vote"),
+					[]byte("the group objects"), sig)
+				if ok {
+					t.Errorf("%s: signature verified on another message", name)
+				}
+				ok, _ = SchnorrRingVerify(suite, ring, nil, msg, sig)
+				if ok {
+					t.Errorf("%s: signature verified in another context", name)
+				}
+
+				image, err := SchnorrRingKeyImage(suite, kv)
+				if err != nil {
+					t.Fatal(err.Error())
+				}
+				if !image.Equal(sig.I) {
+					t.Errorf("%s: signature carries the wrong key image", name)
+				}
+			}
+		}
+	}
+}
+
+func TestSchnorrRingLinkability(t *testing.T) {
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kvs, ring := makeRing(t, suite, 4)
+		_, other := makeRing(t, suite, 3)
+		other = append(other, kvs[1].GetPublicKeyset())
+
+		sig1, err := SchnorrRingSign(suite, kvs[1], ring, nil, []byte("one"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		// the same key in another ring still links.
+		sig2, err := SchnorrRingSign(suite, kvs[1], other, nil, []byte("two"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		sig3, err := SchnorrRingSign(suite, kvs[2], ring, nil, []byte("one"))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if !SchnorrRingLinked(sig1, sig2) {
+			t.Errorf("%s: signatures by one key did not link", name)
+		}
+		if SchnorrRingLinked(sig1, sig3) {
+			t.Errorf("%s: signatures by different keys linked", name)
+		}
+	}
+}
+
+func TestSchnorrRingRejects(t *testing.T) {
+
+	msg := []byte("the group approves")
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+		kvs, ring := makeRing(t, suite, 3)
+		outsider, _ := SchnorrGenerateKeypair(suite)
+
+		_, err := SchnorrRingSign(suite, outsider, ring, nil, msg)
+		if err == nil {
+			t.Errorf("%s: signed for a ring without our key", name)
+		}
+		_, err = SchnorrRingSign(suite, kvs[0], append(ring, ring[1]), nil, msg)
+		if err == nil {
+			t.Errorf("%s: signed for a ring listing a key twice", name)
+		}
+
+		sig, _ := SchnorrRingSign(suite, kvs[0], ring, nil, msg)
+
+		// another ring, or the same keys in another order.
+		swapped := []SchnorrPublicKV{ring[1], ring[0], ring[2]}
+		ok, _ := SchnorrRingVerify(suite, swapped, nil, msg, sig)
+		if ok {
+			t.Errorf("%s: signature verified for a reordered ring", name)
+		}
+		ok, _ = SchnorrRingVerify(suite, ring[:2], nil, msg, sig)
+		if ok {
+			t.Errorf("%s: signature verified for a smaller ring", name)
+		}
+
+		// a key image taken from another member breaks the signature.
+		forged := sig
+		forged.I, _ = SchnorrRingKeyImage(suite, kvs[1])
+		ok, _ = SchnorrRingVerify(suite, ring, nil, msg, forged)
+		if ok {
+			t.Errorf("%s: signature verified with another key image", name)
+		}
+
+		tampered := sig
+		tampered.S = append(tampered.S[:0:0], sig.S...)
+		tampered.S[2] = suite.Scalar().Add(sig.S[2], suite.Scalar().One())
+		ok, _ = SchnorrRingVerify(suite, ring, nil, msg, tampered)
+		if ok {
+			t.Errorf("%s: tampered signature verified", name)
+		}
+	}
+}
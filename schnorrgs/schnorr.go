@@ -12,13 +12,26 @@ package schnorrgs
 
 import (
 	"bytes"
+	"errors"
 	"github.com/dedis/kyber"
 )
 
-// Represents a Schnorr signature.
+// Represents a Schnorr signature. Like the keys, a signature remembers
+// which suite it was made in so it cannot be checked against a key
+// from another suite.
+// R is the commitment g^k. It is optional for (s, e) signatures, but
+// signatures decoded from the (R, s) encoding have R and no E until they
+// are verified against a message.
 type SchnorrSignature struct {
-	S kyber.Scalar
-	E kyber.Scalar
+	S     kyber.Scalar
+	E     kyber.Scalar
+	R     kyber.Point
+	suite string
+}
+
+// Returns the name of the suite this signature belongs to.
+func (sig SchnorrSignature) Suite() string {
+	return sig.suite
 }
 
 // Encode produces a byte array from a signature structure
@@ -26,6 +39,10 @@ type SchnorrSignature struct {
 // with go.
 func (sig SchnorrSignature) Encode() ([]byte, error) {
 
+	if sig.E == nil {
+		return nil, errors.New("Signature has no challenge, use EncodeRS.")
+	}
+
 	var b bytes.Buffer
 	_, err := sig.S.MarshalTo(&b)
 	if err != nil {
@@ -40,47 +57,133 @@ func (sig SchnorrSignature) Encode() ([]byte, error) {
 	return b.Bytes(), nil
 }
 
+// EncodeRS produces the (R, s) encoding of a signature, i.e. R||s.
+// Unlike (s, e) signatures, these can be verified in batches.
+func (sig SchnorrSignature) EncodeRS() ([]byte, error) {
+
+	if sig.R == nil {
+		return nil, errors.New("Signature has no commitment R.")
+	}
+
+	var b bytes.Buffer
+	_, err := sig.R.MarshalTo(&b)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = sig.S.MarshalTo(&b)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// Decodes a signature produced by EncodeRS. The challenge E is left
+// empty as it depends on the message; SchnorrVerify recomputes it.
+func DecodeSchnorrSignatureRS(suite CryptoSuite, sig []byte) (SchnorrSignature,
+	error) {
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+
+	d := newStrictDecoder(suite, "(R, s) signature", sig)
+	R := d.point()
+	S := d.scalar()
+	err = d.finish()
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+
+	return SchnorrSignature{S: S, R: R, suite: name}, nil
+}
+
 // This method takes a binary string and appropriate suite and
-// attempts to decode a signature from it.
+// attempts to decode a signature from it. The string has to be exactly
+// s||e, both canonical.
 func DecodeSchnorrSignature(suite CryptoSuite, sig []byte) (SchnorrSignature,
 	error) {
 
-	var S = suite.Scalar()
-	var E = suite.Scalar()
-	var scalar_size = suite.Scalar().MarshalSize()
-
-	err := S.UnmarshalBinary(sig[:scalar_size])
+	name, err := SuiteName(suite)
 	if err != nil {
 		return SchnorrSignature{}, err
 	}
 
-	err = E.UnmarshalBinary(sig[scalar_size:])
+	d := newStrictDecoder(suite, "signature", sig)
+	S := d.scalar()
+	E := d.scalar()
+	err = d.finish()
 	if err != nil {
 		return SchnorrSignature{}, err
 	}
 
-	return SchnorrSignature{S: S, E: E}, nil
+	return SchnorrSignature{S: S, E: E, suite: name}, nil
 }
 
 // Signs a given message and returns the signature.
 // If no signature is possible due to an error
 // returns the error in the second retval.
+// The nonce comes straight from the suite's random stream; see
+// SchnorrSignWithMode for nonces that survive a bad RNG.
 func SchnorrSign(suite CryptoSuite,
 	kv SchnorrSecretKV,
 	msg []byte) (SchnorrSignature, error) {
 
-	k := suite.Scalar().Pick(suite.RandomStream()) // some k
-	R := suite.Point().Mul(k, nil)                 // r = g^k
+	return SchnorrSignWithMode(suite, kv, msg, NonceRandom)
+}
+
+// Signs a message using the given nonce mode.
+func SchnorrSignWithMode(suite CryptoSuite,
+	kv SchnorrSecretKV,
+	msg []byte, mode SchnorrNonceMode) (SchnorrSignature, error) {
+
+	return schnorrSignTagged(suite, kv, signatureTag, nil, msg, mode)
+}
+
+// Signs a message in the given context, such as "notary-v1". The
+// signature only verifies with SchnorrVerifyWithContext and the same
+// context.
+func SchnorrSignWithContext(suite CryptoSuite,
+	kv SchnorrSecretKV, context []byte,
+	msg []byte, mode SchnorrNonceMode) (SchnorrSignature, error) {
 
-	// e = H(r||M)
-	e, err := SchnorrHashPointsMsgToScalar(suite, R, msg)
+	return schnorrSignTagged(suite, kv, signatureTag, context, msg, mode)
+}
+
+// Signs a message for the protocol named by tag, in the given context.
+func schnorrSignTagged(suite CryptoSuite, kv SchnorrSecretKV, tag string,
+	context []byte, msg []byte,
+	mode SchnorrNonceMode) (SchnorrSignature, error) {
+
+	err := checkSigningKey(suite, kv)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+
+	// the nonce has to depend on the domain too, or the same message
+	// signed in two contexts would reuse k with two challenges.
+	domain, err := challengeDomain(tag, context)
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+	k, err := schnorrDeriveNonce(suite, kv, append(domain, msg...), mode) // some k
+	if err != nil {
+		return SchnorrSignature{}, err
+	}
+	R := suite.Point().Mul(k, nil) // r = g^k
+
+	// e = H(tag||ctx||Y||r||M)
+	e, err := schnorrHashChallenge(suite, tag, context, kv.pP,
+		[]kyber.Point{R}, msg)
 	if err != nil {
 		return SchnorrSignature{}, err
 	}
 	s := suite.Scalar().Zero()
 	s.Mul(kv.s, e).Sub(k, s) // k - xe
 
-	sig := SchnorrSignature{S: s, E: e}
+	sig := SchnorrSignature{S: s, E: e, R: R, suite: kv.suite}
 
 	return sig, nil
 }
@@ -91,7 +194,15 @@ func SchnorrSignBinary(suite CryptoSuite,
 	kv SchnorrSecretKV,
 	msg []byte) ([]byte, error) {
 
-	sig, err := SchnorrSign(suite, kv, msg)
+	return SchnorrSignBinaryWithMode(suite, kv, msg, NonceRandom)
+}
+
+// SchnorrSignBinary with a choice of nonce mode.
+func SchnorrSignBinaryWithMode(suite CryptoSuite,
+	kv SchnorrSecretKV,
+	msg []byte, mode SchnorrNonceMode) ([]byte, error) {
+
+	sig, err := SchnorrSignWithMode(suite, kv, msg, mode)
 	if err != nil {
 		return nil, err
 	}
@@ -105,16 +216,63 @@ func SchnorrVerify(suite CryptoSuite,
 	kp SchnorrPublicKV,
 	msg []byte, signature SchnorrSignature) (bool, error) {
 
+	return schnorrVerifyTagged(suite, kp, signatureTag, nil, msg, signature)
+}
+
+// Checks a signature made by SchnorrSignWithContext in the given context.
+func SchnorrVerifyWithContext(suite CryptoSuite,
+	kp SchnorrPublicKV, context []byte,
+	msg []byte, signature SchnorrSignature) (bool, error) {
+
+	return schnorrVerifyTagged(suite, kp, signatureTag, context, msg,
+		signature)
+}
+
+// Checks a signature made for the protocol named by tag, in the given
+// context.
+func schnorrVerifyTagged(suite CryptoSuite, kp SchnorrPublicKV, tag string,
+	context []byte, msg []byte, signature SchnorrSignature) (bool, error) {
+
+	err := checkSuite(suite, kp.suite)
+	if err != nil {
+		return false, err
+	}
+	if signature.suite != kp.suite {
+		return false, errors.New("Signature suite " + signature.suite +
+			" does not match key suite " + kp.suite)
+	}
+
+	// (R, s) form: recompute e = H(tag||ctx||Y||R||M) and check
+	// R = sG + eY.
+	if signature.E == nil {
+		if signature.R == nil {
+			return false, errors.New("Signature has neither E nor R.")
+		}
+		e, err := schnorrHashChallenge(suite, tag, context, kp.pP,
+			[]kyber.Point{signature.R}, msg)
+		if err != nil {
+			return false, err
+		}
+		sG := suite.Point().Mul(signature.S, nil)
+		eY := suite.Point().Mul(e, kp.pP)
+		return suite.Point().Add(sG, eY).Equal(signature.R), nil
+	}
+
 	var sG, eY, R kyber.Point
 	sG = suite.Point().Mul(signature.S, nil)   // sG
 	eY = suite.Point().Mul(signature.E, kp.pP) // eY
 	R = suite.Point().Add(sG, eY)              // sG +eY
 
-	ev, err := SchnorrHashPointsMsgToScalar(suite, R, msg)
+	ev, err := schnorrHashChallenge(suite, tag, context, kp.pP,
+		[]kyber.Point{R}, msg)
 	if err != nil {
 		return false, err
 	}
 
+	if signature.R != nil && !signature.R.Equal(R) {
+		return false, nil
+	}
+
 	return ev.Equal(signature.E), nil
 }
 
@@ -143,8 +301,13 @@ func SchnorrVerifyBinary(suite CryptoSuite,
 // interface.
 func SchnorrGenerateKeypair(suite CryptoSuite) (SchnorrSecretKV, error) {
 
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrSecretKV{}, err
+	}
+
 	x := suite.Scalar().Pick(suite.RandomStream()) // some x
 	y := suite.Point().Mul(x, nil)                 // y = g^x \in G, DLP.
 
-	return SchnorrSecretKV{suite: "BlakeSHA256Ed25519", s: x, pP: y}, nil
+	return SchnorrSecretKV{suite: name, s: x, pP: y}, nil
 }
@@ -6,6 +6,7 @@ package schnorrgs
 
 import (
 	"github.com/dedis/kyber/group/edwards25519"
+	"github.com/dedis/kyber/group/nist"
 	"testing"
 )
 
@@ -96,3 +97,73 @@ func TestMarshalling(t *testing.T) {
 		}
 	}
 }
+
+func TestSchnorrSignatureP256(t *testing.T) {
+
+	suite := nist.NewBlakeSHA256P256()
+	kv, err := SchnorrGenerateKeypair(suite)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if kv.Suite() != "BlakeSHA256P256" {
+		t.Errorf("P256 key labelled as %s", kv.Suite())
+	}
+
+	pk := kv.GetPublicKeyset()
+	message := []byte("This is a test")
+
+	sig, err := SchnorrSignBinary(suite, kv, message)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	v, err := SchnorrVerifyBinary(suite, pk, message, sig)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if v == false {
+		t.Error("Verification of P256 signature failed")
+	}
+}
+
+func TestSchnorrSuiteMismatch(t *testing.T) {
+
+	ed := edwards25519.NewBlakeSHA256Ed25519()
+	p256 := nist.NewBlakeSHA256P256()
+
+	kv, err := SchnorrGenerateKeypair(p256)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	message := []byte("This is a test")
+
+	_, err = SchnorrSign(ed, kv, message)
+	if err == nil {
+		t.Error("Signed with a P256 key in the Ed25519 suite.")
+	}
+
+	sig, err := SchnorrSign(p256, kv, message)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	_, err = SchnorrVerify(ed, kv.GetPublicKeyset(), message, sig)
+	if err == nil {
+		t.Error("Verified a P256 key in the Ed25519 suite.")
+	}
+
+	edkv, err := SchnorrGenerateKeypair(ed)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, err = SchnorrVerify(ed, edkv.GetPublicKeyset(), message, sig)
+	if err == nil {
+		t.Error("Verified a P256 signature against an Ed25519 key.")
+	}
+
+	_, err = SchnorrMSComputeSharedPublicKey(ed,
+		[]SchnorrPublicKV{edkv.GetPublicKeyset(), kv.GetPublicKeyset()})
+	if err == nil {
+		t.Error("Combined keys from two different suites.")
+	}
+}
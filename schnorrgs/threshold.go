@@ -0,0 +1,224 @@
+package schnorrgs
+
+/* This file implements t-of-n threshold Schnorr signatures.
+
+   The group secret x is Shamir-shared: member i holds x_i = f(i) for a
+   random polynomial f of degree t-1 with f(0) = x, and publishes the share
+   key X_i = x_i G. Any t members can sign together:
+
+       each signer i commits to T_i = v_i G,
+       c = H(sum T_i || M),
+       each signer i answers r_i = v_i - c l_i x_i,
+
+   where l_i is the Lagrange coefficient of i for the set of signers, so
+   that sum l_i x_i = x. The combined response sum r_i and c make an
+   ordinary signature under the group key Y = xG, so commitments, challenge
+   and signature reuse the multisignature functions.
+
+   Share indices are the points the polynomial is evaluated at, so they
+   start at 1: index i holds kyber's share.PriShare with I = i-1.
+*/
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/dedis/kyber"
+	"github.com/dedis/kyber/share"
+	"strconv"
+)
+
+// Splits a fresh key into n shares, any t of which can sign for it. The
+// dealer sees the whole key, so this is only for groups that trust
+// whoever runs it. Returns the group key and the shares; shares[i] has
+// share index i+1.
+func SchnorrTSDealShares(suite CryptoSuite, t int,
+	n int) (SchnorrPublicKV, []SchnorrSecretKV, error) {
+
+	if t < 1 || t > n {
+		return SchnorrPublicKV{}, nil, errors.New("Threshold must be " +
+			"between 1 and the number of shares.")
+	}
+
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrPublicKV{}, nil, err
+	}
+
+	poly := share.NewPriPoly(suite, t, nil, suite.RandomStream())
+	var shares []SchnorrSecretKV
+	for _, s := range poly.Shares(n) {
+		P := suite.Point().Mul(s.V, nil)
+		shares = append(shares, SchnorrSecretKV{s: s.V, pP: P, suite: name})
+	}
+
+	Y := suite.Point().Mul(poly.Secret(), nil)
+	return SchnorrPublicKV{suite: name, pP: Y}, shares, nil
+}
+
+// Checks a set of share indices: all positive and no repeats.
+func checkShareIndices(indices []int) error {
+	seen := make(map[int]bool)
+	for _, i := range indices {
+		if i < 1 {
+			return errors.New("Invalid share index " + strconv.Itoa(i))
+		}
+		if seen[i] {
+			return errors.New("Repeated share index " + strconv.Itoa(i))
+		}
+		seen[i] = true
+	}
+	return nil
+}
+
+// Computes the Lagrange coefficient of index for interpolating at x over
+// the given set of indices, i.e. prod (x - j) / (index - j) over j != index.
+func lagrangeCoefficientAt(suite CryptoSuite, index int, indices []int,
+	x int) (kyber.Scalar, error) {
+
+	err := checkShareIndices(indices)
+	if err != nil {
+		return nil, err
+	}
+
+	num := suite.Scalar().One()
+	den := suite.Scalar().One()
+	found := false
+	xi := suite.Scalar().SetInt64(int64(index))
+	xs := suite.Scalar().SetInt64(int64(x))
+
+	for _, j := range indices {
+		if j == index {
+			found = true
+			continue
+		}
+		xj := suite.Scalar().SetInt64(int64(j))
+		num.Mul(num, suite.Scalar().Sub(xs, xj))
+		den.Mul(den, suite.Scalar().Sub(xi, xj))
+	}
+	if !found {
+		return nil, errors.New("Share index " + strconv.Itoa(index) +
+			" is not among the signers.")
+	}
+	return suite.Scalar().Div(num, den), nil
+}
+
+// Computes the Lagrange coefficient of the signer with share index index
+// among the given signers, for interpolating the secret at 0.
+func SchnorrTSLagrangeCoefficient(suite CryptoSuite, index int,
+	signers []int) (kyber.Scalar, error) {
+
+	return lagrangeCoefficientAt(suite, index, signers, 0)
+}
+
+// Interpolates the share key at share index x from the share keys at the
+// given indices; x = 0 gives the group key.
+func SchnorrTSInterpolatePublicKey(suite CryptoSuite, indices []int,
+	keys []SchnorrPublicKV, x int) (SchnorrPublicKV, error) {
+
+	if len(indices) != len(keys) || len(keys) == 0 {
+		return SchnorrPublicKV{}, errors.New("Need one share index per key.")
+	}
+	name, err := SuiteName(suite)
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+	err = checkKeySuites(name, keys)
+	if err != nil {
+		return SchnorrPublicKV{}, err
+	}
+
+	Y := suite.Point().Null()
+	for k, i := range indices {
+		l, err := lagrangeCoefficientAt(suite, i, indices, x)
+		if err != nil {
+			return SchnorrPublicKV{}, err
+		}
+		Y.Add(Y, suite.Point().Mul(l, keys[k].pP))
+	}
+	return SchnorrPublicKV{suite: name, pP: Y}, nil
+}
+
+// Computes our response as the signer with share index index, holding
+// share, among the given signers: r = v - c l x_i.
+func SchnorrTSComputeResponse(suite CryptoSuite, c kyber.Scalar, index int,
+	signers []int, sharekv SchnorrSecretKV,
+	privatecommit SchnorrMSCommitment) (kyber.Scalar, error) {
+
+	err := checkSigningKey(suite, sharekv)
+	if err != nil {
+		return nil, err
+	}
+	l, err := SchnorrTSLagrangeCoefficient(suite, index, signers)
+	if err != nil {
+		return nil, err
+	}
+
+	lx := suite.Scalar().Mul(l, sharekv.s)
+	clx := suite.Scalar().Mul(c, lx)
+	return suite.Scalar().Sub(privatecommit.v, clx), nil
+}
+
+// Checks one signer's response against its commitment and share key, so
+// that a client can tell which signer spoilt a signature: rG + c l X_i
+// must equal T_i.
+func SchnorrTSVerifyResponse(suite CryptoSuite, c kyber.Scalar, index int,
+	signers []int, sharekey SchnorrPublicKV,
+	commit SchnorrMSPublicCommitment, response kyber.Scalar) (bool, error) {
+
+	err := checkSuite(suite, sharekey.suite)
+	if err != nil {
+		return false, err
+	}
+	l, err := SchnorrTSLagrangeCoefficient(suite, index, signers)
+	if err != nil {
+		return false, err
+	}
+
+	rG := suite.Point().Mul(response, nil)
+	clX := suite.Point().Mul(suite.Scalar().Mul(c, l), sharekey.pP)
+	return suite.Point().Add(rG, clX).Equal(commit.T), nil
+}
+
+// Encodes a list of signer share indices for the wire: a 16-bit
+// big-endian count followed by 16-bit big-endian indices.
+func SchnorrTSEncodeSigners(signers []int) ([]byte, error) {
+	err := checkShareIndices(signers)
+	if err != nil {
+		return nil, err
+	}
+	if len(signers) > 0xffff {
+		return nil, errors.New("Too many signers.")
+	}
+
+	b := make([]byte, 2+2*len(signers))
+	binary.BigEndian.PutUint16(b, uint16(len(signers)))
+	for k, i := range signers {
+		if i > 0xffff {
+			return nil, errors.New("Share index too large.")
+		}
+		binary.BigEndian.PutUint16(b[2+2*k:], uint16(i))
+	}
+	return b, nil
+}
+
+// Decodes a list of signers encoded by SchnorrTSEncodeSigners, with
+// nothing after it.
+func SchnorrTSDecodeSigners(b []byte) ([]int, error) {
+	if len(b) < 2 {
+		return nil, decodeError("signer list", ErrDecodeLength)
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) != 2+2*n {
+		return nil, decodeError("signer list", ErrDecodeLength)
+	}
+
+	signers := make([]int, n)
+	for k := range signers {
+		signers[k] = int(binary.BigEndian.Uint16(b[2+2*k:]))
+	}
+	err := checkShareIndices(signers)
+	if err != nil {
+		return nil, err
+	}
+	return signers, nil
+}
@@ -0,0 +1,165 @@
+package schnorrgs
+
+import (
+	"github.com/dedis/kyber"
+	"testing"
+)
+
+// Runs the threshold protocol between the given signers (share indices)
+// and returns the signature.
+func thresholdSign(t *testing.T, suite CryptoSuite, groupkey SchnorrPublicKV,
+	shares []SchnorrSecretKV, signers []int, message []byte) SchnorrSignature {
+
+	var commits []SchnorrMSCommitment
+	var pcommits []SchnorrMSPublicCommitment
+	for _, i := range signers {
+		commit, err := SchnorrMSGenerateCommitmentHedged(suite, shares[i-1],
+			message)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		commits = append(commits, commit)
+		pcommits = append(pcommits, commit.GetPublicCommitment())
+	}
+
+	agg := SchnorrMSAggregateCommitment(suite, pcommits)
+	c, err := SchnorrMSComputeCollectiveChallenge(suite, nil, groupkey, agg,
+		message)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var responses []kyber.Scalar
+	for k, i := range signers {
+		r, err := SchnorrTSComputeResponse(suite, c, i, signers, shares[i-1],
+			commits[k])
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		v, err := SchnorrTSVerifyResponse(suite, c, i, signers,
+			shares[i-1].GetPublicKeyset(), pcommits[k], r)
+		if err != nil || !v {
+			t.Errorf("Response of signer %d did not verify", i)
+		}
+		responses = append(responses, r)
+	}
+
+	sig, err := SchnorrMSCreateSignature(suite, c,
+		SchnorrMSComputeCombinedResponse(suite, responses))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return sig
+}
+
+func TestThresholdSignature(t *testing.T) {
+
+	message := []byte("This is a test")
+
+	for _, name := range RegisteredSuites() {
+		suite, _ := GetSuite(name)
+
+		groupkey, shares, err := SchnorrTSDealShares(suite, 3, 5)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		for _, signers := range [][]int{{1, 2, 3}, {5, 3, 1}, {2, 4, 5},
+			{1, 2, 3, 4, 5}} {
+			sig := thresholdSign(t, suite, groupkey, shares, signers, message)
+			v, err := SchnorrVerify(suite, groupkey, message, sig)
+			if err != nil || !v {
+				t.Errorf("%s: signature by %v did not verify", name, signers)
+			}
+		}
+
+		// two shares are not enough.
+		sig := thresholdSign(t, suite, groupkey, shares, []int{1, 2}, message)
+		v, _ := SchnorrVerify(suite, groupkey, message, sig)
+		if v {
+			t.Errorf("%s: two of three shares made a valid signature", name)
+		}
+
+		var keys []SchnorrPublicKV
+		for _, s := range shares {
+			keys = append(keys, s.GetPublicKeyset())
+		}
+		Y, err := SchnorrTSInterpolatePublicKey(suite, []int{2, 3, 5},
+			[]SchnorrPublicKV{keys[1], keys[2], keys[4]}, 0)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if !Y.pP.Equal(groupkey.pP) {
+			t.Errorf("%s: share keys did not interpolate to the group key", name)
+		}
+	}
+}
+
+func TestThresholdBadResponse(t *testing.T) {
+
+	suite, _ := GetSuite(DefaultSuite)
+
+	_, shares, err := SchnorrTSDealShares(suite, 2, 3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	signers := []int{1, 3}
+
+	commit := SchnorrMSGenerateCommitment(suite)
+	pcommit := commit.GetPublicCommitment()
+	c := suite.Scalar().Pick(suite.RandomStream())
+
+	// answering with the wrong share must be caught.
+	r, err := SchnorrTSComputeResponse(suite, c, 3, signers, shares[1], commit)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	v, _ := SchnorrTSVerifyResponse(suite, c, 3, signers,
+		shares[2].GetPublicKeyset(), pcommit, r)
+	if v {
+		t.Error("Accepted a response made with the wrong share")
+	}
+
+	_, err = SchnorrTSComputeResponse(suite, c, 2, signers, shares[1], commit)
+	if err == nil {
+		t.Error("Answered as a signer outside the signer set")
+	}
+	_, err = SchnorrTSLagrangeCoefficient(suite, 1, []int{1, 1})
+	if err == nil {
+		t.Error("Accepted a repeated signer")
+	}
+	_, _, err = SchnorrTSDealShares(suite, 4, 3)
+	if err == nil {
+		t.Error("Dealt shares with a threshold above n")
+	}
+}
+
+func TestThresholdSignerEncoding(t *testing.T) {
+
+	signers := []int{7, 1, 300}
+	b, err := SchnorrTSEncodeSigners(signers)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	decoded, err := SchnorrTSDecodeSigners(b)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(decoded) != len(signers) {
+		t.Fatalf("Decoded %v, expected %v", decoded, signers)
+	}
+	for k := range signers {
+		if decoded[k] != signers[k] {
+			t.Errorf("Decoded %v, expected %v", decoded, signers)
+		}
+	}
+
+	_, err = SchnorrTSDecodeSigners(b[:len(b)-1])
+	if err == nil {
+		t.Error("Decoded a truncated signer list")
+	}
+	_, err = SchnorrTSDecodeSigners(append(b, 0, 0))
+	if err == nil {
+		t.Error("Decoded a signer list with trailing bytes")
+	}
+}
@@ -0,0 +1,93 @@
+package schnorrgs
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestVectorsFile(t *testing.T) {
+
+	vectors, err := SchnorrLoadTestVectors("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	err = CheckTestVectors(vectors)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// every scheme in every suite is covered.
+	covered := make(map[string]bool)
+	for _, v := range vectors.Vectors {
+		covered[v.Scheme+" "+v.Suite] = true
+	}
+	for _, name := range RegisteredSuites() {
+		for _, scheme := range []string{VectorSchnorr, VectorMuSig, VectorBlind} {
+			if !covered[scheme+" "+name] {
+				t.Errorf("No %s vector for %s", scheme, name)
+			}
+		}
+	}
+}
+
+func TestVectorsVerify(t *testing.T) {
+
+	// the vectors have to verify on their own, not only regenerate.
+	vectors, err := SchnorrLoadTestVectors("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, v := range vectors.Vectors {
+		if v.Scheme != VectorSchnorr {
+			continue
+		}
+		suite, _ := GetSuite(v.Suite)
+		pk, err := NewSchnorrPublicKeyFromString(v.Suite + ";" +
+			v.Values["PublicKey"])
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		msg, _ := hex.DecodeString(v.Message)
+		b, _ := hex.DecodeString(v.Values["SignatureRS"])
+		sig, err := DecodeSchnorrSignatureRS(suite, b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		ok, err := SchnorrVerifyWithContext(suite, *pk, []byte(v.Context),
+			msg, sig)
+		if err != nil || !ok {
+			t.Errorf("%s vector signature does not verify", v.Suite)
+		}
+	}
+}
+
+func TestVectorsDetectChanges(t *testing.T) {
+
+	v, err := GenerateTestVector(VectorMuSig, DefaultSuite, []byte("seed"),
+		[]byte("message"), []byte("ctx"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if CheckTestVector(v) != nil {
+		t.Fatal("Fresh vector does not check")
+	}
+
+	changed := v
+	changed.Values = make(map[string]string)
+	for name, value := range v.Values {
+		changed.Values[name] = value
+	}
+	changed.Values["Challenge"] = changed.Values["Response0"]
+	if CheckTestVector(changed) == nil {
+		t.Error("Changed value passed")
+	}
+	delete(changed.Values, "Challenge")
+	if CheckTestVector(changed) == nil {
+		t.Error("Missing value passed")
+	}
+	changed = v
+	changed.Seed = hex.EncodeToString([]byte("other seed"))
+	if CheckTestVector(changed) == nil {
+		t.Error("Vector of another seed passed")
+	}
+}
@@ -2,34 +2,14 @@ package main
 
 import (
 	"crypto/rand"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/dedis/kyber"
 	"github.com/diagprov/dedischallenge/schnorrgs"
-	"io/ioutil"
 	"net"
 	"os"
 )
 
-type SchnorrMMember struct {
-	HostName string
-	Port     int
-	PKey     string
-}
-
-func (m SchnorrMMember) GetPKeyAsKV() (*schnorrgs.SchnorrPublicKV, error) {
-	return schnorrgs.NewSchnorrPublicKeyFromString(m.PKey)
-}
-
-type SchnorrMGroupConfig struct {
-	JointKey string
-	Members  []SchnorrMMember
-}
-
-func (m SchnorrMGroupConfig) GetJointKeyAsKV() (*schnorrgs.SchnorrPublicKV, error) {
-	return schnorrgs.NewSchnorrPublicKeyFromString(m.JointKey)
-}
-
 const (
 	MESSAGE    byte = 1
 	COMMITMENT byte = 2
@@ -39,9 +19,10 @@ type controllerMessage struct {
 	MemberIndex int
 	Message     []byte // if we don't keep this generic type enforcement
 	// will stop us using a single channel.
+	// A nil Message means we lost the member.
 }
 
-func serverComms(gconfig SchnorrMGroupConfig, i int, msg []byte, reportChan chan controllerMessage, syncChan chan []byte) {
+func serverComms(gconfig schnorrgs.SchnorrMGroupConfig, i int, msg []byte, reportChan chan controllerMessage, syncChan chan []byte) {
 
 	config := gconfig.Members[i]
 
@@ -55,24 +36,27 @@ func serverComms(gconfig SchnorrMGroupConfig, i int, msg []byte, reportChan chan
 	conn, err := net.Dial("tcp", hostspec)
 	if err != nil {
 		fmt.Println(err.Error())
+		reportChan <- controllerMessage{i, nil}
 		return
 	}
+	defer conn.Close()
 
 	buffer_commit := make([]byte, 1024)
 
 	fmt.Println("CLIENT", i, "Sending message")
 
 	conn.Write(firstMessage)
-	_, err = conn.Read(buffer_commit)
+	n, err := conn.Read(buffer_commit)
 	if err != nil {
 		fmt.Println(err.Error())
+		reportChan <- controllerMessage{i, nil}
 		return
 	}
 	fmt.Println("CLIENT", i, "Response received, reporting to controller")
 
 	// we now need to wait for the next step in the process.
 
-	reportMsg := controllerMessage{i, buffer_commit}
+	reportMsg := controllerMessage{i, buffer_commit[:n]}
 	reportChan <- reportMsg // send back to runClientProtocol
 
 	// now we'll use channel's by default blocking as a synchronisation
@@ -81,8 +65,12 @@ func serverComms(gconfig SchnorrMGroupConfig, i int, msg []byte, reportChan chan
 
 	fmt.Println("CLIENT", i, "Get aggregateBytes")
 
-	var aggregateCommitmentBytes []byte
-	aggregateCommitmentBytes = <-syncChan
+	// the controller closes our channel if it signs without us.
+	aggregateCommitmentBytes, ok := <-syncChan
+	if !ok {
+		fmt.Println("CLIENT", i, "Not among the signers, exiting.")
+		return
+	}
 
 	fmt.Println("CLIENT", i, "Got aggregateCommitmentBytes")
 
@@ -97,44 +85,52 @@ func serverComms(gconfig SchnorrMGroupConfig, i int, msg []byte, reportChan chan
 	fmt.Println("CLIENT", i, "Sending aggregate commitment back to server.")
 
 	conn.Write(secondMessage)
-	_, err = conn.Read(buffer_response)
+	n, err = conn.Read(buffer_response)
 	if err != nil {
 		fmt.Println("CLIENT", i, "Error getting response from server")
 		fmt.Println(err.Error())
+		reportChan <- controllerMessage{i, nil}
 		return
 	}
 
 	fmt.Println("CLIENT", i, "Reporting response response to the controller, then exiting.")
 	// report the outcome of the server response
-	reportMsg = controllerMessage{i, buffer_response}
+	reportMsg = controllerMessage{i, buffer_response[:n]}
 	reportChan <- reportMsg // send back to runClientProtocol
 
 	// and then exit
-	conn.Close()
 	return
 }
 
-func runClientProtocol(configFilePath string) (bool, error) {
+func runClientProtocol(configFilePath string, legacy bool) (bool, error) {
 
 	// first stage, let's retrieve everything from
 	// the configuration file that the client needs
 
-	var config SchnorrMGroupConfig
-
-	suite, _ := schnorrgs.GetSuite("BlakeSHA256Ed25519")
-
-	fcontents, err := ioutil.ReadFile(configFilePath)
+	config, err := schnorrgs.SchnorrLoadGroupConfig(configFilePath)
 	if err != nil {
-		fmt.Println("Error reading file")
+		fmt.Println("Error reading group configuration")
 		fmt.Println(err.Error())
 		os.Exit(1)
 	}
 
-	err = json.Unmarshal(fcontents, &config)
+	// old group files have no record of how the joint key was made.
+	if legacy {
+		config.LegacyAggregation = true
+	}
+
+	suite, err := config.GetSuite()
 	if err != nil {
-		fmt.Println("Error unmarshalling")
 		fmt.Println(err.Error())
-		os.Exit(1)
+		return false, err
+	}
+
+	// refuse to talk to a group whose joint key is not what its
+	// members add up to.
+	err = config.Verify()
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return false, err
 	}
 
 	// and now, for our next trick, a random 1KB blob
@@ -156,51 +152,71 @@ func runClientProtocol(configFilePath string) (bool, error) {
 		syncChans = append(syncChans, syncChan)
 		fmt.Println("CLIENT", "C", "Launching goroutine worker")
 
-		go serverComms(config, i, randomdata, reportChan, syncChan)
+		go serverComms(*config, i, randomdata, reportChan, syncChan)
 	}
 
-	var respCount int = 0
+	// a threshold group signs with the first Threshold members to
+	// commit; anyone else only needs to be there if someone fails.
+	needed := config.SignersNeeded()
+	var signers []int
+	var failures int = 0
 	fmt.Println("CLIENT", "C", "Allocated space for", len(config.Members))
 	commitmentArray := make([]schnorrgs.SchnorrMSPublicCommitment, len(config.Members))
 
 	fmt.Println("CLIENT", "C", "Controller getting ready to receive")
 
-	for {
-
-		select {
-		case msg := <-reportChan:
-
-			// we should probably check all our client threads have responded
-			// once and only once, but we won't
+	for len(signers) < needed {
 
-			commitment := schnorrgs.SchnorrMSPublicCommitment{}
-
-			err = commitment.UnmarshalBinary(suite, msg.Message)
-			if err != nil {
-				fmt.Println("CLIENT", "C", "Decode Read Error")
-				fmt.Println(err.Error())
-				return false, err
+		msg := <-reportChan
+		if msg.Message == nil {
+			failures = failures + 1
+			fmt.Println("CLIENT", "C", "Lost member", msg.MemberIndex)
+			if len(config.Members)-failures < needed {
+				return false, errors.New("Too few members available to sign.")
 			}
+			continue
+		}
 
-			// we have our abstract point.
-			// let's go
-			fmt.Println("CLIENT", "C", "Controller got message index", msg.MemberIndex)
-			commitmentArray[msg.MemberIndex] = commitment
-			respCount = respCount + 1
+		commitment := schnorrgs.SchnorrMSPublicCommitment{}
 
-		default:
+		err = commitment.UnmarshalBinary(suite, msg.Message)
+		if err != nil {
+			fmt.Println("CLIENT", "C", "Decode Read Error")
+			fmt.Println(err.Error())
+			return false, err
 		}
 
-		if respCount == len(config.Members) {
-			break
-		}
+		// we have our abstract point.
+		// let's go
+		fmt.Println("CLIENT", "C", "Controller got message index", msg.MemberIndex)
+		commitmentArray[msg.MemberIndex] = commitment
+		signers = append(signers, msg.MemberIndex)
+	}
+
+	fmt.Println("CLIENT", "C", "Controller received enough commitments, preparing to aggregate")
+
+	isSigner := make([]bool, len(config.Members))
+	var signerCommitments []schnorrgs.SchnorrMSPublicCommitment
+	var shareIndices []int
+	for _, i := range signers {
+		isSigner[i] = true
+		signerCommitments = append(signerCommitments, commitmentArray[i])
+		shareIndices = append(shareIndices, config.Members[i].ShareIndex)
 	}
 
-	fmt.Println("CLIENT", "C", "Controller received all responses, preparing to aggregate")
+	sharedpubkey, err := config.GetJointKeyAsKV()
+	if err != nil {
+		fmt.Println("Error")
+		return false, err
+	}
 
 	// sum the points
-	aggregateCommmitment := schnorrgs.SchnorrMSAggregateCommitment(suite, commitmentArray)
-	collectiveChallenge, _ := schnorrgs.SchnorrMSComputeCollectiveChallenge(suite, aggregateCommmitment, randomdata)
+	aggregateCommmitment := schnorrgs.SchnorrMSAggregateCommitment(suite, signerCommitments)
+	collectiveChallenge, err := schnorrgs.SchnorrMSComputeCollectiveChallenge(suite, nil, *sharedpubkey, aggregateCommmitment, randomdata)
+	if err != nil {
+		fmt.Println("Error")
+		return false, err
+	}
 
 	bAggregateCommmitment, err := aggregateCommmitment.MarshalBinary()
 	if err != nil {
@@ -208,8 +224,23 @@ func runClientProtocol(configFilePath string) (bool, error) {
 		return false, err
 	}
 
+	// threshold signers also need to know who else is signing, for
+	// their Lagrange coefficients.
+	if config.Threshold > 0 {
+		bSigners, err := schnorrgs.SchnorrTSEncodeSigners(shareIndices)
+		if err != nil {
+			fmt.Println("Error")
+			return false, err
+		}
+		bAggregateCommmitment = append(bAggregateCommmitment, bSigners...)
+	}
+
 	// report
-	for _, ch := range syncChans {
+	for i, ch := range syncChans {
+		if !isSigner[i] {
+			close(ch)
+			continue
+		}
 		fmt.Println("CLIENT", "C", "Sending aggcommitbytes back to workers")
 		ch <- bAggregateCommmitment
 	}
@@ -219,57 +250,68 @@ func runClientProtocol(configFilePath string) (bool, error) {
 
 	fmt.Println("CLIENT", "C", "Controller getting ready to receive")
 
-	responseArray := make([]kyber.Scalar, len(config.Members))
-	respCount = 0
+	var responseArray []kyber.Scalar
+	respCount := 0
 
-	for {
-		select {
-		case msg := <-reportChan:
+	for respCount < needed {
 
-			// we should probably check all our client threads have responded
-			// once and only once, but we won't
-			response := suite.Scalar().Zero()
-			scalar_size := suite.Scalar().MarshalSize()
+		msg := <-reportChan
 
-			err := response.UnmarshalBinary(msg.Message[0:scalar_size])
-
-			if err != nil {
-				fmt.Println("CLIENT", "C", "Error!")
-				fmt.Println(err.Error())
-				return false, err
-			}
+		// late commitments from members we are not using.
+		if !isSigner[msg.MemberIndex] {
+			continue
+		}
+		if msg.Message == nil {
+			return false, errors.New("Lost a signer during signing.")
+		}
 
-			fmt.Println("CLIENT", "C", "Received from", msg.MemberIndex)
+		response, err := schnorrgs.DecodeScalar(suite, msg.Message)
 
-			// we have our abstract point.
-			// let's go
-			responseArray[msg.MemberIndex] = response
+		if err != nil {
+			fmt.Println("CLIENT", "C", "Error!")
+			fmt.Println(err.Error())
+			return false, err
+		}
 
-			respCount = respCount + 1
-			fmt.Println("CLIENT", "C", "Received responses", respCount)
+		fmt.Println("CLIENT", "C", "Received from", msg.MemberIndex)
 
-		default:
+		// with share keys we can tell exactly who answered wrongly.
+		if config.Threshold > 0 {
+			member := config.Members[msg.MemberIndex]
+			sharekey, err := member.GetPKeyAsKV()
+			if err != nil {
+				return false, err
+			}
+			ok, err := schnorrgs.SchnorrTSVerifyResponse(suite,
+				collectiveChallenge, member.ShareIndex, shareIndices,
+				*sharekey, commitmentArray[msg.MemberIndex], response)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				fmt.Println("CLIENT", "C", "Bad response from member", msg.MemberIndex)
+				return false, errors.New("Member sent an invalid response.")
+			}
 		}
 
-		if respCount == len(config.Members) {
-			break
-		}
+		responseArray = append(responseArray, response)
+
+		respCount = respCount + 1
+		fmt.Println("CLIENT", "C", "Received responses", respCount)
 	}
 
 	combined_response := schnorrgs.SchnorrMSComputeCombinedResponse(suite, responseArray)
 
-	sig := schnorrgs.SchnorrMSCreateSignature(suite, collectiveChallenge, combined_response)
-
-	fmt.Println("Signature created, is")
-	fmt.Println(sig)
-
-	sharedpubkey, err := config.GetJointKeyAsKV()
+	sig, err := schnorrgs.SchnorrMSCreateSignature(suite, collectiveChallenge, combined_response)
 	if err != nil {
-		fmt.Println("Error during Verification")
+		fmt.Println("Error creating signature")
 		fmt.Println(err.Error())
 		return false, err
 	}
 
+	fmt.Println("Signature created, is")
+	fmt.Println(sig)
+
 	fmt.Println("Verifying Signature with shared public key")
 
 	verified, err := schnorrgs.SchnorrVerify(suite,
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dedis/kyber"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+)
+
+// FROST connections start with this byte, followed by JSON requests.
+const FROST byte = 0x46
+
+// The commitments servers have published to us and we have not used
+// yet, hex encoded, by share index.
+type frostCommitmentCache map[int][]string
+
+func loadFROSTCache(path string) (frostCommitmentCache, error) {
+	cache := make(frostCommitmentCache)
+	fcontents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(fcontents, &cache)
+	return cache, err
+}
+
+func saveFROSTCache(path string, cache frostCommitmentCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// Opens a FROST connection to a member and sends it one request.
+func frostCall(member schnorrgs.SchnorrMMember,
+	req schnorrgs.SchnorrFROSTRequest) (schnorrgs.SchnorrFROSTReply, error) {
+
+	var reply schnorrgs.SchnorrFROSTReply
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(member.HostName,
+		strconv.Itoa(member.Port)))
+	if err != nil {
+		return reply, err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte{FROST})
+	if err != nil {
+		return reply, err
+	}
+	err = json.NewEncoder(conn).Encode(req)
+	if err != nil {
+		return reply, err
+	}
+	err = json.NewDecoder(conn).Decode(&reply)
+	if err != nil {
+		return reply, err
+	}
+	if reply.Error != "" {
+		return reply, errors.New(reply.Error)
+	}
+	return reply, nil
+}
+
+// Reads and checks a group configuration for FROST signing.
+func loadFROSTGroup(configFilePath string) (*schnorrgs.SchnorrMGroupConfig, error) {
+	config, err := schnorrgs.SchnorrLoadGroupConfig(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if config.Threshold == 0 {
+		return nil, errors.New("FROST needs a threshold group.")
+	}
+	err = config.Verify()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+/* Asks every member for count fresh nonce commitments and adds them to
+   the cache at cachePath. This is FROST's first round, done ahead of
+   time; members that are down are skipped. */
+func runFROSTPreprocess(configFilePath string, cachePath string,
+	count int) error {
+
+	config, err := loadFROSTGroup(configFilePath)
+	if err != nil {
+		return err
+	}
+	suite, err := config.GetSuite()
+	if err != nil {
+		return err
+	}
+	cache, err := loadFROSTCache(cachePath)
+	if err != nil {
+		return err
+	}
+
+	for i, member := range config.Members {
+		reply, err := frostCall(member, schnorrgs.SchnorrFROSTRequest{
+			Step: schnorrgs.FROSTPreprocessStep, Count: count})
+		if err != nil {
+			fmt.Println("CLIENT", i, "Error", err.Error())
+			continue
+		}
+		for _, b := range reply.Commitments {
+			var fc schnorrgs.SchnorrFROSTCommitment
+			err = fc.UnmarshalBinary(suite, b)
+			if err != nil || fc.Index != member.ShareIndex {
+				return errors.New("Member " + strconv.Itoa(i) +
+					" sent a bad commitment.")
+			}
+			cache[member.ShareIndex] = append(cache[member.ShareIndex],
+				hex.EncodeToString(b))
+		}
+		fmt.Println("CLIENT", i, "Got", len(reply.Commitments), "commitments")
+	}
+
+	return saveFROSTCache(cachePath, cache)
+}
+
+// The outcome of asking one signer for its signature share.
+type frostShareReport struct {
+	MemberIndex int
+	Share       kyber.Scalar
+	Err         error
+}
+
+/* Signs a random 1KB blob with FROST in a single round: picks the first
+   Threshold members we hold unused commitments for, sends each the
+   message and the commitment list, and checks and adds up the shares. */
+func runFROSTClientProtocol(configFilePath string,
+	cachePath string) (bool, error) {
+
+	config, err := loadFROSTGroup(configFilePath)
+	if err != nil {
+		return false, err
+	}
+	suite, err := config.GetSuite()
+	if err != nil {
+		return false, err
+	}
+	sharedpubkey, err := config.GetJointKeyAsKV()
+	if err != nil {
+		return false, err
+	}
+	cache, err := loadFROSTCache(cachePath)
+	if err != nil {
+		return false, err
+	}
+
+	var signers []int
+	var commitments []schnorrgs.SchnorrFROSTCommitment
+	var encoded [][]byte
+	for i, member := range config.Members {
+		if len(signers) == config.Threshold {
+			break
+		}
+		pending := cache[member.ShareIndex]
+		if len(pending) == 0 {
+			continue
+		}
+		b, err := hex.DecodeString(pending[0])
+		if err != nil {
+			return false, err
+		}
+		var fc schnorrgs.SchnorrFROSTCommitment
+		err = fc.UnmarshalBinary(suite, b)
+		if err != nil {
+			return false, err
+		}
+		cache[member.ShareIndex] = pending[1:]
+		signers = append(signers, i)
+		commitments = append(commitments, fc)
+		encoded = append(encoded, b)
+	}
+	if len(signers) < config.Threshold {
+		return false, errors.New("Not enough preprocessed commitments; " +
+			"run with --preprocess first.")
+	}
+
+	// a commitment must never be offered twice, so forget the ones we
+	// use before anybody sees them.
+	err = saveFROSTCache(cachePath, cache)
+	if err != nil {
+		return false, err
+	}
+
+	randomdata := make([]byte, 1024)
+	_, err = rand.Read(randomdata)
+	if err != nil {
+		return false, err
+	}
+
+	req := schnorrgs.SchnorrFROSTRequest{Step: schnorrgs.FROSTSignStep,
+		Message: randomdata, Commitments: encoded}
+	reportChan := make(chan frostShareReport)
+	for _, i := range signers {
+		go func(i int) {
+			report := frostShareReport{MemberIndex: i}
+			reply, err := frostCall(config.Members[i], req)
+			if err == nil {
+				report.Share, err = schnorrgs.DecodeScalar(suite, reply.Share)
+			}
+			report.Err = err
+			reportChan <- report
+		}(i)
+	}
+
+	var shares []kyber.Scalar
+	for range signers {
+		report := <-reportChan
+		if report.Err != nil {
+			fmt.Println("CLIENT", report.MemberIndex, "Error", report.Err.Error())
+			return false, report.Err
+		}
+
+		member := config.Members[report.MemberIndex]
+		sharekey, err := member.GetPKeyAsKV()
+		if err != nil {
+			return false, err
+		}
+		ok, err := schnorrgs.SchnorrFROSTVerifyShare(suite, *sharedpubkey,
+			*sharekey, member.ShareIndex, nil, randomdata, commitments,
+			report.Share)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			fmt.Println("CLIENT", report.MemberIndex, "Bad signature share")
+			return false, errors.New("Member sent an invalid signature share.")
+		}
+		fmt.Println("CLIENT", report.MemberIndex, "Signature share OK")
+		shares = append(shares, report.Share)
+	}
+
+	sig, err := schnorrgs.SchnorrFROSTAggregate(suite, *sharedpubkey, nil,
+		randomdata, commitments, shares)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Println("Signature created, is")
+	fmt.Println(sig)
+
+	fmt.Println("Verifying Signature with shared public key")
+	verified, err := schnorrgs.SchnorrVerify(suite, *sharedpubkey, randomdata,
+		sig)
+	if err != nil {
+		return false, err
+	}
+	if !verified {
+		fmt.Println("Verification of signature failed.")
+		return false, nil
+	}
+	fmt.Println("Signature verified OK!")
+	return true, nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	//    "crypto/rand"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	"fmt"
 	"os"
 	//    "github.com/dedis/crypto/edwards/ed25519"
 	//    "vennard.ch/crypto"
@@ -13,10 +14,38 @@ import (
 var (
 	app        = kingpin.New("sthresholdclient", "Command line client for multisignature schnorr")
 	configFile = app.Arg("config", "Read the group configuration from this file").Required().String()
+	legacy     = app.Flag("legacy", "Treat the group as using naive, pre-MuSig key aggregation").Bool()
+	frost      = app.Flag("frost", "Sign in one round with FROST, using preprocessed nonce commitments").Bool()
+	preprocess = app.Flag("preprocess", "Fetch this many FROST nonce commitments from each member and exit").Int()
+	nonceFile  = app.Flag("nonces", "Keep unused FROST nonce commitments in this file (default: config file + .frost)").String()
 )
 
 func main() {
 	kingpin.MustParse(app.Parse(os.Args[1:]))
 
-	runClientProtocol(*configFile)
+	cachePath := *nonceFile
+	if cachePath == "" {
+		cachePath = *configFile + ".frost"
+	}
+
+	if *preprocess > 0 {
+		err := runFROSTPreprocess(*configFile, cachePath, *preprocess)
+		if err != nil {
+			fmt.Println("Error " + err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if *frost {
+		ok, err := runFROSTClientProtocol(*configFile, cachePath)
+		if err != nil {
+			fmt.Println("Error " + err.Error())
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	runClientProtocol(*configFile, *legacy)
 }
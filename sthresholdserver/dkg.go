@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/* Takes part in a single distributed key generation, relayed by keytool
+   dkg, using kv as our long-term identity. Our share of the new key is
+   written to outpath.pri, .pub and .pop; the shares of the others never
+   reach us, nor the group secret anybody.
+
+   The participants and the threshold are ours to decide, not the
+   relay's: a relay that could name the other participants would name
+   keys of its own and read every share we deal. The start message has to
+   list exactly the participants and threshold we were given. */
+func runDKG(port int, kv schnorrgs.SchnorrSecretKV, outpath string,
+	participants []schnorrgs.SchnorrPublicKV, threshold int) error {
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	fmt.Println("SERVER", "Waiting for a DKG relay")
+	conn, err := ln.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+
+	var dkg *schnorrgs.SchnorrDKG
+	var start schnorrgs.SchnorrDKGRequest
+	var share schnorrgs.SchnorrSecretKV
+
+	for {
+		var req schnorrgs.SchnorrDKGRequest
+		err = dec.Decode(&req)
+		if err != nil {
+			return err
+		}
+		fmt.Println("SERVER", "DKG step", req.Step)
+
+		var reply schnorrgs.SchnorrDKGReply
+		switch {
+		case req.Step == schnorrgs.DKGStartStep && dkg == nil:
+			start = req
+			err = checkDKGStart(req, participants, threshold)
+			if err != nil {
+				break
+			}
+			if req.Suite != kv.Suite() {
+				err = errors.New("Relay asked for suite " + req.Suite +
+					" but our identity is " + kv.Suite())
+				break
+			}
+			var suite schnorrgs.CryptoSuite
+			suite, err = schnorrgs.GetSuite(req.Suite)
+			if err != nil {
+				break
+			}
+			dkg, err = schnorrgs.NewSchnorrDKG(suite, kv, participants,
+				req.Threshold, req.Session)
+			if err != nil {
+				break
+			}
+			reply.Message, err = dkg.Deal()
+		case req.Step == schnorrgs.DKGDealsStep && dkg != nil:
+			reply.Message, err = dkg.ProcessDeals(req.Messages)
+		case req.Step == schnorrgs.DKGComplaintsStep && dkg != nil:
+			reply.Message, err = dkg.ProcessComplaints(req.Messages)
+		case req.Step == schnorrgs.DKGJustificationsStep && dkg != nil:
+			share, reply.Message, err = dkg.ProcessJustifications(req.Messages)
+		case req.Step == schnorrgs.DKGCommitStep && dkg != nil:
+			// only keep the share if everybody agrees on how we got it.
+			suite, _ := schnorrgs.GetSuite(start.Suite)
+			_, err = schnorrgs.SchnorrDKGVerifyResults(suite, participants,
+				start.Threshold, start.Session, req.Messages)
+			if err == nil {
+				err = saveDKGShare(suite, share, outpath)
+			}
+			if err == nil {
+				err = enc.Encode(reply)
+				fmt.Println("SERVER", "DKG complete, share written to", outpath+".pri")
+				return err
+			}
+		default:
+			err = errors.New("Unexpected DKG step " + req.Step)
+		}
+
+		if err != nil {
+			reply.Error = err.Error()
+			enc.Encode(reply)
+			return err
+		}
+		err = enc.Encode(reply)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+/* Loads the participants' identity keys from a comma separated list of
+   public key files. */
+func loadDKGPeers(paths string) ([]schnorrgs.SchnorrPublicKV, error) {
+	if paths == "" {
+		return nil, errors.New("-dkg needs -dkg-peers")
+	}
+	var participants []schnorrgs.SchnorrPublicKV
+	for _, path := range strings.Split(paths, ",") {
+		pk, err := schnorrgs.SchnorrLoadPubkey(path)
+		if err != nil {
+			return nil, err
+		}
+		participants = append(participants, *pk)
+	}
+	return participants, nil
+}
+
+/* Checks the relay's start message names exactly our participants, in
+   our order, and our threshold. */
+func checkDKGStart(req schnorrgs.SchnorrDKGRequest,
+	participants []schnorrgs.SchnorrPublicKV, threshold int) error {
+
+	if req.Threshold != threshold {
+		return errors.New("Relay asked for threshold " +
+			strconv.Itoa(req.Threshold) + " but ours is " +
+			strconv.Itoa(threshold))
+	}
+	if len(req.Participants) != len(participants) {
+		return errors.New("Relay listed " + strconv.Itoa(len(req.Participants)) +
+			" participants but we expect " + strconv.Itoa(len(participants)))
+	}
+	for i, k := range req.Participants {
+		if k != participants[i].Export() {
+			return errors.New("Relay listed participant " + strconv.Itoa(i+1) +
+				" as " + k + " but we expect " + participants[i].Export())
+		}
+	}
+	return nil
+}
+
+func saveDKGShare(suite schnorrgs.CryptoSuite, share schnorrgs.SchnorrSecretKV,
+	outpath string) error {
+
+	err := schnorrgs.SchnorrSaveSecretKV(outpath+".pri", share)
+	if err != nil {
+		return err
+	}
+	err = schnorrgs.SchnorrSavePubkey(outpath+".pub", share.GetPublicKeyset())
+	if err != nil {
+		return err
+	}
+	proof, err := schnorrgs.SchnorrGenerateProofOfPossession(suite, share)
+	if err != nil {
+		return err
+	}
+	return schnorrgs.SchnorrSaveProofOfPossession(outpath+".pop", proof)
+}
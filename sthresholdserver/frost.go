@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dedis/kyber"
+	"github.com/diagprov/dedischallenge/schnorrgs"
+	"net"
+)
+
+// FROST connections start with this byte, followed by JSON requests.
+const FROST byte = 0x46
+
+// Most commitments we hand out in one go.
+const frostMaxPreprocess = 1000
+
+// A connection we can peek into before deciding who handles it.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func newBufferedConn(conn net.Conn) bufferedConn {
+	return bufferedConn{conn, bufio.NewReader(conn)}
+}
+
+func (b bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+/* Serves FROST requests on conn: publishing fresh nonce commitments, and
+   signing with ones published before. Nonces live in store, shared by all
+   connections, and each is used for at most one signature. */
+func serveFROST(conn bufferedConn, suite schnorrgs.CryptoSuite,
+	kv schnorrgs.SchnorrSecretKV, group schnorrgs.SchnorrMGroupConfig,
+	memberIndex int, store *schnorrgs.SchnorrFROSTNonceStore) {
+
+	defer conn.Close()
+
+	_, err := conn.r.ReadByte()
+	if err != nil {
+		return
+	}
+
+	dec := json.NewDecoder(conn.r)
+	enc := json.NewEncoder(conn)
+	index := group.Members[memberIndex].ShareIndex
+
+	for {
+		var req schnorrgs.SchnorrFROSTRequest
+		err := dec.Decode(&req)
+		if err != nil {
+			return
+		}
+
+		var reply schnorrgs.SchnorrFROSTReply
+		switch req.Step {
+		case schnorrgs.FROSTPreprocessStep:
+			fmt.Println("SERVER", "FROST preprocessing", req.Count)
+			reply.Commitments, err = frostPreprocess(suite, kv, index,
+				req.Count, store)
+		case schnorrgs.FROSTSignStep:
+			fmt.Println("SERVER", "FROST signing")
+			reply.Share, err = frostSign(suite, kv, group, index, req, store)
+		default:
+			err = errors.New("Unknown FROST step " + req.Step)
+		}
+
+		if err != nil {
+			fmt.Println("Error " + err.Error())
+			reply = schnorrgs.SchnorrFROSTReply{Error: err.Error()}
+		}
+		err = enc.Encode(reply)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func frostPreprocess(suite schnorrgs.CryptoSuite, kv schnorrgs.SchnorrSecretKV,
+	index int, count int, store *schnorrgs.SchnorrFROSTNonceStore) ([][]byte, error) {
+
+	if count < 1 || count > frostMaxPreprocess {
+		return nil, errors.New("Can not preprocess that many nonces.")
+	}
+	nonces, err := schnorrgs.SchnorrFROSTPreprocess(suite, index, kv, count)
+	if err != nil {
+		return nil, err
+	}
+	err = store.Add(nonces)
+	if err != nil {
+		return nil, err
+	}
+
+	var commitments [][]byte
+	for _, n := range nonces {
+		b, err := n.GetCommitment().MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		commitments = append(commitments, b)
+	}
+	return commitments, nil
+}
+
+func frostSign(suite schnorrgs.CryptoSuite, kv schnorrgs.SchnorrSecretKV,
+	group schnorrgs.SchnorrMGroupConfig, index int,
+	req schnorrgs.SchnorrFROSTRequest,
+	store *schnorrgs.SchnorrFROSTNonceStore) ([]byte, error) {
+
+	members := make(map[int]bool)
+	for _, m := range group.Members {
+		members[m.ShareIndex] = true
+	}
+
+	var commitments []schnorrgs.SchnorrFROSTCommitment
+	var own *schnorrgs.SchnorrFROSTCommitment
+	for _, b := range req.Commitments {
+		var fc schnorrgs.SchnorrFROSTCommitment
+		err := fc.UnmarshalBinary(suite, b)
+		if err != nil {
+			return nil, err
+		}
+		if !members[fc.Index] {
+			return nil, errors.New("Commitment from a non-member.")
+		}
+		commitments = append(commitments, fc)
+		if fc.Index == index {
+			own = &commitments[len(commitments)-1]
+		}
+	}
+	if len(commitments) < group.Threshold {
+		return nil, errors.New("Fewer signers than the threshold.")
+	}
+	if own == nil {
+		return nil, errors.New("We are not among the signers.")
+	}
+
+	// taking the nonce out first means it is gone even if signing fails.
+	nonce, err := store.Take(*own)
+	if err != nil {
+		return nil, err
+	}
+	groupkey, err := group.GetJointKeyAsKV()
+	if err != nil {
+		return nil, err
+	}
+	var z kyber.Scalar
+	z, err = schnorrgs.SchnorrFROSTSign(suite, kv, nonce, *groupkey, nil,
+		req.Message, commitments)
+	if err != nil {
+		return nil, err
+	}
+	return z.MarshalBinary()
+}
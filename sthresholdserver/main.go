@@ -1,28 +1,106 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/dedis/kyber/group/edwards25519"
 	"github.com/diagprov/dedischallenge/schnorrgs"
 	"golang.org/x/net/context"
 	"net"
 	"os"
 	"os/signal"
+	"time"
 )
 
 func main() {
 	var port int
 	var kfilepath string
+	var passfile string
+	var gfilepath string
+	var legacy bool
+	var dkgpath string
+	var dkgpeers string
+	var dkgthreshold int
+	var frostNonces int
+	var frostLifetime time.Duration
 
 	flag.IntVar(&port, "port", 1111, "Listen on given port")
 	flag.StringVar(&kfilepath, "keyfile", "", "Use the keyfile specified")
+	flag.StringVar(&passfile, "passfile", "", "Read the passphrase of an encrypted keyfile from this file instead of $"+schnorrgs.PassphraseEnvVar+" or the terminal")
+	flag.StringVar(&gfilepath, "group", "", "Group configuration this server is a member of")
+	flag.BoolVar(&legacy, "legacy", false, "Use naive, pre-MuSig key aggregation")
+	flag.StringVar(&dkgpath, "dkg", "", "Run one distributed key generation with the keyfile as identity, writing our share to this path (.pri, .pub, .pop), then exit")
+	flag.StringVar(&dkgpeers, "dkg-peers", "", "Identity public keys of every DKG participant, ours included, comma separated in the order the relay lists them")
+	flag.IntVar(&dkgthreshold, "dkg-threshold", 0, "Number of members needed to sign in the group the DKG makes")
+
+	flag.IntVar(&frostNonces, "frost-nonces", schnorrgs.DefaultFROSTNonceCapacity, "Most unused FROST nonces to keep")
+	flag.DurationVar(&frostLifetime, "frost-nonce-lifetime", schnorrgs.DefaultFROSTNonceLifetime, "Forget unused FROST nonces after this long")
 
 	flag.Parse()
 	fmt.Printf("sthresholdserver - listening on port %d.\n", port)
 
-	suite := edwards25519.NewBlakeSHA256Ed25519()
-	kv, err := schnorrgs.SchnorrLoadSecretKV(kfilepath)
+	kv, encrypted, err := schnorrgs.SchnorrLoadSecretKVWithPassphrase(kfilepath,
+		func() ([]byte, error) {
+			return schnorrgs.SchnorrReadPassphrase(passfile,
+				"Passphrase for "+kfilepath+": ")
+		})
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	if !encrypted {
+		fmt.Println("Warning: keyfile " + kfilepath + " is not encrypted")
+	}
+	err = kv.CheckUsage(schnorrgs.UsageMultisig)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+
+	// the key file tells us which suite we are working in.
+	suite, err := schnorrgs.GetSuite(kv.Suite())
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+
+	if dkgpath != "" {
+		// the relay only carries messages; who takes part is up to us.
+		var participants []schnorrgs.SchnorrPublicKV
+		participants, err = loadDKGPeers(dkgpeers)
+		if err == nil && dkgthreshold < 1 {
+			err = errors.New("-dkg needs -dkg-threshold")
+		}
+		if err == nil {
+			err = runDKG(port, *kv, dkgpath, participants, dkgthreshold)
+		}
+		if err != nil {
+			fmt.Println("Error " + err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// MuSig responses depend on the keys of the whole group.
+	group, err := schnorrgs.SchnorrLoadGroupConfig(gfilepath)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	if legacy {
+		group.LegacyAggregation = true
+	}
+	err = group.Verify()
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	memberIndex, err := group.MemberIndex(*kv)
+	if err != nil {
+		fmt.Println("Error " + err.Error())
+		return
+	}
+	members, err := group.GetMemberKeys()
 	if err != nil {
 		fmt.Println("Error " + err.Error())
 		return
@@ -31,8 +109,23 @@ func main() {
 	exitCh := make(chan struct{})
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// FROST nonces have to outlive the connection that published them.
+	frostStore := schnorrgs.NewSchnorrFROSTNonceStoreWithLimits(frostNonces,
+		frostLifetime)
+
 	var signOneKBImpl connectionhandler = func(conn net.Conn) {
-		signOneKBMSchnorr(conn, suite, *kv)
+		bconn := newBufferedConn(conn)
+		first, err := bconn.r.Peek(1)
+		if err == nil && first[0] == FROST {
+			if group.Threshold == 0 {
+				fmt.Println("Error FROST needs a threshold group")
+				conn.Close()
+				return
+			}
+			serveFROST(bconn, suite, *kv, *group, memberIndex, frostStore)
+			return
+		}
+		signOneKBMSchnorr(bconn, suite, *kv, *group, memberIndex, members)
 	}
 	serve(port, signOneKBImpl, ctx, exitCh)
 
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"github.com/dedis/kyber"
 	"github.com/diagprov/dedischallenge/schnorrgs"
 	"golang.org/x/net/context"
 	"io"
@@ -19,7 +21,8 @@ const (
 	COMMITMENT byte = 2
 )
 
-func signOneKBMSchnorr(conn net.Conn, suite schnorrgs.CryptoSuite, kv schnorrgs.SchnorrSecretKV) {
+func signOneKBMSchnorr(conn net.Conn, suite schnorrgs.CryptoSuite, kv schnorrgs.SchnorrSecretKV,
+	group schnorrgs.SchnorrMGroupConfig, memberIndex int, members []schnorrgs.SchnorrPublicKV) {
 
 	defer conn.Close()
 
@@ -36,14 +39,17 @@ func signOneKBMSchnorr(conn net.Conn, suite schnorrgs.CryptoSuite, kv schnorrgs.
 			// try to read the data
 			fmt.Println("SERVER", "Read goroutine off and going")
 			buffer := make([]byte, 1026)
-			_, err := conn.Read(buffer)
+			n, err := conn.Read(buffer)
 			if err != nil {
 				// send an error if it's encountered
 				errorCh <- err
 				return
 			}
-			// send data if we read some.
-			ch <- buffer
+			// send data if we read some, and only that: the decoders
+			// refuse trailing bytes.
+			if n >= 2 {
+				ch <- buffer[:n]
+			}
 		}
 	}(ch, errorCh)
 
@@ -77,14 +83,20 @@ func signOneKBMSchnorr(conn net.Conn, suite schnorrgs.CryptoSuite, kv schnorrgs.
 
 				message = payload
 
-				privateCommitment = schnorrgs.SchnorrMSGenerateCommitment(suite)
+				var err error
+				privateCommitment, err = schnorrgs.SchnorrMSGenerateCommitmentHedged(suite, kv, message)
+				if err != nil {
+					fmt.Println("Error")
+					fmt.Println(err.Error())
+					return
+				}
 
 				publicCommitment := privateCommitment.GetPublicCommitment()
 				fmt.Println("Public commitment " + publicCommitment.T.String())
 				b, err := publicCommitment.MarshalBinary()
 				if err != nil {
 					fmt.Println("Error")
-					fmt.Println(err.Error)
+					fmt.Println(err.Error())
 					return
 				}
 
@@ -93,19 +105,56 @@ func signOneKBMSchnorr(conn net.Conn, suite schnorrgs.CryptoSuite, kv schnorrgs.
 			case COMMITMENT:
 
 				fmt.Println("SERVER", "Received Commitment")
-				err := aggregateCommitment.UnmarshalBinary(suite, payload)
+				// the point, followed by the signers for threshold groups.
+				point_size := suite.Point().MarshalSize()
+				if len(payload) < point_size {
+					fmt.Println("Error")
+					fmt.Println("Aggregate commitment too short.")
+					return
+				}
+				err := aggregateCommitment.UnmarshalBinary(suite, payload[:point_size])
+				if err == nil && group.Threshold == 0 && len(payload) != point_size {
+					err = errors.New("Trailing bytes after the aggregate commitment.")
+				}
 				if err != nil {
 					fmt.Println("Error")
 					fmt.Println(err.Error())
 					return
 				}
-				collectiveChallenge, err := schnorrgs.SchnorrMSComputeCollectiveChallenge(suite, aggregateCommitment, message)
+				jointKey, err := group.GetJointKeyAsKV()
+				if err != nil {
+					fmt.Println("Error")
+					fmt.Println(err.Error())
+					return
+				}
+				collectiveChallenge, err := schnorrgs.SchnorrMSComputeCollectiveChallenge(suite, nil, *jointKey, aggregateCommitment, message)
+				if err != nil {
+					fmt.Println("Error")
+					fmt.Println(err.Error())
+					return
+				}
+				var response kyber.Scalar
+				if group.Threshold > 0 {
+					// threshold clients tell us who else signs after the commitment.
+					var signers []int
+					signers, err = schnorrgs.SchnorrTSDecodeSigners(payload[point_size:])
+					if err == nil && len(signers) < group.Threshold {
+						err = errors.New("Fewer signers than the threshold.")
+					}
+					if err == nil {
+						response, err = schnorrgs.SchnorrTSComputeResponse(suite, collectiveChallenge,
+							group.Members[memberIndex].ShareIndex, signers, kv, privateCommitment)
+					}
+				} else if group.LegacyAggregation {
+					response, err = schnorrgs.SchnorrMSComputeResponseLegacy(suite, collectiveChallenge, kv, privateCommitment)
+				} else {
+					response, err = schnorrgs.SchnorrMSComputeResponse(suite, collectiveChallenge, members, kv, privateCommitment)
+				}
 				if err != nil {
 					fmt.Println("Error")
 					fmt.Println(err.Error())
 					return
 				}
-				response := schnorrgs.SchnorrMSComputeResponse(suite, collectiveChallenge, kv, privateCommitment)
 				b, err := response.MarshalBinary()
 				if err != nil {
 					fmt.Println("Error")
@@ -0,0 +1,21 @@
+#!/bin/bash
+
+
+echo "[*] Generating an encrypted key"
+echo "notary test passphrase" > $PWD/notarytest.pass
+./keytool gen --encrypt --passfile $PWD/notarytest.pass $PWD/notarytestenc
+
+./notaryserver -keyfile $PWD/notarytestenc.pri -passfile $PWD/notarytest.pass &
+jobid=$(echo $!)
+echo "[*] Background server started with PID=$jobid"
+
+sleep 1
+
+echo "[*] Launching Client"
+
+./notaryclient -keyfile $PWD/notarytestenc.pub
+
+sleep 1
+
+echo "[*] Killing server job"
+kill $jobid
@@ -9,10 +9,10 @@ echo "[*] Making group configuration file"
 ./keytool mkgroup groupfile.txt localhost:2220,$PWD/ms0.pub \
                                 localhost:2221,$PWD/ms1.pub
 
-./sthresholdserver -keyfile $PWD/ms0.pri -port 2220 >$PWD/srv0.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms0.pri -group $PWD/groupfile.txt -port 2220 >$PWD/srv0.log 2>&1 &
 jobid0=$(echo $!)
 echo "[*] Background server started with PID=$jobid0"
-./sthresholdserver -keyfile $PWD/ms1.pri -port 2221 >$PWD/srv1.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms1.pri -group $PWD/groupfile.txt -port 2221 >$PWD/srv1.log 2>&1 &
 jobid1=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
 
@@ -26,34 +26,34 @@ echo "[*] Making group configuration file"
                                 localhost:2228,$PWD/ms8.pub \
                                 localhost:2229,$PWD/ms9.pub \
 
-./sthresholdserver -keyfile $PWD/ms0.pri -port 2220 >$PWD/srv0.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms0.pri -group $PWD/groupfile10.txt -port 2220 >$PWD/srv0.log 2>&1 &
 jobid0=$(echo $!)
 echo "[*] Background server started with PID=$jobid0"
-./sthresholdserver -keyfile $PWD/ms1.pri -port 2221 >$PWD/srv1.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms1.pri -group $PWD/groupfile10.txt -port 2221 >$PWD/srv1.log 2>&1 &
 jobid1=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
-./sthresholdserver -keyfile $PWD/ms2.pri -port 2222 >$PWD/srv2.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms2.pri -group $PWD/groupfile10.txt -port 2222 >$PWD/srv2.log 2>&1 &
 jobid2=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
-./sthresholdserver -keyfile $PWD/ms3.pri -port 2223 >$PWD/srv3.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms3.pri -group $PWD/groupfile10.txt -port 2223 >$PWD/srv3.log 2>&1 &
 jobid3=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
-./sthresholdserver -keyfile $PWD/ms4.pri -port 2224 >$PWD/srv4.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms4.pri -group $PWD/groupfile10.txt -port 2224 >$PWD/srv4.log 2>&1 &
 jobid4=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
-./sthresholdserver -keyfile $PWD/ms5.pri -port 2225 >$PWD/srv5.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms5.pri -group $PWD/groupfile10.txt -port 2225 >$PWD/srv5.log 2>&1 &
 jobid5=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
-./sthresholdserver -keyfile $PWD/ms6.pri -port 2226 >$PWD/srv6.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms6.pri -group $PWD/groupfile10.txt -port 2226 >$PWD/srv6.log 2>&1 &
 jobid6=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
-./sthresholdserver -keyfile $PWD/ms7.pri -port 2227 >$PWD/srv7.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms7.pri -group $PWD/groupfile10.txt -port 2227 >$PWD/srv7.log 2>&1 &
 jobid7=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
-./sthresholdserver -keyfile $PWD/ms8.pri -port 2228 >$PWD/srv8.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms8.pri -group $PWD/groupfile10.txt -port 2228 >$PWD/srv8.log 2>&1 &
 jobid8=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
-./sthresholdserver -keyfile $PWD/ms9.pri -port 2229 >$PWD/srv9.log 2>&1 &
+./sthresholdserver -keyfile $PWD/ms9.pri -group $PWD/groupfile10.txt -port 2229 >$PWD/srv9.log 2>&1 &
 jobid9=$(echo $!)
 echo "[*] Background server started with PID=$jobid1"
 
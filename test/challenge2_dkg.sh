@@ -0,0 +1,52 @@
+#!/bin/bash
+
+
+echo "[*] Generating identity keys"
+./keytool gen $PWD/id0
+./keytool gen $PWD/id1
+./keytool gen $PWD/id2
+
+echo "[*] Starting servers for the key generation"
+peers=$PWD/id0.pub,$PWD/id1.pub,$PWD/id2.pub
+./sthresholdserver -keyfile $PWD/id0.pri -dkg $PWD/dkg0 -dkg-peers $peers -dkg-threshold 2 -port 2220 >$PWD/dkg0.log 2>&1 &
+./sthresholdserver -keyfile $PWD/id1.pri -dkg $PWD/dkg1 -dkg-peers $peers -dkg-threshold 2 -port 2221 >$PWD/dkg1.log 2>&1 &
+./sthresholdserver -keyfile $PWD/id2.pri -dkg $PWD/dkg2 -dkg-peers $peers -dkg-threshold 2 -port 2222 >$PWD/dkg2.log 2>&1 &
+
+sleep 1
+
+echo "[*] Running a 2-of-3 distributed key generation"
+./keytool dkg --threshold 2 groupfiledkg.txt localhost:2220,$PWD/id0.pub \
+                                              localhost:2221,$PWD/id1.pub \
+                                              localhost:2222,$PWD/id2.pub
+
+# the DKG servers exit once they have their share.
+wait
+
+echo "[*] A relay asking for another threshold is refused"
+./sthresholdserver -keyfile $PWD/id0.pri -dkg $PWD/dkgbad0 -dkg-peers $peers -dkg-threshold 2 -port 2220 >$PWD/dkgbad0.log 2>&1 &
+./sthresholdserver -keyfile $PWD/id1.pri -dkg $PWD/dkgbad1 -dkg-peers $peers -dkg-threshold 2 -port 2221 >$PWD/dkgbad1.log 2>&1 &
+./sthresholdserver -keyfile $PWD/id2.pri -dkg $PWD/dkgbad2 -dkg-peers $peers -dkg-threshold 2 -port 2222 >$PWD/dkgbad2.log 2>&1 &
+sleep 1
+./keytool dkg --threshold 3 groupfilebad.txt localhost:2220,$PWD/id0.pub \
+                                             localhost:2221,$PWD/id1.pub \
+                                             localhost:2222,$PWD/id2.pub
+wait
+
+./sthresholdserver -keyfile $PWD/dkg0.pri -group $PWD/groupfiledkg.txt -port 2220 >$PWD/srv0.log 2>&1 &
+jobid0=$(echo $!)
+echo "[*] Background server started with PID=$jobid0"
+./sthresholdserver -keyfile $PWD/dkg2.pri -group $PWD/groupfiledkg.txt -port 2222 >$PWD/srv2.log 2>&1 &
+jobid2=$(echo $!)
+echo "[*] Background server started with PID=$jobid2"
+
+sleep 1
+
+echo "[*] Launching Client"
+
+./sthresholdclient ./groupfiledkg.txt
+
+sleep 1
+
+echo "[*] Killing server jobs"
+kill $jobid0
+kill $jobid2
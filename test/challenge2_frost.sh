@@ -0,0 +1,37 @@
+#!/bin/bash
+
+
+echo "[*] Dealing a 2-of-3 threshold key"
+./keytool deal --threshold 2 $PWD/fs 3
+
+echo "[*] Making group configuration file"
+./keytool mkgroup --threshold 2 groupfilefs.txt localhost:2230,$PWD/fs1.pub,1 \
+                                               localhost:2231,$PWD/fs2.pub,2 \
+                                               localhost:2232,$PWD/fs3.pub,3
+rm -f groupfilefs.txt.frost
+
+./sthresholdserver -keyfile $PWD/fs1.pri -group $PWD/groupfilefs.txt -port 2230 >$PWD/srv0.log 2>&1 &
+jobid0=$(echo $!)
+echo "[*] Background server started with PID=$jobid0"
+./sthresholdserver -keyfile $PWD/fs2.pri -group $PWD/groupfilefs.txt -port 2231 >$PWD/srv1.log 2>&1 &
+jobid1=$(echo $!)
+echo "[*] Background server started with PID=$jobid1"
+./sthresholdserver -keyfile $PWD/fs3.pri -group $PWD/groupfilefs.txt -port 2232 >$PWD/srv2.log 2>&1 &
+jobid2=$(echo $!)
+echo "[*] Background server started with PID=$jobid2"
+
+sleep 1
+
+echo "[*] Fetching nonce commitments"
+./sthresholdclient --preprocess 5 ./groupfilefs.txt
+
+echo "[*] Signing with FROST"
+./sthresholdclient --frost ./groupfilefs.txt
+./sthresholdclient --frost ./groupfilefs.txt
+
+sleep 1
+
+echo "[*] Killing server jobs"
+kill $jobid0
+kill $jobid1
+kill $jobid2
@@ -0,0 +1,30 @@
+#!/bin/bash
+
+
+echo "[*] Dealing a 2-of-3 threshold key"
+./keytool deal --threshold 2 $PWD/ts 3
+
+echo "[*] Making group configuration file"
+./keytool mkgroup --threshold 2 groupfilets.txt localhost:2220,$PWD/ts1.pub,1 \
+                                               localhost:2221,$PWD/ts2.pub,2 \
+                                               localhost:2222,$PWD/ts3.pub,3
+
+# member 2 stays down; the other two are enough to sign.
+./sthresholdserver -keyfile $PWD/ts1.pri -group $PWD/groupfilets.txt -port 2220 >$PWD/srv0.log 2>&1 &
+jobid0=$(echo $!)
+echo "[*] Background server started with PID=$jobid0"
+./sthresholdserver -keyfile $PWD/ts3.pri -group $PWD/groupfilets.txt -port 2222 >$PWD/srv2.log 2>&1 &
+jobid2=$(echo $!)
+echo "[*] Background server started with PID=$jobid2"
+
+sleep 1
+
+echo "[*] Launching Client"
+
+./sthresholdclient ./groupfilets.txt
+
+sleep 1
+
+echo "[*] Killing server jobs"
+kill $jobid0
+kill $jobid2
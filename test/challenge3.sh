@@ -0,0 +1,30 @@
+#!/bin/bash
+
+
+echo "[*] Generating keys and agreed information"
+./keytool gen --usage blind $PWD/blindtest
+./keytool raninf $PWD/blindtest1.info
+./keytool raninf $PWD/blindtest5.info
+./keytool raninf $PWD/blindtestold.info
+./keytool mkcatalogue $PWD/blindtest $PWD/blindtest.catalogue \
+    1,$PWD/blindtest1.info,1 5,$PWD/blindtest5.info,5,2099-01-01 \
+    old,$PWD/blindtestold.info,10,2000-01-01
+
+./partialblindsigserver $PWD/blindtest.pri $PWD/blindtest.catalogue 1113 &
+jobid=$(echo $!)
+echo "[*] Background server started with PID=$jobid"
+
+sleep 1
+
+echo "[*] Launching Client for a coin of 5"
+
+./partialblindsigclient $PWD/blindtest.pub 5 localhost:1113
+
+echo "[*] Launching Client for an expired denomination, which should be refused"
+
+./partialblindsigclient $PWD/blindtest.pub old localhost:1113
+
+sleep 1
+
+echo "[*] Killing server job"
+kill $jobid
@@ -0,0 +1,49 @@
+#!/bin/bash
+
+
+echo "[*] Generating member keys, the group and each member's catalogue"
+./keytool raninf $PWD/grouptest5.info
+for i in 0 1 2; do
+    ./keytool gen --usage blind --usage multisig $PWD/groupbank$i
+    ./keytool mkcatalogue $PWD/groupbank$i $PWD/groupbank$i.catalogue 5,$PWD/grouptest5.info,5
+done
+./keytool mkgroup $PWD/groupbank.group localhost:2230,$PWD/groupbank0.pub \
+                                       localhost:2231,$PWD/groupbank1.pub \
+                                       localhost:2232,$PWD/groupbank2.pub
+./keytool gen --usage notary $PWD/groupreceipt
+rm -f $PWD/groupbank.spent
+
+for i in 0 1 2; do
+    ./partialblindsigserver --group $PWD/groupbank.group $PWD/groupbank$i.pri $PWD/groupbank$i.catalogue 223$i &
+    jobs[$i]=$(echo $!)
+done
+./depositserver --group $PWD/groupbank.group $PWD/groupbank0.catalogue $PWD/groupreceipt.pri $PWD/groupbank.spent 2235 &
+depositjob=$(echo $!)
+echo "[*] Background servers started with PIDs ${jobs[*]} $depositjob"
+
+sleep 1
+
+echo "[*] Withdrawing a coin signed by the whole group"
+./partialblindsigclient --group --coin $PWD/grouptest.coin $PWD/groupbank.group 5
+
+echo "[*] Depositing it, then again, which should give the same receipt"
+./depositclient $PWD/groupreceipt.pub $PWD/grouptest.coin localhost:2235
+./depositclient $PWD/groupreceipt.pub $PWD/grouptest.coin localhost:2235
+
+echo "[*] Depositing a coin one member signed on its own, which should be refused"
+./partialblindsigserver $PWD/groupbank0.pri $PWD/groupbank0.catalogue 2236 &
+solojob=$(echo $!)
+sleep 1
+./partialblindsigclient --coin $PWD/grouptestsolo.coin $PWD/groupbank0.pub 5 localhost:2236
+kill $solojob
+./depositclient $PWD/groupreceipt.pub $PWD/grouptestsolo.coin localhost:2235
+
+echo "[*] Withdrawing with a member down, which should fail"
+kill ${jobs[2]}
+wait ${jobs[2]} 2>/dev/null
+./partialblindsigclient --group $PWD/groupbank.group 5
+
+sleep 1
+
+echo "[*] Killing server jobs"
+kill ${jobs[0]} ${jobs[1]} $depositjob
@@ -0,0 +1,33 @@
+#!/bin/bash
+
+
+echo "[*] Generating keys and agreed information"
+./keytool gen --usage blind $PWD/blindtest
+./keytool raninf $PWD/blindtest.info
+./keytool mkcatalogue $PWD/blindtest $PWD/blindtest.catalogue 1,$PWD/blindtest.info,1
+
+./partialblindsigserver --max-sessions 1 --session-timeout 3s $PWD/blindtest.pri $PWD/blindtest.catalogue 1114 &
+jobid=$(echo $!)
+echo "[*] Background server started with PID=$jobid"
+
+sleep 1
+
+echo "[*] Holding the only session open without answering"
+exec 3<>/dev/tcp/localhost/1114
+printf '\x01\x011' >&3
+sleep 1
+
+echo "[*] Launching Client, which should be told to retry"
+./partialblindsigclient $PWD/blindtest.pub 1 localhost:1114
+
+echo "[*] Waiting for the held session to expire"
+sleep 4
+exec 3<&-
+
+echo "[*] Launching Client again"
+./partialblindsigclient $PWD/blindtest.pub 1 localhost:1114
+
+sleep 1
+
+echo "[*] Killing server job"
+kill $jobid
@@ -0,0 +1,53 @@
+#!/bin/bash
+
+
+echo "[*] Generating bank and receipt keys and the catalogue"
+./keytool gen --usage blind $PWD/banktest
+./keytool gen --usage notary $PWD/receipttest
+./keytool raninf $PWD/banktest5.info
+./keytool mkcatalogue $PWD/banktest $PWD/banktest.catalogue 5,$PWD/banktest5.info,5
+rm -f $PWD/banktest.spent
+
+./partialblindsigserver $PWD/banktest.pri $PWD/banktest.catalogue 1113 &
+signjob=$(echo $!)
+./depositserver $PWD/banktest.pub $PWD/banktest.catalogue $PWD/receipttest.pri $PWD/banktest.spent 1115 &
+depositjob=$(echo $!)
+echo "[*] Background servers started with PIDs $signjob and $depositjob"
+
+sleep 1
+
+echo "[*] Withdrawing two coins"
+./partialblindsigclient --coin $PWD/banktest1.coin $PWD/banktest.pub 5 localhost:1113
+./partialblindsigclient --coin $PWD/banktest2.coin $PWD/banktest.pub 5 localhost:1113
+
+echo "[*] Depositing the first coin, then again, which should give the same receipt"
+./depositclient $PWD/receipttest.pub $PWD/banktest1.coin localhost:1115
+./depositclient $PWD/receipttest.pub $PWD/banktest1.coin localhost:1115
+
+echo "[*] Depositing the second coin five times at once; all should get one receipt"
+for i in 1 2 3 4 5; do
+    ./depositclient $PWD/receipttest.pub $PWD/banktest2.coin localhost:1115 &
+done
+wait $(jobs -p | grep -v -e "^$signjob\$" -e "^$depositjob\$")
+
+echo "[*] Depositing a coin with a changed message, which should be refused"
+cp $PWD/banktest1.coin $PWD/banktest3.coin
+printf '\x00' | dd of=$PWD/banktest3.coin bs=1 seek=30 conv=notrunc 2>/dev/null
+./depositclient $PWD/receipttest.pub $PWD/banktest3.coin localhost:1115
+
+echo "[*] Restarting the deposit server after a torn write"
+kill $depositjob
+wait $depositjob 2>/dev/null
+printf 'torn' >> $PWD/banktest.spent
+./depositserver $PWD/banktest.pub $PWD/banktest.catalogue $PWD/receipttest.pri $PWD/banktest.spent 1115 &
+depositjob=$(echo $!)
+sleep 1
+
+echo "[*] Depositing both coins again, which should give their first receipts"
+./depositclient $PWD/receipttest.pub $PWD/banktest1.coin localhost:1115
+./depositclient $PWD/receipttest.pub $PWD/banktest2.coin localhost:1115
+
+sleep 1
+
+echo "[*] Killing server jobs"
+kill $signjob $depositjob
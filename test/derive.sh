@@ -0,0 +1,22 @@
+#!/bin/bash
+
+
+echo "[*] Generating master key"
+./keytool gen $PWD/master
+
+echo "[*] Writing the master extended public key"
+./keytool derive $PWD/master m $PWD/masterx
+
+echo "[*] Deriving a server key, and its public key from the master .xpub"
+./keytool derive $PWD/master m/notary/3 $PWD/notary3
+./keytool derive-pub $PWD/masterx.xpub m/notary/3 $PWD/notary3-check
+
+# derived keys record metadata; compare the key fields only.
+if [ "$(cut -d';' -f1,2 notary3.pub)" = "$(cut -d';' -f1,2 notary3-check.pub)" ]; then
+    echo "[*] Public derivation matches"
+else
+    echo "[!] Public derivation MISMATCH"
+fi
+
+echo "[*] Hardened steps need the secret key"
+./keytool derive-pub $PWD/masterx.xpub "m/notary'/3" $PWD/hard
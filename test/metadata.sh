@@ -0,0 +1,8 @@
+#!/bin/bash
+
+
+echo "[*] Generating a key that may only be used for multisignatures"
+./keytool gen --usage multisig --expires 2099-12-31 $PWD/msonly
+
+echo "[*] The notary must refuse it"
+./notaryserver -keyfile $PWD/msonly.pri -port 2250
@@ -0,0 +1,28 @@
+#!/bin/bash
+
+
+echo "[*] Generating keys"
+./keytool gen $PWD/rg0
+./keytool gen $PWD/rg1
+./keytool gen $PWD/rg2
+
+echo "[*] Making group configuration file"
+./keytool mkgroup ringgroup.txt localhost:2240,$PWD/rg0.pub \
+                                localhost:2241,$PWD/rg1.pub \
+                                localhost:2242,$PWD/rg2.pub
+
+echo "the group approves" > ringmsg.txt
+
+echo "[*] Signing as two different members, and twice as one"
+./keytool ringsign --context vote ringgroup.txt $PWD/rg1 ringmsg.txt ring1.sig
+./keytool ringsign --context vote ringgroup.txt $PWD/rg1 ringmsg.txt ring1b.sig
+./keytool ringsign --context vote ringgroup.txt $PWD/rg2 ringmsg.txt ring2.sig
+
+echo "[*] Verifying"
+./keytool ringverify --context vote --link ring1b.sig:ringmsg.txt \
+        --link ring2.sig:ringmsg.txt ringgroup.txt ringmsg.txt ring1.sig
+
+echo "[*] Linking a signature against the wrong message, which should fail"
+echo "the group objects" > ringmsg2.txt
+./keytool ringverify --context vote --link ring1b.sig:ringmsg2.txt \
+        ringgroup.txt ringmsg.txt ring1.sig